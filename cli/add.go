@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/esm-dev/esm.sh/internal/importmap"
+	"github.com/esm-dev/esm.sh/internal/npm"
 	"github.com/goccy/go-json"
 	"github.com/ije/gox/term"
 	"golang.org/x/net/html"
@@ -26,7 +28,12 @@ Arguments:
   [...packages]    Packages to add, separated by space
 
 Options:
+  --npmrc          Send the credentials of the ".npmrc" file to the CDN to resolve private packages
   --help           Show help message
+
+With the "--npmrc" flag, private packages are resolved with the ".npmrc" file next to the index.html. The credentials
+are only sent to the CDN specified by the "$cdn" field of the "importmap" script, and only the credentials of the
+registry that serves the package are sent.
 `
 
 const htmlTemplate = `<!DOCTYPE html>
@@ -48,6 +55,7 @@ const htmlTemplate = `<!DOCTYPE html>
 // Add adds packages to "importmap" script
 func Add() {
 	help := flag.Bool("help", false, "Show help message")
+	useNpmrc := flag.Bool("npmrc", false, "Send the credentials of the .npmrc file to the CDN")
 	arg0, argMore := parseCommandFlag(2)
 
 	if *help {
@@ -61,18 +69,28 @@ func Add() {
 		packages = append(packages, argMore...)
 	}
 
-	err := updateImportMap(packages)
+	err := updateImportMap(packages, *useNpmrc)
 	if err != nil {
 		fmt.Println(term.Red("✖︎"), "Failed to add packages: "+err.Error())
 	}
 }
 
-func updateImportMap(packages []string) (err error) {
+func updateImportMap(packages []string, useNpmrc bool) (err error) {
 	indexHtml, exists, err := lookupCloestFile("index.html")
 	if err != nil {
 		return
 	}
 
+	// use the `.npmrc` file next to the index.html to resolve private packages, the credentials are only sent to
+	// the CDN specified by the `$cdn` field (see `ImportMap.AddPackages`)
+	var npmrc *npm.RC
+	if useNpmrc {
+		npmrc, err = loadNpmrc(filepath.Dir(indexHtml))
+		if err != nil {
+			return fmt.Errorf("invalid .npmrc: %w", err)
+		}
+	}
+
 	if exists {
 		var f *os.File
 		f, err = os.Open(indexHtml)
@@ -91,7 +109,7 @@ func updateImportMap(packages []string) (err error) {
 				tagName, _ := tokenizer.TagName()
 				if string(tagName) == "head" && !updated {
 					buf.WriteString("  <script type=\"importmap\">\n    ")
-					importMap := importmap.ImportMap{}
+					importMap := importmap.ImportMap{Npmrc: npmrc}
 					if !importMap.AddPackages(packages) {
 						return
 					}
@@ -117,7 +135,7 @@ func updateImportMap(packages []string) (err error) {
 					}
 					if typeAttr != "importmap" && !updated {
 						buf.WriteString("<script type=\"importmap\">\n    ")
-						importMap := importmap.ImportMap{}
+						importMap := importmap.ImportMap{Npmrc: npmrc}
 						if !importMap.AddPackages(packages) {
 							return
 						}
@@ -142,6 +160,7 @@ func updateImportMap(packages []string) (err error) {
 							}
 						}
 						buf.WriteString("\n    ")
+						importMap.Npmrc = npmrc
 						if !importMap.AddPackages(packages) {
 							return
 						}
@@ -165,7 +184,7 @@ func updateImportMap(packages []string) (err error) {
 		}
 		err = os.WriteFile(indexHtml, buf.Bytes(), fi.Mode())
 	} else {
-		importMap := importmap.ImportMap{}
+		importMap := importmap.ImportMap{Npmrc: npmrc}
 		if !importMap.AddPackages(packages) {
 			return
 		}
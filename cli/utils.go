@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/esm-dev/esm.sh/internal/npm"
 	"golang.org/x/term"
 )
 
@@ -51,7 +52,13 @@ func parseCommandFlag(start int) (string, []string) {
 				nextVaule = false
 			}
 		} else if !strings.Contains(arg, "=") {
+			// the boolean flags don't take the next argument as the value
 			nextVaule = true
+			if f := flag.CommandLine.Lookup(strings.TrimLeft(arg, "-")); f != nil {
+				if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+					nextVaule = false
+				}
+			}
 		}
 	}
 	if len(args) == 0 {
@@ -83,6 +90,19 @@ func lookupCloestFile(basename string) (filename string, exists bool, err error)
 	return filepath.Join(cwd, basename), false, nil
 }
 
+// loadNpmrc loads the `.npmrc` file in the given directory, the `${VAR}` references are expanded by the
+// environment variables. It returns nil if the file does not exist.
+func loadNpmrc(dir string) (*npm.RC, error) {
+	rc, err := npm.ReadRCFile(filepath.Join(dir, ".npmrc"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rc, nil
+}
+
 func walkEmbedFS(fs *embed.FS, dir string, callback func(filename string) error) error {
 	entries, err := efs.ReadDir(dir)
 	if err != nil {
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/esm-dev/esm.sh/internal/signature"
+	"github.com/goccy/go-json"
+	"github.com/ije/gox/term"
+)
+
+const verifyHelpMessage = "\033[30mesm.sh - A nobuild tool for modern web development.\033[0m" + `
+
+Usage: esm.sh verify [...urls] [options]
+
+Examples:
+  esm.sh verify https://esm.sh/react@19.0.0/es2022/react.mjs
+  esm.sh verify https://esm.example.com/react@19.0.0/es2022/react.mjs --keys ./esm-signing-keys.json
+
+Arguments:
+  [...urls]    The build urls to verify, separated by space
+
+Options:
+  --keys       The public keys(JWKS) file or url, default is "<origin>/.well-known/esm-signing-keys.json"
+  --help       Show help message
+`
+
+// Verify verifies the signatures of the build artifacts
+func Verify() {
+	keys := flag.String("keys", "", "the public keys(JWKS) file or url")
+	help := flag.Bool("help", false, "Show help message")
+	arg0, argMore := parseCommandFlag(2)
+
+	if *help || arg0 == "" {
+		fmt.Print(verifyHelpMessage)
+		return
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	keySets := map[string]*signature.JWKS{}
+	failed := false
+	for _, arg := range append([]string{arg0}, argMore...) {
+		err := verifyArtifact(client, arg, *keys, keySets)
+		if err != nil {
+			fmt.Println(term.Red("✖︎"), arg, term.Dim(err.Error()))
+			failed = true
+		} else {
+			fmt.Println(term.Green("✔"), arg)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func verifyArtifact(client *http.Client, artifactUrl string, keys string, keySets map[string]*signature.JWKS) error {
+	u, err := url.Parse(artifactUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("invalid url")
+	}
+	if keys == "" {
+		keys = u.Scheme + "://" + u.Host + "/.well-known/esm-signing-keys.json"
+	}
+	jwks, ok := keySets[keys]
+	if !ok {
+		jwks, err = loadSigningKeys(client, keys)
+		if err != nil {
+			return fmt.Errorf("failed to load the public keys: %w", err)
+		}
+		keySets[keys] = jwks
+	}
+
+	res, err := client.Get(artifactUrl)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return errors.New("unexpected http status " + res.Status)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	v := res.Header.Get("X-ESM-Signature")
+	if v == "" {
+		return errors.New("the artifact is not signed")
+	}
+	sig, err := signature.Parse(v)
+	if err != nil {
+		return err
+	}
+	// the classic scripts(iife/umd) are served with the build path in the `X-ESM-Path` header
+	pathname := res.Header.Get("X-ESM-Path")
+	if pathname == "" {
+		pathname = u.Path
+	}
+	return signature.Verify(jwks.PublicKeys(), pathname, data, sig)
+}
+
+func loadSigningKeys(client *http.Client, keys string) (*signature.JWKS, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(keys, "https://") || strings.HasPrefix(keys, "http://") {
+		var res *http.Response
+		res, err = client.Get(keys)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != 200 {
+			return nil, errors.New("unexpected http status " + res.Status)
+		}
+		data, err = io.ReadAll(res.Body)
+	} else {
+		data, err = os.ReadFile(keys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jwks signature.JWKS
+	err = json.Unmarshal(data, &jwks)
+	if err != nil {
+		return nil, err
+	}
+	return &jwks, nil
+}
@@ -22,9 +22,22 @@ type FetchClient struct {
 
 // NewClient creates a new FetchClient.
 func NewClient(userAgent string, timeout int, reserveRedirect bool) (client *FetchClient, recycle func()) {
+	return newClient(userAgent, timeout, reserveRedirect, nil, nil)
+}
+
+// NewGuardedClient creates a new FetchClient that refuses to connect to the private, loopback, link-local and
+// cloud metadata addresses. The addresses are checked after the DNS resolution, so a public domain that resolves
+// to an internal address or a redirect to an internal host is blocked as well. The optional `allowURL` function
+// is called with the redirect urls.
+func NewGuardedClient(userAgent string, timeout int, reserveRedirect bool, allowURL func(u *url.URL) bool) (client *FetchClient, recycle func()) {
+	return newClient(userAgent, timeout, reserveRedirect, guardedTransport, allowURL)
+}
+
+func newClient(userAgent string, timeout int, reserveRedirect bool, transport http.RoundTripper, allowURL func(u *url.URL) bool) (client *FetchClient, recycle func()) {
 	client = clientPool.Get().(*FetchClient)
 	client.userAgent = userAgent
 	client.Timeout = time.Duration(timeout) * time.Second
+	client.Transport = transport
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		if reserveRedirect && len(via) > 0 {
 			return http.ErrUseLastResponse
@@ -32,6 +45,14 @@ func NewClient(userAgent string, timeout int, reserveRedirect bool) (client *Fet
 		if len(via) >= 3 {
 			return errors.New("stopped after 3 redirects")
 		}
+		if transport == guardedTransport {
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("redirect to unsupported scheme: " + req.URL.Scheme)
+			}
+			if allowURL != nil && !allowURL(req.URL) {
+				return errors.New("redirect to disallowed origin: " + req.URL.Scheme + "://" + req.URL.Host)
+			}
+		}
 		return nil
 	}
 	return client, func() { clientPool.Put(client) }
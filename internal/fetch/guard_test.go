@@ -0,0 +1,50 @@
+package fetch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	for _, s := range []string{"8.8.8.8", "1.1.1.1", "2606:4700:4700::1111"} {
+		if !IsPublicAddr(netip.MustParseAddr(s)) {
+			t.Fatalf("%s should be public", s)
+		}
+	}
+	for _, s := range []string{
+		"127.0.0.1", "10.0.0.1", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0",
+		"255.255.255.255", "::1", "fe80::1", "fd00:ec2::254", "::ffff:10.0.0.1", "64:ff9b::a00:1",
+	} {
+		if IsPublicAddr(netip.MustParseAddr(s)) {
+			t.Fatalf("%s should not be public", s)
+		}
+	}
+}
+
+func TestGuardedClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+
+	client, recycle := NewClient("test", 5, false)
+	res, err := client.Fetch(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	recycle()
+
+	client, recycle = NewGuardedClient("test", 5, false, nil)
+	defer recycle()
+	_, err = client.Fetch(u, nil)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("expected ErrPrivateAddress, got %v", err)
+	}
+}
@@ -25,6 +25,7 @@ type ImportMap struct {
 	Scopes    map[string]map[string]string `json:"scopes,omitempty"`
 	Routes    map[string]string            `json:"routes,omitempty"`
 	Integrity map[string]string            `json:"integrity,omitempty"`
+	Npmrc     *npm.RC                      `json:"-"` // sent as the `X-Npmrc` header to the explicit `$cdn` only
 	srcUrl    *url.URL
 }
 
@@ -88,8 +89,14 @@ func (im *ImportMap) toAbsPath(path string) string {
 
 func (im *ImportMap) AddPackages(packages []string) bool {
 	cdnOrigin := im.Cdn
+	npmrc := im.Npmrc
 	if cdnOrigin == "" {
 		cdnOrigin = "https://esm.sh"
+		// never send the credentials to the default CDN
+		if npmrc != nil {
+			fmt.Println(term.Dim("The .npmrc credentials are not sent, set the \"$cdn\" field of the importmap to your CDN."))
+			npmrc = nil
+		}
 	}
 
 	var resolvedPackages []PackageJSON
@@ -109,7 +116,7 @@ func (im *ImportMap) AddPackages(packages []string) bool {
 				errors = append(errors, fmt.Errorf("invalid package name or version: %s", pkg))
 				return
 			}
-			pkgJson, err := fetchPackageInfo(cdnOrigin, pkg)
+			pkgJson, err := fetchPackageInfo(cdnOrigin, pkg, npmrc)
 			if err != nil {
 				errors = append(errors, err)
 				return
@@ -189,7 +196,7 @@ func (im *ImportMap) AddPackages(packages []string) bool {
 						}
 					}
 				}
-				p, err := fetchPackageInfo(cdnOrigin, pkgName+"@"+pkgVersion)
+				p, err := fetchPackageInfo(cdnOrigin, pkgName+"@"+pkgVersion, npmrc)
 				if err != nil {
 					errors = append(errors, err)
 					return
@@ -307,26 +314,49 @@ var (
 	cacheStore sync.Map
 )
 
-func fetchPackageInfo(cdnOrigin string, pkg string) (pkgJSON PackageJSON, err error) {
+func fetchPackageInfo(cdnOrigin string, pkg string, npmrc *npm.RC) (pkgJSON PackageJSON, err error) {
 	url := fmt.Sprintf("%s/%s/package.json", cdnOrigin, pkg)
+	cacheKey := url
+	var npmrcHeader string
+	if npmrc != nil {
+		// only send the credentials of the registry that serves the package
+		pkgName := pkg
+		if i := strings.IndexByte(pkg[1:], '@'); i >= 0 {
+			pkgName = pkg[:i+1]
+		}
+		var data []byte
+		data, err = json.Marshal(npmrc.ForPackage(pkgName))
+		if err != nil {
+			return
+		}
+		npmrcHeader = string(data)
+		cacheKey += "#" + npmrcHeader
+	}
 
 	// check cache first
-	if v, ok := cacheStore.Load(url); ok {
+	if v, ok := cacheStore.Load(cacheKey); ok {
 		pkgJSON, _ = v.(PackageJSON)
 		return
 	}
 
 	// only one fetch at a time for the same url
-	unlock := cacheMutex.Lock(url)
+	unlock := cacheMutex.Lock(cacheKey)
 	defer unlock()
 
 	// check cache again after get lock
-	if v, ok := cacheStore.Load(url); ok {
+	if v, ok := cacheStore.Load(cacheKey); ok {
 		pkgJSON, _ = v.(PackageJSON)
 		return
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return
+	}
+	if npmrcHeader != "" {
+		req.Header.Set("X-Npmrc", npmrcHeader)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		err = errors.New("http request failed: " + err.Error())
 		return
@@ -347,7 +377,7 @@ func fetchPackageInfo(cdnOrigin string, pkg string) (pkgJSON PackageJSON, err er
 		err = errors.New("could not parse package.json")
 	}
 	if err == nil {
-		cacheStore.Store(url, pkgJSON)
+		cacheStore.Store(cacheKey, pkgJSON)
 	}
 	return
 }
@@ -32,6 +32,8 @@ type Package struct {
 	Version  string
 	Github   bool
 	PkgPrNew bool
+	GitHost  string
+	Tgz      bool
 }
 
 func (p *Package) String() string {
@@ -39,15 +41,23 @@ func (p *Package) String() string {
 	if p.Github {
 		return "gh/" + s
 	}
+	if p.GitHost != "" {
+		return "git/" + p.GitHost + "/" + s
+	}
 	if p.PkgPrNew {
 		return "pr/" + s
 	}
+	if p.Tgz {
+		return "tgz/" + s
+	}
 	return s
 }
 
 // ResolveDependencyVersion resolves the version of a dependency
 // e.g. "react": "npm:react@19.0.0"
 // e.g. "react": "github:facebook/react#semver:19.0.0"
+// e.g. "lib": "gitlab:owner/lib#v1.0.0"
+// e.g. "lib": "git+https://codeberg.org/owner/lib.git#v1.0.0"
 // e.g. "flag": "jsr:@luca/flag@0.0.1"
 // e.g. "tinybench": "https://pkg.pr.new/tinybench@a832a55"
 func ResolveDependencyVersion(v string) (Package, error) {
@@ -81,20 +91,39 @@ func ResolveDependencyVersion(v string) (Package, error) {
 			Version: strings.TrimPrefix(url.QueryEscape(fragment), "semver:"),
 		}, nil
 	}
+	if strings.HasPrefix(v, "gitlab:") || strings.HasPrefix(v, "bitbucket:") {
+		host, rest := utils.SplitByFirstByte(v, ':')
+		repo, fragment := utils.SplitByLastByte(rest, '#')
+		if host == "gitlab" {
+			host = "gitlab.com"
+		} else {
+			host = "bitbucket.org"
+		}
+		return Package{
+			GitHost: host,
+			Name:    repo,
+			Version: strings.TrimPrefix(url.QueryEscape(fragment), "semver:"),
+		}, nil
+	}
 	if strings.HasPrefix(v, "git+ssh://") || strings.HasPrefix(v, "git+https://") || strings.HasPrefix(v, "git://") {
 		gitUrl, e := url.Parse(v)
-		if e != nil || gitUrl.Hostname() != "github.com" {
+		if e != nil || len(gitUrl.Path) < 2 {
 			return Package{}, errors.New("unsupported git dependency")
 		}
 		repo := strings.TrimSuffix(gitUrl.Path[1:], ".git")
 		if gitUrl.Scheme == "git+ssh" {
 			repo = gitUrl.Port() + "/" + repo
 		}
-		return Package{
-			Github:  true,
+		pkg := Package{
 			Name:    repo,
 			Version: strings.TrimPrefix(url.QueryEscape(gitUrl.Fragment), "semver:"),
-		}, nil
+		}
+		if hostname := gitUrl.Hostname(); hostname == "github.com" {
+			pkg.Github = true
+		} else {
+			pkg.GitHost = hostname
+		}
+		return pkg, nil
 	}
 	// https://pkg.pr.new
 	if strings.HasPrefix(v, "https://") || strings.HasPrefix(v, "http://") {
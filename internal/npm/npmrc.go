@@ -0,0 +1,247 @@
+package npm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var regexpEnvRef = regexp.MustCompile(`\$\{([^${}]+)\}`)
+
+// RCRegistry defines a registry entry of a `.npmrc` file.
+type RCRegistry struct {
+	Registry string `json:"registry,omitempty"`
+	Token    string `json:"token,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// RC defines the content of a `.npmrc` file.
+type RC struct {
+	RCRegistry
+	ScopedRegistries map[string]RCRegistry `json:"scopedRegistries,omitempty"`
+	// AuthTokens contains the `_authToken` of hosts, e.g. `//github.com/:_authToken=***`
+	AuthTokens map[string]string `json:"-"`
+}
+
+type rcAuth struct {
+	token    string
+	auth     string
+	user     string
+	password string
+}
+
+// ParseRC parses the given `.npmrc` (ini) content.
+// Supported keys are `registry`, `@scope:registry`, `//host/path/:_authToken`, `//host/path/:_auth`,
+// `//host/path/:username`, `//host/path/:_password`, `_auth` and `_authToken`. The `always-auth` key is ignored, the
+// credentials of a registry are sent with every request to the registry, including the tarball downloads.
+// The `${VAR}` references are expanded by the `getenv` function, or kept as-is if it's nil.
+func ParseRC(data []byte, getenv func(key string) string) (rc *RC, err error) {
+	rc = &RC{ScopedRegistries: map[string]RCRegistry{}, AuthTokens: map[string]string{}}
+	global := rcAuth{}
+	auths := map[string]*rcAuth{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '[' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = unquoteRCValue(strings.TrimSpace(value))
+		if getenv != nil {
+			value = expandRCEnv(value, getenv)
+		}
+		if strings.HasPrefix(key, "//") {
+			// e.g. //registry.npmjs.org/:_authToken=***
+			i := strings.LastIndex(key, ":")
+			if i < 0 {
+				continue
+			}
+			prefix, name := key[:i], key[i+1:]
+			if !strings.HasSuffix(prefix, "/") {
+				prefix += "/"
+			}
+			auth, ok := auths[prefix]
+			if !ok {
+				auth = &rcAuth{}
+				auths[prefix] = auth
+			}
+			setRCAuthField(auth, name, value)
+			continue
+		}
+		switch {
+		case key == "registry":
+			rc.Registry, err = normalizeRCRegistry(value)
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(key, "@") && strings.HasSuffix(key, ":registry"):
+			scope := strings.TrimSuffix(key, ":registry")
+			if len(scope) < 2 || !Naming.Match(scope[1:]) {
+				return nil, fmt.Errorf("invalid scope name '%s'", scope)
+			}
+			var registry string
+			registry, err = normalizeRCRegistry(value)
+			if err != nil {
+				return nil, err
+			}
+			rc.ScopedRegistries[scope] = RCRegistry{Registry: registry}
+		default:
+			setRCAuthField(&global, key, value)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// sort auth prefixes by length (longest first) to match the most specific one
+	prefixes := make([]string, 0, len(auths))
+	for prefix, auth := range auths {
+		prefixes = append(prefixes, prefix)
+		if host := prefix[2 : len(prefix)-1]; host != "" && auth.token != "" && !strings.ContainsRune(host, '/') {
+			rc.AuthTokens[host] = auth.token
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	applyAuth := func(reg *RCRegistry, fallback *rcAuth) error {
+		var auth *rcAuth
+		if reg.Registry != "" {
+			u, err := url.Parse(reg.Registry)
+			if err != nil {
+				return err
+			}
+			nerfed := "//" + u.Host + u.Path
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(nerfed, prefix) {
+					auth = auths[prefix]
+					break
+				}
+			}
+		}
+		if auth == nil {
+			auth = fallback
+		}
+		if auth == nil {
+			return nil
+		}
+		reg.Token = auth.token
+		reg.User = auth.user
+		if auth.password != "" {
+			password, err := base64.StdEncoding.DecodeString(auth.password)
+			if err != nil {
+				return errors.New("invalid `_password`, require base64 encoded string")
+			}
+			reg.Password = string(password)
+		}
+		if auth.auth != "" {
+			userinfo, err := base64.StdEncoding.DecodeString(auth.auth)
+			if err != nil {
+				return errors.New("invalid `_auth`, require base64 encoded string")
+			}
+			user, password, ok := strings.Cut(string(userinfo), ":")
+			if !ok {
+				return errors.New("invalid `_auth`, require base64 encoded `user:password`")
+			}
+			reg.User = user
+			reg.Password = password
+		}
+		return nil
+	}
+
+	// the global auth fields only apply to the default registry
+	err = applyAuth(&rc.RCRegistry, &global)
+	if err != nil {
+		return nil, err
+	}
+	for scope, reg := range rc.ScopedRegistries {
+		err = applyAuth(&reg, nil)
+		if err != nil {
+			return nil, err
+		}
+		rc.ScopedRegistries[scope] = reg
+	}
+	return rc, nil
+}
+
+// ForPackage returns a copy of the rc that only contains the registry of the given package, the credentials of
+// the other registries and hosts are stripped.
+func (rc *RC) ForPackage(pkgName string) *RC {
+	ret := &RC{}
+	if strings.HasPrefix(pkgName, "@") {
+		scope, _, _ := strings.Cut(pkgName, "/")
+		if reg, ok := rc.ScopedRegistries[scope]; ok {
+			ret.ScopedRegistries = map[string]RCRegistry{scope: reg}
+			return ret
+		}
+	}
+	ret.RCRegistry = rc.RCRegistry
+	return ret
+}
+
+// ReadRCFile reads and parses the given `.npmrc` file, the `${VAR}` references are expanded by
+// the environment variables.
+func ReadRCFile(filename string) (*RC, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRC(data, os.Getenv)
+}
+
+// ExpandRCEnv expands the `${VAR}` references in the given `.npmrc` content.
+func ExpandRCEnv(data []byte, getenv func(key string) string) []byte {
+	return []byte(expandRCEnv(string(data), getenv))
+}
+
+func expandRCEnv(s string, getenv func(key string) string) string {
+	return regexpEnvRef.ReplaceAllStringFunc(s, func(ref string) string {
+		return getenv(ref[2 : len(ref)-1])
+	})
+}
+
+func setRCAuthField(auth *rcAuth, name string, value string) {
+	switch name {
+	case "_authToken":
+		auth.token = value
+	case "_auth":
+		auth.auth = value
+	case "username":
+		auth.user = value
+	case "_password":
+		auth.password = value
+	}
+}
+
+func normalizeRCRegistry(registry string) (string, error) {
+	u, err := url.Parse(registry)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid registry '%s'", registry)
+	}
+	return strings.TrimRight(registry, "/") + "/", nil
+}
+
+func unquoteRCValue(value string) string {
+	if l := len(value); l >= 2 && (value[0] == '"' || value[0] == '\'') && value[l-1] == value[0] {
+		return value[1 : l-1]
+	}
+	// strip inline comments
+	for _, sep := range []string{" #", " ;", "\t#", "\t;"} {
+		if i := strings.Index(value, sep); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+	}
+	return value
+}
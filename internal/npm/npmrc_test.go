@@ -0,0 +1,83 @@
+package npm
+
+import (
+	"testing"
+)
+
+func TestParseRC(t *testing.T) {
+	rc, err := ParseRC([]byte(`
+# comments
+registry=https://registry.example.com
+@acme:registry = https://npm.pkg.github.com/
+@private:registry="https://registry.example.com/private/"
+//npm.pkg.github.com/:_authToken=${GITHUB_TOKEN}
+//registry.example.com/:_auth=dXNlcjpwYXNz ; inline comment
+//registry.example.com/private/:_authToken=private-token
+//github.com/:_authToken=${GITHUB_TOKEN}
+`), func(key string) string {
+		if key == "GITHUB_TOKEN" {
+			return "gh-token"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.Registry != "https://registry.example.com/" {
+		t.Fatalf("invalid registry: %s", rc.Registry)
+	}
+	if rc.User != "user" || rc.Password != "pass" || rc.Token != "" {
+		t.Fatalf("invalid registry auth: %+v", rc.RCRegistry)
+	}
+	acme, ok := rc.ScopedRegistries["@acme"]
+	if !ok || acme.Registry != "https://npm.pkg.github.com/" || acme.Token != "gh-token" {
+		t.Fatalf("invalid scoped registry: %+v", acme)
+	}
+	private, ok := rc.ScopedRegistries["@private"]
+	if !ok || private.Token != "private-token" || private.User != "" {
+		t.Fatalf("invalid scoped registry: %+v", private)
+	}
+	if rc.AuthTokens["github.com"] != "gh-token" || rc.AuthTokens["npm.pkg.github.com"] != "gh-token" {
+		t.Fatalf("invalid auth tokens: %+v", rc.AuthTokens)
+	}
+
+	rc, err = ParseRC([]byte("_authToken=${NPM_TOKEN}"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.Token != "${NPM_TOKEN}" {
+		t.Fatalf("env references should not be expanded: %s", rc.Token)
+	}
+
+	_, err = ParseRC([]byte("registry=file:///etc/passwd"), nil)
+	if err == nil {
+		t.Fatal("should return an error for invalid registry")
+	}
+}
+
+func TestRCForPackage(t *testing.T) {
+	rc, err := ParseRC([]byte(`
+registry=https://registry.example.com/
+//registry.example.com/:_authToken=default-token
+@acme:registry=https://npm.pkg.github.com/
+//npm.pkg.github.com/:_authToken=acme-token
+//github.com/:_authToken=gh-token
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme := rc.ForPackage("@acme/utils")
+	if acme.Token != "" || acme.Registry != "" || len(acme.AuthTokens) != 0 {
+		t.Fatalf("the default registry should be stripped: %+v", acme)
+	}
+	if reg := acme.ScopedRegistries["@acme"]; len(acme.ScopedRegistries) != 1 || reg.Token != "acme-token" {
+		t.Fatalf("invalid scoped registries: %+v", acme.ScopedRegistries)
+	}
+	react := rc.ForPackage("react")
+	if react.Token != "default-token" || len(react.ScopedRegistries) != 0 || len(react.AuthTokens) != 0 {
+		t.Fatalf("the scoped registries should be stripped: %+v", react)
+	}
+	if other := rc.ForPackage("@other/utils"); other.Token != "default-token" || len(other.ScopedRegistries) != 0 {
+		t.Fatalf("unexpected rc: %+v", other)
+	}
+}
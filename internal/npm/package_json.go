@@ -42,7 +42,8 @@ type PackageJSONRaw struct {
 
 // NpmPackageDist defines the dist field of a NPM package
 type NpmPackageDist struct {
-	Tarball string `json:"tarball"`
+	Tarball   string `json:"tarball"`
+	Integrity string `json:"integrity"`
 }
 
 // PackageJSON defines the package.json of a NPM package
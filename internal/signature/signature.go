@@ -0,0 +1,206 @@
+// Package signature implements the Ed25519 signatures of the build artifacts, the signature binds the sha256
+// digest of the artifact to its path, so a signed artifact can not be replaced by another signed one.
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// the version prefix of the signed message
+const messagePrefix = "esm.sh-signature-v1\n"
+
+var (
+	ErrInvalidKey       = errors.New("invalid signing key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrDigestMismatch   = errors.New("digest mismatch")
+)
+
+// Signature is the signature of an artifact, formatted as `kid=<kid>; alg=ed25519; digest=sha256:<hex>; sig=<base64url>`.
+type Signature struct {
+	KeyId  string
+	Digest string
+	Sig    []byte
+}
+
+// String returns the header value of the signature.
+func (s *Signature) String() string {
+	return "kid=" + s.KeyId + "; alg=ed25519; digest=sha256:" + s.Digest + "; sig=" + base64.RawURLEncoding.EncodeToString(s.Sig)
+}
+
+// Parse parses the signature from the header value.
+func Parse(value string) (*Signature, error) {
+	s := &Signature{}
+	for _, part := range strings.Split(value, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, ErrInvalidSignature
+		}
+		switch k {
+		case "kid":
+			s.KeyId = v
+		case "alg":
+			if v != "ed25519" {
+				return nil, errors.New("unsupported signature algorithm: " + v)
+			}
+		case "digest":
+			digest, ok := strings.CutPrefix(v, "sha256:")
+			if !ok || len(digest) != 64 {
+				return nil, ErrInvalidSignature
+			}
+			s.Digest = digest
+		case "sig":
+			sig, err := base64.RawURLEncoding.DecodeString(v)
+			if err != nil || len(sig) != ed25519.SignatureSize {
+				return nil, ErrInvalidSignature
+			}
+			s.Sig = sig
+		}
+	}
+	if s.KeyId == "" || s.Digest == "" || s.Sig == nil {
+		return nil, ErrInvalidSignature
+	}
+	return s, nil
+}
+
+// Signer signs the artifacts with the first key, the other keys are only published to verify the artifacts
+// signed before the key rotation.
+type Signer struct {
+	keys []ed25519.PrivateKey
+}
+
+// NewSigner creates a signer with the base64 encoded Ed25519 private keys(32-byte seed or 64-byte key).
+func NewSigner(keys []string) (*Signer, error) {
+	signer := &Signer{}
+	for _, key := range keys {
+		privateKey, err := ParsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		signer.keys = append(signer.keys, privateKey)
+	}
+	if len(signer.keys) == 0 {
+		return nil, ErrInvalidKey
+	}
+	return signer, nil
+}
+
+// Sign signs the artifact of the path.
+func (s *Signer) Sign(pathname string, data []byte) *Signature {
+	key := s.keys[0]
+	digest := sha256.Sum256(data)
+	sig := &Signature{
+		KeyId:  KeyId(key.Public().(ed25519.PublicKey)),
+		Digest: hex.EncodeToString(digest[:]),
+	}
+	sig.Sig = ed25519.Sign(key, message(pathname, sig.Digest))
+	return sig
+}
+
+// PublicKeys returns the public keys of the signer.
+func (s *Signer) PublicKeys() []ed25519.PublicKey {
+	keys := make([]ed25519.PublicKey, len(s.keys))
+	for i, key := range s.keys {
+		keys[i] = key.Public().(ed25519.PublicKey)
+	}
+	return keys
+}
+
+// JWKS returns the public keys of the signer as a JSON Web Key Set.
+func (s *Signer) JWKS() *JWKS {
+	jwks := &JWKS{Keys: []JWK{}}
+	for _, key := range s.PublicKeys() {
+		jwks.Keys = append(jwks.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			Alg: "EdDSA",
+			Use: "sig",
+			Kid: KeyId(key),
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		})
+	}
+	return jwks
+}
+
+// Verify verifies the artifact of the path with the public keys.
+func Verify(keys []ed25519.PublicKey, pathname string, data []byte, sig *Signature) error {
+	digest := sha256.Sum256(data)
+	if hex.EncodeToString(digest[:]) != sig.Digest {
+		return ErrDigestMismatch
+	}
+	for _, key := range keys {
+		if KeyId(key) == sig.KeyId {
+			if !ed25519.Verify(key, message(pathname, sig.Digest), sig.Sig) {
+				return ErrInvalidSignature
+			}
+			return nil
+		}
+	}
+	return ErrUnknownKey
+}
+
+// JWKS is a JSON Web Key Set of the Ed25519 public keys.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a JSON Web Key of an Ed25519 public key, see https://www.rfc-editor.org/rfc/rfc8037
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid"`
+	X   string `json:"x"`
+}
+
+// PublicKeys returns the Ed25519 public keys of the set.
+func (jwks *JWKS) PublicKeys() (keys []ed25519.PublicKey) {
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys = append(keys, ed25519.PublicKey(x))
+	}
+	return
+}
+
+// ParsePrivateKey parses a base64 encoded Ed25519 private key, which is a 32-byte seed or a 64-byte key.
+func ParsePrivateKey(key string) (ed25519.PrivateKey, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	switch len(data) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(data), nil
+	case ed25519.PrivateKeySize:
+		privateKey := ed25519.PrivateKey(data)
+		// check the public key part
+		if !privateKey.Public().(ed25519.PublicKey).Equal(ed25519.NewKeyFromSeed(privateKey.Seed()).Public()) {
+			return nil, ErrInvalidKey
+		}
+		return privateKey, nil
+	default:
+		return nil, ErrInvalidKey
+	}
+}
+
+// KeyId returns the id of the public key, which is the first 8 bytes of the sha256 hash of the key in hex.
+func KeyId(key ed25519.PublicKey) string {
+	h := sha256.Sum256(key)
+	return hex.EncodeToString(h[:8])
+}
+
+func message(pathname string, digest string) []byte {
+	return []byte(messagePrefix + pathname + "\nsha256:" + digest)
+}
@@ -0,0 +1,58 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	oldKey := ed25519.NewKeyFromSeed(append([]byte{1}, seed[1:]...))
+	signer, err := NewSigner([]string{base64.StdEncoding.EncodeToString(seed), base64.StdEncoding.EncodeToString(oldKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("export default 1;\n")
+	sig := signer.Sign("/foo@1.0.0/es2022/foo.mjs", data)
+	parsed, err := Parse(sig.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := signer.JWKS().PublicKeys()
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %d", len(keys))
+	}
+	if err = Verify(keys, "/foo@1.0.0/es2022/foo.mjs", data, parsed); err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify(keys, "/foo@1.0.0/es2022/foo.mjs", []byte("export default 2;\n"), parsed); err != ErrDigestMismatch {
+		t.Fatalf("expected ErrDigestMismatch, got %v", err)
+	}
+	if err = Verify(keys, "/bar@1.0.0/es2022/bar.mjs", data, parsed); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+	if err = Verify(keys[1:], "/foo@1.0.0/es2022/foo.mjs", data, parsed); err != ErrUnknownKey {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+
+	// the artifacts signed with the old key are still verifiable after the key rotation
+	oldSigner, _ := NewSigner([]string{base64.StdEncoding.EncodeToString(oldKey)})
+	if err = Verify(keys, "/foo@1.0.0/es2022/foo.mjs", data, oldSigner.Sign("/foo@1.0.0/es2022/foo.mjs", data)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"", "kid=abc", "kid=abc; alg=rsa; digest=sha256:00; sig=00"} {
+		if _, err = Parse(v); err == nil {
+			t.Fatalf("%q should be invalid", v)
+		}
+	}
+	if _, err = NewSigner([]string{"invalid"}); err == nil {
+		t.Fatal("invalid key should be rejected")
+	}
+}
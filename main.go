@@ -18,6 +18,7 @@ Commands:
   init                    Initialize a new web application
   serve                   Serve the web application in production mode
   dev                     Serve the web application in development mode with live reload
+  verify [...urls]        Verify the signatures of the build artifacts
 
 Options:
   --version, -v           Show the version of esm.sh CLI
@@ -42,6 +43,8 @@ func main() {
 		cli.Serve(false)
 	case "dev":
 		cli.Serve(true)
+	case "verify":
+		cli.Verify()
 	case "version":
 		fmt.Println("esm.sh CLI " + cli.Version)
 	default:
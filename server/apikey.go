@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/ije/rex"
+)
+
+// the admin endpoints to manage the API keys stored in the database, only accessible by the keys that list the
+// route explicitly, see `matchAdminRoutes`.
+const (
+	apiKeysRoute       = "/apikeys"
+	apiKeysRevokeRoute = "/apikeys/revoke"
+)
+
+type apiKeyContextKey struct{}
+
+// apiKeyAuth returns a middleware that authenticates the requests with the API keys defined in the config or stored
+// in the database. The key is accepted via the `Authorization: Bearer <key>` header, the `X-Api-Key` header or the
+// `?apikey` query.
+func apiKeyAuth(db Database, options *ApiKeyOptions) rex.Handle {
+	limiter := newRateLimiter(time.Minute)
+	return func(ctx *rex.Context) any {
+		pathname := ctx.R.URL.Path
+		isAdminRoute := pathname == apiKeysRoute || pathname == apiKeysRevokeRoute
+		key, fromHeader := getApiKeyFromRequest(ctx.R)
+		if key == "" {
+			if options.Required || isAdminRoute || matchRoutes(options.ProtectedRoutes, pathname) {
+				ctx.SetHeader("WWW-Authenticate", "Bearer")
+				return rex.Status(401, "API Key Required")
+			}
+			return ctx.Next()
+		}
+		apiKey, err := lookupApiKey(db, options, key)
+		if err != nil {
+			return rex.Status(500, err.Error())
+		}
+		if apiKey == nil {
+			ctx.SetHeader("WWW-Authenticate", "Bearer")
+			return rex.Status(401, "Invalid API Key")
+		}
+		if isAdminRoute {
+			if !matchAdminRoutes(apiKey.Routes, pathname) {
+				return rex.Status(403, "Forbidden")
+			}
+		} else if len(apiKey.Routes) > 0 && !matchRoutes(apiKey.Routes, pathname) {
+			return rex.Status(403, "Forbidden")
+		}
+		if apiKey.RateLimit > 0 {
+			ok, retryAfter := limiter.Allow("apikey:"+apiKey.Name, apiKey.RateLimit)
+			if !ok {
+				ctx.SetHeader("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+				return rex.Status(429, "Too Many Requests")
+			}
+		}
+		if fromHeader {
+			// the response may differ between the authenticated and the anonymous requests
+			appendVaryHeader(ctx.W.Header(), "Authorization, X-Api-Key")
+		}
+		ctx.R = ctx.R.WithContext(context.WithValue(ctx.R.Context(), apiKeyContextKey{}, apiKey))
+		if isAdminRoute {
+			return handleApiKeysAdmin(ctx, db, pathname)
+		}
+		return ctx.Next()
+	}
+}
+
+// getApiKeyFromRequest returns the API key of the request.
+func getApiKeyFromRequest(r *http.Request) (key string, fromHeader bool) {
+	if v := r.Header.Get("Authorization"); v != "" {
+		if scheme, token, ok := strings.Cut(v, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token), true
+		}
+	}
+	if v := r.Header.Get("X-Api-Key"); v != "" {
+		return v, true
+	}
+	return r.URL.Query().Get("apikey"), false
+}
+
+// getRequestApiKey returns the authenticated API key of the request, returns nil for the anonymous requests.
+func getRequestApiKey(r *http.Request) *ApiKey {
+	apiKey, _ := r.Context().Value(apiKeyContextKey{}).(*ApiKey)
+	return apiKey
+}
+
+// lookupApiKey looks up the API key in the config first, then in the database.
+func lookupApiKey(db Database, options *ApiKeyOptions, key string) (*ApiKey, error) {
+	for i, k := range options.Keys {
+		if k.Key != "" && subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			return &options.Keys[i], nil
+		}
+	}
+	data, err := db.Get(getApiKeyDBKey(key))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var apiKey ApiKey
+	err = json.Unmarshal(data, &apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+// getApiKeyDBKey returns the database key of the API key, only the hash of the key is stored.
+func getApiKeyDBKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return "apikey:" + hex.EncodeToString(h[:])
+}
+
+// Enabled returns true if the API key layer is configured.
+func (options *ApiKeyOptions) Enabled() bool {
+	return options.Required || len(options.Keys) > 0 || len(options.ProtectedScopes) > 0 || len(options.ProtectedRoutes) > 0
+}
+
+// isPackageAccessible checks if the package is accessible by the request.
+func (options *ApiKeyOptions) isPackageAccessible(r *http.Request, pkgName string) bool {
+	protected := matchPackageScopes(options.ProtectedScopes, pkgName)
+	apiKey := getRequestApiKey(r)
+	if apiKey == nil {
+		return !protected && !options.Required
+	}
+	if len(apiKey.Scopes) == 0 || matchPackageScopes(apiKey.Scopes, pkgName) {
+		return true
+	}
+	// the key scopes restrict all packages if the API key is required
+	return !protected && !options.Required
+}
+
+var (
+	errApiKeyRequired   = errors.New("API Key Required")
+	errPackageForbidden = errors.New("forbidden package")
+)
+
+// checkPackageAccess checks the packages resolved on behalf of the request like the main route, e.g. the imports of
+// the `/graph/` route and the packages of the `/importmap` API. The error is `errApiKeyRequired` or wraps the
+// `errPackageForbidden`.
+func checkPackageAccess(r *http.Request, pkgName string) error {
+	if !config.AllowList.IsPackageAllowed(pkgName) || config.BanList.IsPackageBanned(pkgName) {
+		return fmt.Errorf("%w '%s'", errPackageForbidden, pkgName)
+	}
+	if !config.ApiKeys.isPackageAccessible(r, pkgName) {
+		if getRequestApiKey(r) == nil {
+			return errApiKeyRequired
+		}
+		return fmt.Errorf("%w '%s'", errPackageForbidden, pkgName)
+	}
+	return nil
+}
+
+// handleApiKeysAdmin handles the admin endpoints:
+//   - POST /apikeys creates a new API key with the given name, scopes, routes and rate limit
+//   - POST /apikeys/revoke revokes the given API key
+func handleApiKeysAdmin(ctx *rex.Context, db Database, pathname string) any {
+	if ctx.R.Method != "POST" {
+		return rex.Status(405, "Method Not Allowed")
+	}
+	var input ApiKey
+	err := json.NewDecoder(io.LimitReader(ctx.R.Body, MB)).Decode(&input)
+	if err != nil {
+		return rex.Err(400, "require valid json body")
+	}
+	if pathname == apiKeysRevokeRoute {
+		if input.Key == "" {
+			return rex.Err(400, "Missing key")
+		}
+		err = db.Delete(getApiKeyDBKey(input.Key))
+		if err != nil {
+			return rex.Status(500, err.Error())
+		}
+		return map[string]any{"revoked": true}
+	}
+	if input.Name == "" {
+		return rex.Err(400, "Missing name")
+	}
+	if input.RateLimit < 0 {
+		return rex.Err(400, "Invalid rate limit")
+	}
+	buf := make([]byte, 24)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return rex.Status(500, err.Error())
+	}
+	key := "esm_" + hex.EncodeToString(buf)
+	input.Key = ""
+	data, err := json.Marshal(input)
+	if err != nil {
+		return rex.Status(500, err.Error())
+	}
+	err = db.Put(getApiKeyDBKey(key), data)
+	if err != nil {
+		return rex.Status(500, err.Error())
+	}
+	input.Key = key
+	ctx.SetHeader("Cache-Control", "private, no-store")
+	return input
+}
+
+// matchRoutes checks if the pathname matches any of the routes, e.g. "/gh/*" matches "/gh/owner/repo".
+func matchRoutes(routes []string, pathname string) bool {
+	for _, route := range routes {
+		if prefix, ok := strings.CutSuffix(route, "*"); ok {
+			if strings.HasPrefix(pathname, prefix) {
+				return true
+			}
+		} else if route == pathname {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAdminRoutes checks if the admin route is listed explicitly, e.g. "/apikeys" or "/apikeys*". The wildcard
+// routes like "*" or "/*" don't grant the admin routes.
+func matchAdminRoutes(routes []string, pathname string) bool {
+	for _, route := range routes {
+		if prefix, ok := strings.CutSuffix(route, "*"); ok {
+			if strings.HasPrefix(prefix, apiKeysRoute) && strings.HasPrefix(pathname, prefix) {
+				return true
+			}
+		} else if route == pathname {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPackageScopes checks if the package matches any of the scopes, e.g. "@internal" matches "@internal/utils".
+func matchPackageScopes(scopes []string, pkgName string) bool {
+	for _, scope := range scopes {
+		if scope == "*" || scope == pkgName || (strings.HasPrefix(scope, "@") && !strings.ContainsRune(scope, '/') && strings.HasPrefix(pkgName, scope+"/")) {
+			return true
+		}
+	}
+	return false
+}
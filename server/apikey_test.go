@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApiKeyScopes(t *testing.T) {
+	options := &ApiKeyOptions{
+		ProtectedScopes: []string{"@internal"},
+		Keys: []ApiKey{
+			{Key: "key-a", Name: "a", Scopes: []string{"@internal"}},
+			{Key: "key-b", Name: "b", Scopes: []string{"@other"}},
+		},
+	}
+	anonymous := httptest.NewRequest("GET", "/@internal/utils", nil)
+	if options.isPackageAccessible(anonymous, "@internal/utils") || !options.isPackageAccessible(anonymous, "react") {
+		t.Fatal("anonymous requests should only access the unprotected packages")
+	}
+	for _, k := range []struct {
+		key      string
+		internal bool
+	}{{"key-a", true}, {"key-b", false}} {
+		apiKey, err := lookupApiKey(nil, options, k.key)
+		if err != nil || apiKey == nil {
+			t.Fatalf("lookupApiKey(%s): %v", k.key, err)
+		}
+		r := anonymous.WithContext(context.WithValue(anonymous.Context(), apiKeyContextKey{}, apiKey))
+		if options.isPackageAccessible(r, "@internal/utils") != k.internal || !options.isPackageAccessible(r, "react") {
+			t.Fatalf("unexpected access of the key %s", k.key)
+		}
+	}
+
+	options.Required = true
+	if options.isPackageAccessible(anonymous, "react") {
+		t.Fatal("anonymous requests should be rejected if the API key is required")
+	}
+
+	if !matchRoutes([]string{"/transform", "/gh/*"}, "/gh/owner/repo") || matchRoutes([]string{"/transform"}, "/bundle") {
+		t.Fatal("matchRoutes: unexpected result")
+	}
+	if matchAdminRoutes([]string{"*"}, apiKeysRoute) || matchAdminRoutes([]string{"/*"}, apiKeysRevokeRoute) || matchAdminRoutes([]string{"/api*"}, apiKeysRoute) {
+		t.Fatal("matchAdminRoutes: the wildcard routes should not grant the admin routes")
+	}
+	if !matchAdminRoutes([]string{"/apikeys*"}, apiKeysRevokeRoute) || !matchAdminRoutes([]string{apiKeysRoute}, apiKeysRoute) || matchAdminRoutes([]string{apiKeysRoute}, apiKeysRevokeRoute) {
+		t.Fatal("matchAdminRoutes: unexpected result")
+	}
+	if !matchPackageScopes([]string{"@internal"}, "@internal/utils") || matchPackageScopes([]string{"@internal"}, "@internals/utils") {
+		t.Fatal("matchPackageScopes: unexpected result")
+	}
+}
+
+func TestCheckPackageAccess(t *testing.T) {
+	defer func(options ApiKeyOptions) { config.ApiKeys = options }(config.ApiKeys)
+	config.ApiKeys = ApiKeyOptions{ProtectedScopes: []string{"@internal"}}
+
+	r := httptest.NewRequest("POST", "/importmap", nil)
+	if err := checkPackageAccess(r, "react"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := checkPackageAccess(r, "@internal/utils"); err != errApiKeyRequired {
+		t.Fatalf("expected errApiKeyRequired, got %v", err)
+	}
+	apiKey := &ApiKey{Name: "other", Scopes: []string{"@other"}}
+	r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, apiKey))
+	if err := checkPackageAccess(r, "@internal/utils"); !errors.Is(err, errPackageForbidden) {
+		t.Fatalf("expected errPackageForbidden, got %v", err)
+	}
+}
@@ -39,6 +39,7 @@ type BuildContext struct {
 	esmPath     EsmPath
 	args        BuildArgs
 	bundleMode  BundleMode
+	format      string
 	externalAll bool
 	target      string
 	dev         bool
@@ -51,13 +52,21 @@ type BuildContext struct {
 	esmImports  [][2]string
 	cjsRequires [][3]string
 	smOffset    int
+	// rebuild the module for the bundle analysis, see `buildAnalysis`
+	analyzeBundle bool
+	// the metafile generated by esbuild
+	metafile string
 }
 
 var (
 	regexpESMInternalIdent = regexp.MustCompile(`__[a-zA-Z]+\$`)
 	regexpVarDecl          = regexp.MustCompile(`var ([\w$]+)\s*=\s*[\w$]+$`)
+	regexpExternalAsset    = regexp.MustCompile(`-[A-Z0-9]{8}\.(svg|png|webp|gif|ttf|eot|woff2?)$`)
 )
 
+// the filter of binary assets that are inlined as data URLs by default, see `loaders`
+const binaryAssetsFilter = `\.(svg|png|webp|gif|ttf|eot|woff2?)$`
+
 var loaders = map[string]esbuild.Loader{
 	".js":     esbuild.LoaderJS,
 	".mjs":    esbuild.LoaderJS,
@@ -82,6 +91,10 @@ var loaders = map[string]esbuild.Loader{
 	".eot":    esbuild.LoaderDataURL,
 	".woff":   esbuild.LoaderDataURL,
 	".woff2":  esbuild.LoaderDataURL,
+
+	// CSS modules, the local names(classes, ids and keyframes) are scoped by esbuild, and the JS importers
+	// get the mapping of the scoped names, `composes`, `:global` and `:local` are supported
+	".module.css": esbuild.LoaderLocalCSS,
 }
 
 func (ctx *BuildContext) Path() string {
@@ -126,6 +139,9 @@ func (ctx *BuildContext) Build() (meta *BuildMeta, err error) {
 	if ctx.target == "types" {
 		return ctx.buildTypes()
 	}
+	if ctx.analyzeBundle {
+		return ctx.buildAnalysis()
+	}
 
 	// check previous build
 	meta, ok, err := ctx.Exists()
@@ -211,6 +227,19 @@ func (ctx *BuildContext) buildPath() {
 	if ctx.dev {
 		name += ".development"
 	}
+	if ctx.format != "" {
+		// the script formats(iife/umd) always bundle dependencies, e.g. react.iife.js
+		ctx.path = fmt.Sprintf(
+			"/%s%s/%s%s/%s.%s.js",
+			asteriskPrefix,
+			esm.Name(),
+			ctx.getBuildArgsPrefix(false),
+			ctx.target,
+			name,
+			ctx.format,
+		)
+		return
+	}
 	if ctx.bundleMode == BundleDeps {
 		name += ".bundle"
 	} else if ctx.bundleMode == BundleFalse {
@@ -263,7 +292,7 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 	}
 
 	// json module
-	if strings.HasSuffix(entry.main, ".json") {
+	if strings.HasSuffix(entry.main, ".json") && ctx.format == "" {
 		if analyzeMode {
 			return
 		}
@@ -277,30 +306,32 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 		defer recycle()
 		buffer.WriteString("export default ")
 		buffer.Write(jsonData)
-		err = ctx.storage.Put(ctx.getSavepath(), buffer)
+		var sig string
+		sig, err = ctx.putArtifact(ctx.getSavepath(), ctx.Path(), buffer.Bytes())
 		if err != nil {
 			ctx.logger.Errorf("storage.put(%s): %v", ctx.getSavepath(), err)
 			err = errors.New("storage: " + err.Error())
 			return
 		}
-		meta = &BuildMeta{ExportDefault: true}
+		meta = &BuildMeta{ExportDefault: true, Signature: sig}
 		return
 	}
 
 	var (
-		cjsReexport string
-		cjsExports  []string
+		cjsReexport  string
+		namedExports []string
+		exportStar   bool
 	)
 
 	if !analyzeMode {
-		meta, cjsExports, cjsReexport, err = ctx.lexer(&entry)
+		meta, namedExports, exportStar, cjsReexport, err = ctx.lexer(&entry)
 		if err != nil {
 			return
 		}
 	}
 
 	// cjs reexport
-	if cjsReexport != "" {
+	if cjsReexport != "" && ctx.format == "" {
 		dep, _, e := ctx.lookupDep(cjsReexport, false)
 		if e != nil {
 			err = e
@@ -322,7 +353,7 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 			return
 		}
 		entry = b.resolveEntry(dep)
-		meta, _, _, err = b.lexer(&entry)
+		meta, namedExports, exportStar, _, err = b.lexer(&entry)
 		if err != nil {
 			return
 		}
@@ -332,13 +363,16 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 		if meta.ExportDefault {
 			fmt.Fprintf(buf, `export { default } from "%s";`, importUrl)
 		}
-		err = ctx.storage.Put(ctx.getSavepath(), buf)
+		meta.Signature, err = ctx.putArtifact(ctx.getSavepath(), ctx.Path(), buf.Bytes())
 		if err != nil {
 			ctx.logger.Errorf("storage.put(%s): %v", ctx.getSavepath(), err)
 			err = errors.New("storage: " + err.Error())
 			return
 		}
 		meta.Dts, err = ctx.resolveDTS(entry)
+		if err == nil && meta.Dts == "" && !exportStar {
+			meta.Dts, err = ctx.synthesizeDTS(namedExports, meta.ExportDefault)
+		}
 		return
 	}
 
@@ -359,8 +393,8 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 		buf, recycle := newBuffer()
 		defer recycle()
 		fmt.Fprintf(buf, `import * as cjsm from "%s";`, entrySpecifier)
-		if len(cjsExports) > 0 {
-			fmt.Fprintf(buf, `export const { %s } = cjsm;`, strings.Join(cjsExports, ","))
+		if len(namedExports) > 0 {
+			fmt.Fprintf(buf, `export const { %s } = cjsm;`, strings.Join(namedExports, ","))
 		}
 		buf.WriteString("export default cjsm.default ?? cjsm;")
 		stdin = esbuild.StdinOptions{
@@ -383,6 +417,10 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 			}
 		}
 	}
+	// the script formats(iife/umd) can not import other modules
+	if ctx.format != "" {
+		noBundle = false
+	}
 	esmifyPlugin := esbuild.Plugin{
 		Name: "esmify",
 		Setup: func(build esbuild.PluginBuild) {
@@ -522,6 +560,13 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 
 					// nodejs builtin module
 					if isNodeBuiltinSpecifier(specifier) {
+						// the script formats(iife/umd) can not import the polyfills of node builtin modules
+						if ctx.format != "" {
+							return esbuild.OnResolveResult{
+								Path:      args.Path,
+								Namespace: "browser-exclude",
+							}, nil
+						}
 						externalPath, err := ctx.resolveExternalModule(specifier, args.Kind, withTypeJSON, analyzeMode)
 						if err != nil {
 							return esbuild.OnResolveResult{}, err
@@ -564,10 +609,11 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 					}
 
 					// bundles all dependencies in `bundle` mode, apart from peerDependencies and `?external` flag
+					// the script formats(iife/umd) bundle peerDependencies as well
 					if ctx.bundleMode == BundleDeps && !ctx.args.External.Has(toPackageName(specifier)) && !implicitExternal.Has(specifier) {
 						pkgName := toPackageName(specifier)
 						_, ok := pkgJson.PeerDependencies[pkgName]
-						if !ok {
+						if !ok || ctx.format != "" {
 							return esbuild.OnResolveResult{}, nil
 						}
 					}
@@ -656,7 +702,7 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 										}
 										if match {
 											asExport = path.Join(pkgJson.Name, stripModuleExt(exportName))
-											if asExport != entrySpecifier && asExport != entrySpecifier+"/index" {
+											if asExport != entrySpecifier && asExport != entrySpecifier+"/index" && ctx.format == "" {
 												externalPath, err := ctx.resolveExternalModule(asExport, args.Kind, withTypeJSON, analyzeMode)
 												if err != nil {
 													return esbuild.OnResolveResult{}, err
@@ -683,7 +729,7 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 							filename = path.Join(ctx.wd, "node_modules", ctx.esmPath.PkgName, modulePath)
 
 							// split the module that includes `export * from "external"` statement
-							if entry.module && len(pkgJson.Dependencies)+len(pkgJson.PeerDependencies) > 0 && args.Kind == esbuild.ResolveJSImportStatement {
+							if entry.module && len(pkgJson.Dependencies)+len(pkgJson.PeerDependencies) > 0 && args.Kind == esbuild.ResolveJSImportStatement && ctx.format == "" {
 								fi, err := os.Lstat(filename)
 								if err == nil && fi.Size() < 512 {
 									data, err := os.ReadFile(filename)
@@ -737,7 +783,7 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 							// - it's the entry point
 							// - it's not a dynamic import and the `?bundle=false` flag is not present
 							// - it's not in the `splitting` list
-							if modulePath == entry.main || (asExport != "" && asExport == entrySpecifier) || (args.Kind != esbuild.ResolveJSDynamicImport && !noBundle) {
+							if modulePath == entry.main || (asExport != "" && asExport == entrySpecifier) || ((args.Kind != esbuild.ResolveJSDynamicImport || ctx.format != "") && !noBundle) {
 								if existsFile(filename) {
 									pkgDir := path.Join(ctx.wd, "node_modules", ctx.esmPath.PkgName)
 									short := strings.TrimPrefix(filename, pkgDir)[1:]
@@ -937,6 +983,25 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 				},
 			)
 
+			// binary assets loader for the `?assets=external` mode, the assets that are larger
+			// than the inline limit are emitted as separate files instead of data URLs
+			if ctx.args.ExternalAssets && ctx.format == "" {
+				build.OnLoad(
+					esbuild.OnLoadOptions{Filter: binaryAssetsFilter, Namespace: "file"},
+					func(args esbuild.OnLoadArgs) (esbuild.OnLoadResult, error) {
+						data, err := os.ReadFile(args.Path)
+						if err != nil {
+							return esbuild.OnLoadResult{}, err
+						}
+						contents := string(data)
+						if len(data) <= ctx.args.AssetsInlineLimit {
+							return esbuild.OnLoadResult{Contents: &contents, Loader: esbuild.LoaderDataURL}, nil
+						}
+						return esbuild.OnLoadResult{Contents: &contents, Loader: esbuild.LoaderFile}, nil
+					},
+				)
+			}
+
 			// vue SFC loader
 			build.OnLoad(
 				esbuild.OnLoadOptions{Filter: ".*", Namespace: "vue"},
@@ -1017,6 +1082,13 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 		}
 		define["global"] = "globalThis"
 	}
+	// the package defaults of the config, and the `?define` query
+	for key, value := range config.Define[ctx.esmPath.PkgName] {
+		define[key] = value
+	}
+	for key, value := range ctx.args.Define {
+		define[key] = value
+	}
 	conditions := ctx.args.Conditions
 	if ctx.dev {
 		conditions = append(conditions, "development")
@@ -1026,11 +1098,13 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 	} else if ctx.target == "node" {
 		conditions = append(conditions, "node")
 	}
+	target, engines := getBuildTarget(ctx.target)
 	options := esbuild.BuildOptions{
 		AbsWorkingDir:     ctx.wd,
 		PreserveSymlinks:  true,
 		Format:            esbuild.FormatESModule,
-		Target:            targets[ctx.target],
+		Target:            target,
+		Engines:           engines,
 		Platform:          esbuild.PlatformBrowser,
 		Define:            define,
 		Supported:         supported,
@@ -1047,6 +1121,7 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 		Plugins:           []esbuild.Plugin{esmifyPlugin},
 		Outdir:            "/esbuild",
 		Write:             false,
+		Metafile:          ctx.analyzeBundle, // for the bundle analysis and the SBOM, see `buildAnalysis`
 	}
 	if entryPoint != "" {
 		options.EntryPoints = []string{entryPoint}
@@ -1056,9 +1131,18 @@ func (ctx *BuildContext) buildModule(analyzeMode bool) (meta *BuildMeta, include
 	if ctx.target == "node" {
 		options.Platform = esbuild.PlatformNode
 	}
+	if ctx.format != "" {
+		// the commonjs output will be wrapped as a classic script(iife/umd), see `scriptWrapper`
+		options.Format = esbuild.FormatCommonJS
+	}
 	if config.SourceMap {
 		options.Sourcemap = esbuild.SourceMapExternal
 	}
+	if ctx.args.ExternalAssets && ctx.format == "" {
+		// the assets are saved next to the module, e.g. "/pkg@1.0.0/es2022/logo-XXXXXXXX.png"
+		options.AssetNames = "[name]-[hash]"
+		options.PublicPath = path.Dir(ctx.Path()) + "/"
+	}
 	for _, pkgName := range []string{"preact", "react", "solid-js", "mono-jsx", "vue", "hono"} {
 		_, ok1 := ctx.pkgJson.Dependencies[pkgName]
 		_, ok2 := ctx.pkgJson.PeerDependencies[pkgName]
@@ -1122,15 +1206,52 @@ REBUILD:
 		return
 	}
 
+	ctx.metafile = res.Metafile
+
 	for _, w := range res.Warnings {
 		ctx.logger.Warnf("esbuild(%s): %s", ctx.Path(), w.Text)
 	}
 
+	// the script formats(iife/umd) can not import external modules
+	if ctx.format != "" {
+		if len(ctx.esmImports) > 0 {
+			err = fmt.Errorf("could not bundle \"%s\" in the %s format", ctx.esmImports[0][0], ctx.format)
+			return
+		}
+		for _, r := range ctx.cjsRequires {
+			if !strings.HasPrefix(r[0], "npm:") {
+				err = fmt.Errorf("could not bundle \"%s\" in the %s format", r[0], ctx.format)
+				return
+			}
+		}
+	}
+
 	imports := set.New[string]()
 
+	// save the external assets
+	var assetUrls []string
+	for _, file := range res.OutputFiles {
+		if regexpExternalAsset.MatchString(file.Path) {
+			name := path.Base(file.Path)
+			savePath := path.Join(path.Dir(ctx.getSavepath()), name)
+			err = ctx.storage.Put(savePath, bytes.NewReader(file.Contents))
+			if err != nil {
+				ctx.logger.Errorf("storage.put(%s): %v", savePath, err)
+				err = errors.New("storage: " + err.Error())
+				return
+			}
+			assetUrls = append(assetUrls, path.Dir(ctx.Path())+"/"+name)
+		}
+	}
+
 	for _, file := range res.OutputFiles {
 		if strings.HasSuffix(file.Path, ".js") {
 			jsContent := file.Contents
+			// resolve the asset urls by the module url, since they are used in the document mostly
+			for _, assetUrl := range assetUrls {
+				quoted := `"` + assetUrl + `"`
+				jsContent = bytes.ReplaceAll(jsContent, []byte(quoted), []byte("new URL("+quoted+",import.meta.url).href"))
+			}
 			header, recycle := newBuffer()
 			defer recycle()
 			header.WriteString("/* esm.sh - ")
@@ -1138,9 +1259,13 @@ REBUILD:
 				header.WriteString("github:")
 			} else if ctx.esmPath.PrPrefix {
 				header.WriteString("pkg.pr.new/")
+			} else if ctx.esmPath.GitHost != "" {
+				header.WriteString("git:" + ctx.esmPath.GitHost + "/")
+			} else if ctx.esmPath.TgzPrefix {
+				header.WriteString("tgz:")
 			}
 			header.WriteString(ctx.esmPath.PkgName)
-			if ctx.esmPath.GhPrefix {
+			if ctx.esmPath.GhPrefix || ctx.esmPath.GitHost != "" {
 				header.WriteByte('#')
 			} else {
 				header.WriteByte('@')
@@ -1152,6 +1277,14 @@ REBUILD:
 			}
 			header.WriteString(" */\n")
 
+			var scriptFooter string
+			if ctx.format != "" {
+				var scriptHeader string
+				scriptHeader, scriptFooter = scriptWrapper(ctx.format, ctx.args.GlobalName)
+				header.WriteString(scriptHeader)
+				header.WriteByte('\n')
+			}
+
 			// remove shebang
 			if bytes.HasPrefix(jsContent, []byte("#!/")) {
 				jsContent = jsContent[bytes.IndexByte(jsContent, '\n')+1:]
@@ -1165,7 +1298,10 @@ REBUILD:
 					ids.Add(string(r))
 				}
 				if ids.Has("__Process$") {
-					if ctx.args.External.Has("node:process") {
+					if ctx.format != "" {
+						header.WriteString(`var __Process$ = globalThis.process || { env: {}, browser: true };`)
+						header.WriteByte('\n')
+					} else if ctx.args.External.Has("node:process") {
 						header.WriteString(`import __Process$ from "node:process";`)
 						header.WriteByte('\n')
 					} else if ctx.isBrowserTarget() {
@@ -1198,7 +1334,10 @@ REBUILD:
 					}
 				}
 				if ids.Has("__Buffer$") {
-					if ctx.args.External.Has("node:buffer") {
+					if ctx.format != "" {
+						header.WriteString(`var __Buffer$ = globalThis.Buffer;`)
+						header.WriteByte('\n')
+					} else if ctx.args.External.Has("node:buffer") {
 						header.WriteString(`import { Buffer as __Buffer$ } from "node:buffer";`)
 						header.WriteByte('\n')
 					} else if ctx.isBrowserTarget() {
@@ -1306,7 +1445,7 @@ REBUILD:
 								if err == nil {
 									entry := b.resolveEntry(dep)
 									if !entry.module {
-										ret, cjsNamedExports, _, e := b.lexer(&entry)
+										ret, cjsNamedExports, _, _, e := b.lexer(&entry)
 										if e == nil && ret.CJS && slices.Contains(cjsNamedExports, "__esModule") {
 											isEsModule[i] = true
 										}
@@ -1350,13 +1489,15 @@ REBUILD:
 			finalJS.Write(jsContent)
 
 			// check if the package is deprecated
-			if !ctx.esmPath.GhPrefix && !ctx.esmPath.PrPrefix {
+			if !ctx.esmPath.GhPrefix && !ctx.esmPath.PrPrefix && ctx.esmPath.GitHost == "" && !ctx.esmPath.TgzPrefix {
 				deprecated, _ := ctx.npmrc.isDeprecated(ctx.pkgJson.Name, ctx.pkgJson.Version)
 				if deprecated != "" {
 					fmt.Fprintf(finalJS, `console.warn("%%c[esm.sh]%%c %%cdeprecated%%c %s@%s: " + %s, "color:grey", "", "color:red", "");%s`, ctx.esmPath.PkgName, ctx.esmPath.PkgVersion, utils.MustEncodeJSON(deprecated), "\n")
 				}
 			}
 
+			finalJS.WriteString(scriptFooter)
+
 			// add sourcemap Url
 			if config.SourceMap && !dropSourceMap {
 				finalJS.WriteString("//# sourceMappingURL=")
@@ -1364,7 +1505,7 @@ REBUILD:
 				finalJS.WriteString(".map")
 			}
 
-			err = ctx.storage.Put(ctx.getSavepath(), finalJS)
+			meta.Signature, err = ctx.putArtifact(ctx.getSavepath(), ctx.Path(), finalJS.Bytes())
 			if err != nil {
 				ctx.logger.Errorf("storage.put(%s): %v", ctx.getSavepath(), err)
 				err = errors.New("storage: " + err.Error())
@@ -1377,7 +1518,8 @@ REBUILD:
 		if strings.HasSuffix(file.Path, ".css") {
 			savePath := ctx.getSavepath()
 			savePath = strings.TrimSuffix(savePath, path.Ext(savePath)) + ".css"
-			err = ctx.storage.Put(savePath, bytes.NewReader(file.Contents))
+			cssPath := strings.TrimSuffix(ctx.Path(), path.Ext(ctx.Path())) + ".css"
+			meta.CSSSignature, err = ctx.putArtifact(savePath, cssPath, file.Contents)
 			if err != nil {
 				ctx.logger.Errorf("storage.put(%s): %v", savePath, err)
 				err = errors.New("storage: " + err.Error())
@@ -1417,8 +1559,24 @@ REBUILD:
 	}
 	sort.Strings(meta.Imports)
 
-	// resolve types(dts)
+	// save the SBOM of the bundled packages in the rebuild of `buildAnalysis`, see the `?sbom` query
+	if ctx.analyzeBundle {
+		var metafile esbuildMetafile
+		err = json.Unmarshal([]byte(res.Metafile), &metafile)
+		if err != nil {
+			return
+		}
+		err = ctx.saveSBOM(&metafile, meta.Imports)
+		if err != nil {
+			return
+		}
+	}
+
+	// resolve types(dts), or synthesize the `any`-typed declarations for the untyped module
 	meta.Dts, err = ctx.resolveDTS(entry)
+	if err == nil && meta.Dts == "" && !exportStar {
+		meta.Dts, err = ctx.synthesizeDTS(namedExports, meta.ExportDefault)
+	}
 	return
 }
 
@@ -1430,6 +1588,11 @@ func (ctx *BuildContext) buildTypes() (ret *BuildMeta, err error) {
 		return
 	}
 
+	// regenerate the synthesized declarations of the untyped module, e.g. the file was purged from the storage
+	if subModuleName, ok := strings.CutSuffix(ctx.esmPath.SubPath, ".synthesized.d.ts"); ok {
+		return ctx.buildSynthesizedTypes(subModuleName)
+	}
+
 	var dts string
 	if endsWith(ctx.esmPath.SubPath, ".ts", ".mts", ".tsx", ".cts") {
 		dts = "./" + ctx.esmPath.SubPath
@@ -1452,6 +1615,67 @@ func (ctx *BuildContext) buildTypes() (ret *BuildMeta, err error) {
 	return
 }
 
+// buildSynthesizedTypes synthesizes the `any`-typed declarations of the untyped (sub)module, see `synthesizeDTS`.
+func (ctx *BuildContext) buildSynthesizedTypes(subModuleName string) (ret *BuildMeta, err error) {
+	if subModuleName == "index" {
+		subModuleName = ""
+	}
+	ctx.esmPath.SubModuleName = subModuleName
+	ctx.esmPath.SubPath = subModuleName
+
+	entry := ctx.resolveEntry(ctx.esmPath)
+	if entry.types != "" {
+		err = errors.New("types not found")
+		return
+	}
+	meta, namedExports, exportStar, cjsReexport, err := ctx.lexer(&entry)
+	if err != nil {
+		return
+	}
+	if cjsReexport != "" {
+		dep, _, e := ctx.lookupDep(cjsReexport, false)
+		if e != nil {
+			err = e
+			return
+		}
+		b := &BuildContext{
+			npmrc:       ctx.npmrc,
+			logger:      ctx.logger,
+			db:          ctx.db,
+			storage:     ctx.storage,
+			esmPath:     dep,
+			args:        ctx.args,
+			externalAll: ctx.externalAll,
+			target:      ctx.target,
+		}
+		err = b.install()
+		if err != nil {
+			return
+		}
+		entry = b.resolveEntry(dep)
+		meta, namedExports, exportStar, _, err = b.lexer(&entry)
+		if err != nil {
+			return
+		}
+	}
+	if exportStar {
+		err = errors.New("types not found")
+		return
+	}
+
+	ctx.status = "build"
+	dts, err := ctx.synthesizeDTS(namedExports, meta.ExportDefault)
+	if err != nil {
+		return
+	}
+	if dts == "" {
+		err = errors.New("types not found")
+		return
+	}
+	ret = &BuildMeta{Dts: dts}
+	return
+}
+
 func (ctx *BuildContext) install() (err error) {
 	if ctx.wd == "" || ctx.pkgJson == nil {
 		p, err := ctx.npmrc.installPackage(ctx.esmPath.Package())
@@ -1459,7 +1683,7 @@ func (ctx *BuildContext) install() (err error) {
 			return err
 		}
 
-		if ctx.esmPath.GhPrefix || ctx.esmPath.PrPrefix {
+		if ctx.esmPath.GhPrefix || ctx.esmPath.PrPrefix || ctx.esmPath.GitHost != "" || ctx.esmPath.TgzPrefix {
 			// if the name in package.json is not the same as the repository name
 			if p.Name != ctx.esmPath.PkgName {
 				p.PkgName = p.Name
@@ -1519,3 +1743,37 @@ func (ctx *BuildContext) install() (err error) {
 	}
 	return
 }
+
+// scriptWrapper returns the header and footer to wrap the commonjs output as a classic script(iife/umd)
+// that exposes the module exports to the global variable `globalName`, e.g. "MyLib" or "MyLib.utils".
+func scriptWrapper(format string, globalName string) (header string, footer string) {
+	const globalObject = `typeof globalThis<"u"?globalThis:typeof self<"u"?self:this`
+	const cjsModule = `var module={exports:{}},exports=module.exports;`
+	assignee := ""
+	if globalName != "" {
+		names := strings.Split(globalName, ".")
+		assignee = "g"
+		for _, name := range names[:len(names)-1] {
+			assignee = fmt.Sprintf("(%s.%s=%s.%s||{})", assignee, name, assignee, name)
+		}
+		assignee += "." + names[len(names)-1]
+	}
+	if format == "umd" {
+		header = `(function(g,f){if(typeof exports=="object"&&typeof module<"u")module.exports=f();else if(typeof define=="function"&&define.amd)define([],f);`
+		if assignee != "" {
+			header += "else " + assignee + "=f()"
+		} else {
+			header += "else f()"
+		}
+		header += "})(" + globalObject + ",function(){" + cjsModule
+		footer = "\nreturn module.exports});\n"
+		return
+	}
+	header = "(function(g){" + cjsModule
+	footer = "\n"
+	if assignee != "" {
+		footer += assignee + "=module.exports;"
+	}
+	footer += "})(" + globalObject + ");\n"
+	return
+}
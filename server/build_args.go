@@ -1,25 +1,56 @@
 package server
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"path"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/esm-dev/esm.sh/internal/npm"
+	"github.com/goccy/go-json"
 	"github.com/ije/gox/set"
 	"github.com/ije/gox/utils"
 )
 
+const (
+	maxDefineCount      = 32
+	maxDefineValueBytes = 256
+)
+
+// the globals that can not be replaced by the `?define` query, they are used by the runtime polyfills
+var reservedDefineGlobals = set.NewReadOnly(
+	"Buffer",
+	"__dirname",
+	"__filename",
+	"clearImmediate",
+	"document",
+	"exports",
+	"global",
+	"globalThis",
+	"import",
+	"module",
+	"require",
+	"self",
+	"setImmediate",
+	"window",
+)
+
 type BuildArgs struct {
 	Alias             map[string]string
 	Deps              map[string]string
 	External          set.ReadOnlySet[string]
 	Conditions        []string
+	Define            map[string]string
 	KeepNames         bool
 	IgnoreAnnotations bool
 	ExternalRequire   bool
+	GlobalName        string
+	ExternalAssets    bool
+	AssetsInlineLimit int
 }
 
 func decodeBuildArgs(argsString string) (args BuildArgs, err error) {
@@ -48,6 +79,21 @@ func decodeBuildArgs(argsString string) (args BuildArgs, err error) {
 				args.External = *set.NewReadOnly(strings.Split(p[1:], ",")...)
 			} else if strings.HasPrefix(p, "c") {
 				args.Conditions = append(args.Conditions, strings.Split(p[1:], ",")...)
+			} else if strings.HasPrefix(p, "g") {
+				args.GlobalName = p[1:]
+			} else if strings.HasPrefix(p, "f") {
+				args.ExternalAssets = true
+				if p[1:] != "" {
+					args.AssetsInlineLimit, err = strconv.Atoi(p[1:])
+					if err != nil {
+						return
+					}
+				}
+			} else if strings.HasPrefix(p, "D") {
+				args.Define, err = parseDefine(p[1:])
+				if err != nil {
+					return
+				}
 			} else {
 				switch p {
 				case "r":
@@ -116,6 +162,19 @@ func encodeBuildArgs(args BuildArgs, isDts bool) string {
 		if args.IgnoreAnnotations {
 			lines = append(lines, "i")
 		}
+		if args.GlobalName != "" {
+			lines = append(lines, "g"+args.GlobalName)
+		}
+		if len(args.Define) > 0 {
+			lines = append(lines, "D"+formatDefine(args.Define))
+		}
+		if args.ExternalAssets {
+			if args.AssetsInlineLimit > 0 {
+				lines = append(lines, "f"+strconv.Itoa(args.AssetsInlineLimit))
+			} else {
+				lines = append(lines, "f")
+			}
+		}
 	}
 	if len(lines) > 0 {
 		return btoaUrl(strings.Join(lines, "\n"))
@@ -123,6 +182,77 @@ func encodeBuildArgs(args BuildArgs, isDts bool) string {
 	return ""
 }
 
+// parseDefine parses the `?define` query in format of `KEY:JSON,...`, e.g. `__DEV__:false,process.env.API_URL:"/api"`.
+// The keys must be global names, and the values must be JSON strings, numbers, booleans or null.
+func parseDefine(s string) (define map[string]string, err error) {
+	define = map[string]string{}
+	for s != "" {
+		key, rest := utils.SplitByFirstByte(s, ':')
+		key = strings.TrimSpace(key)
+		if !isDefineKeyAllowed(key) {
+			return nil, fmt.Errorf("invalid define key '%s'", key)
+		}
+		dec := json.NewDecoder(strings.NewReader(rest))
+		dec.UseNumber()
+		var value any
+		if dec.Decode(&value) != nil {
+			return nil, fmt.Errorf("invalid define value of '%s'", key)
+		}
+		switch value.(type) {
+		case string, json.Number, bool, nil:
+		default:
+			return nil, fmt.Errorf("invalid define value of '%s'", key)
+		}
+		raw := bytes.TrimSpace([]byte(rest[:dec.InputOffset()]))
+		if len(raw) > maxDefineValueBytes {
+			return nil, fmt.Errorf("define value of '%s' is too long", key)
+		}
+		// use the compact form of strings
+		if str, ok := value.(string); ok {
+			raw, _ = json.Marshal(str)
+		}
+		define[key] = string(raw)
+		if len(define) > maxDefineCount {
+			return nil, errors.New("too many defines")
+		}
+		s = strings.TrimSpace(rest[dec.InputOffset():])
+		if s != "" {
+			if s[0] != ',' {
+				return nil, fmt.Errorf("invalid define value of '%s'", key)
+			}
+			s = s[1:]
+		}
+	}
+	return
+}
+
+// formatDefine formats the define map to the sorted `KEY:JSON,...` string.
+func formatDefine(define map[string]string) string {
+	ss := make(sort.StringSlice, 0, len(define))
+	for key, value := range define {
+		ss = append(ss, key+":"+value)
+	}
+	ss.Sort()
+	return strings.Join(ss, ",")
+}
+
+// isDefineKeyAllowed checks if the given key can be replaced by the `?define` query.
+// The `process` global is only allowed with the `process.env.` prefix, except the `NODE_ENV`
+// that is controlled by the `?dev` query.
+func isDefineKeyAllowed(key string) bool {
+	if !isGlobalName(key) {
+		return false
+	}
+	root, _ := utils.SplitByFirstByte(key, '.')
+	if reservedDefineGlobals.Has(root) {
+		return false
+	}
+	if root == "process" {
+		return strings.HasPrefix(key, "process.env.") && strings.Count(key, ".") == 2 && key != "process.env.NODE_ENV"
+	}
+	return true
+}
+
 // resolveBuildArgs resolves `alias`, `deps`, `external` of the build args
 func resolveBuildArgs(npmrc *NpmRC, installDir string, args *BuildArgs, esm EsmPath) error {
 	if len(args.Alias) > 0 || len(args.Deps) > 0 || args.External.Len() > 0 {
@@ -136,7 +266,7 @@ func resolveBuildArgs(npmrc *NpmRC, installDir string, args *BuildArgs, esm EsmP
 				if err == nil {
 					p = raw.ToNpmPackage()
 				}
-			} else if esm.GhPrefix || esm.PrPrefix {
+			} else if esm.GhPrefix || esm.PrPrefix || esm.GitHost != "" || esm.TgzPrefix {
 				p, err = npmrc.installPackage(esm.Package())
 			} else {
 				p, err = npmrc.getPackageInfo(esm.PkgName, esm.PkgVersion)
@@ -254,7 +384,7 @@ func walkDeps(npmrc *NpmRC, installDir string, pkg npm.Package, mark *set.Set[st
 		if err == nil {
 			p = raw.ToNpmPackage()
 		}
-	} else if pkg.Github || pkg.PkgPrNew {
+	} else if pkg.Github || pkg.PkgPrNew || pkg.GitHost != "" || pkg.Tgz {
 		p, err = npmrc.installPackage(pkg)
 	} else {
 		p, err = npmrc.getPackageInfo(pkg.Name, pkg.Version)
@@ -21,6 +21,10 @@ func TestEncodeBuildArgs(t *testing.T) {
 			ExternalRequire:   true,
 			KeepNames:         true,
 			IgnoreAnnotations: true,
+			GlobalName:        "MyLib",
+			Define:            map[string]string{"__DEV__": "false", "process.env.API_URL": `"/api,v1"`},
+			ExternalAssets:    true,
+			AssetsInlineLimit: 4096,
 		},
 		false,
 	)
@@ -49,4 +53,43 @@ func TestEncodeBuildArgs(t *testing.T) {
 	if !args.IgnoreAnnotations {
 		t.Fatal("ignoreAnnotations should be true")
 	}
+	if args.GlobalName != "MyLib" {
+		t.Fatal("invalid globalName")
+	}
+	if len(args.Define) != 2 || args.Define["__DEV__"] != "false" || args.Define["process.env.API_URL"] != `"/api,v1"` {
+		t.Fatal("invalid define")
+	}
+	if !args.ExternalAssets || args.AssetsInlineLimit != 4096 {
+		t.Fatal("invalid externalAssets")
+	}
+}
+
+func TestParseDefine(t *testing.T) {
+	define, err := parseDefine(`__DEV__:false, process.env.API_URL:"/api,v1",FEATURE.flag: 1e3,x:null`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(define) != 4 || define["__DEV__"] != "false" || define["process.env.API_URL"] != `"/api,v1"` || define["FEATURE.flag"] != "1e3" || define["x"] != "null" {
+		t.Fatalf("invalid define: %v", define)
+	}
+	if s := formatDefine(define); s != `FEATURE.flag:1e3,__DEV__:false,process.env.API_URL:"/api,v1",x:null` {
+		t.Fatalf("invalid formatted define: %s", s)
+	}
+	for _, s := range []string{
+		`window:1`,
+		`globalThis.foo:1`,
+		`process:1`,
+		`process.env.NODE_ENV:"development"`,
+		`require.resolve:1`,
+		`foo-bar:1`,
+		`foo:{"a":1}`,
+		`foo:[1]`,
+		`foo:bar`,
+		`foo:1 2`,
+		`foo`,
+	} {
+		if _, err := parseDefine(s); err == nil {
+			t.Fatalf("parseDefine(%s) should return an error", s)
+		}
+	}
 }
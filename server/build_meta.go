@@ -15,6 +15,8 @@ type BuildMeta struct {
 	ExportDefault bool
 	CSSEntry      string
 	Dts           string
+	Signature     string
+	CSSSignature  string
 	Imports       []string
 }
 
@@ -43,6 +45,16 @@ func encodeBuildMeta(meta *BuildMeta) []byte {
 		buf.WriteString(meta.Dts)
 		buf.WriteByte('\n')
 	}
+	if meta.Signature != "" {
+		buf.Write([]byte{'s', ':'})
+		buf.WriteString(meta.Signature)
+		buf.WriteByte('\n')
+	}
+	if meta.CSSSignature != "" {
+		buf.Write([]byte{'S', ':'})
+		buf.WriteString(meta.CSSSignature)
+		buf.WriteByte('\n')
+	}
 	if len(meta.Imports) > 0 {
 		for _, path := range meta.Imports {
 			buf.Write([]byte{'i', ':'})
@@ -87,6 +99,10 @@ func decodeBuildMeta(data []byte) (*BuildMeta, error) {
 			if !endsWith(meta.Dts, ".ts", ".mts", ".cts") {
 				return nil, errors.New("invalid dts path")
 			}
+		case ll > 2 && line[0] == 's' && line[1] == ':':
+			meta.Signature = string(line[2:])
+		case ll > 2 && line[0] == 'S' && line[1] == ':':
+			meta.CSSSignature = string(line[2:])
 		case ll > 2 && line[0] == 'i' && line[1] == ':':
 			importSepcifier := string(line[2:])
 			if !strings.HasSuffix(importSepcifier, ".mjs") {
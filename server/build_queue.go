@@ -49,7 +49,7 @@ func (q *BuildQueue) Add(ctx *BuildContext) chan BuildOutput {
 
 	ch := make(chan BuildOutput, 1)
 
-	task, ok := q.tasks[ctx.Path()]
+	task, ok := q.tasks[getTaskKey(ctx, ctx.Path())]
 	if ok {
 		task.waitChans = append(task.waitChans, ch)
 		return ch
@@ -63,7 +63,7 @@ func (q *BuildQueue) Add(ctx *BuildContext) chan BuildOutput {
 	ctx.status = "pending"
 
 	task.el = q.queue.PushBack(task)
-	q.tasks[ctx.Path()] = task
+	q.tasks[getTaskKey(ctx, ctx.Path())] = task
 
 	go q.schedule()
 
@@ -114,10 +114,10 @@ func (q *BuildQueue) run(task *BuildTask) {
 
 	q.lock.Lock()
 	q.queue.Remove(task.el)
-	delete(q.tasks, task.ctx.Path())
+	delete(q.tasks, getTaskKey(task.ctx, task.ctx.Path()))
 	if task.ctx.rawPath != "" {
 		// the `Build` function may have changed the path
-		delete(q.tasks, task.ctx.rawPath)
+		delete(q.tasks, getTaskKey(task.ctx, task.ctx.rawPath))
 	}
 	q.chann += 1
 	q.lock.Unlock()
@@ -146,3 +146,11 @@ func (q *BuildQueue) run(task *BuildTask) {
 		}
 	}
 }
+
+// getTaskKey returns the key of the build task, the bundle analysis is a separate task of the same build path.
+func getTaskKey(ctx *BuildContext, path string) string {
+	if ctx.analyzeBundle {
+		return "analyze:" + path
+	}
+	return path
+}
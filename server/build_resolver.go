@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path"
 	"slices"
@@ -241,7 +242,7 @@ func (ctx *BuildContext) resolveEntry(esm EsmPath) (entry BuildEntry) {
 		}
 
 		// lookup entry main from `src` directory
-		if entry.main == "" && esm.GhPrefix {
+		if entry.main == "" && (esm.GhPrefix || esm.GitHost != "") {
 			for _, ext := range []string{"mts", "ts", "mjs", "js", "tsx", "cts", "cjs"} {
 				isModule := ext != "cjs" && ext != "cts"
 				if filename := "./src/" + subModuleName + "/index." + ext; ctx.existsPkgFile(filename) {
@@ -343,7 +344,7 @@ func (ctx *BuildContext) resolveEntry(esm EsmPath) (entry BuildEntry) {
 		}
 
 		// lookup entry main from `src` directory
-		if entry.main == "" && esm.GhPrefix {
+		if entry.main == "" && (esm.GhPrefix || esm.GitHost != "") {
 			for _, ext := range []string{"mts", "ts", "mjs", "js", "tsx", "cts", "cjs"} {
 				filename := "./src/index." + ext
 				if ctx.existsPkgFile(filename) {
@@ -487,7 +488,7 @@ func (ctx *BuildContext) finalizeBuildEntry(entry *BuildEntry) {
 			}
 		} else if !entry.module && endsWith(entry.main, ".js", ".ts") {
 			// check if the cjs entry is an ESM
-			isESM, _, err := validateJSFile(path.Join(ctx.wd, "node_modules", ctx.esmPath.PkgName, entry.main))
+			isESM, _, _, err := validateJSFile(path.Join(ctx.wd, "node_modules", ctx.esmPath.PkgName, entry.main))
 			if err == nil {
 				entry.module = isESM
 			}
@@ -708,6 +709,8 @@ func (ctx *BuildContext) resolveExternalModule(specifier string, kind esbuild.Re
 			PkgVersion: pkgJson.Version,
 			GhPrefix:   ctx.esmPath.GhPrefix,
 			PrPrefix:   ctx.esmPath.PrPrefix,
+			GitHost:    ctx.esmPath.GitHost,
+			TgzPrefix:  ctx.esmPath.TgzPrefix,
 		}, ctx.getBuildArgsPrefix(false), ctx.externalAll)
 		return
 	}
@@ -718,6 +721,8 @@ func (ctx *BuildContext) resolveExternalModule(specifier string, kind esbuild.Re
 		subModule := EsmPath{
 			GhPrefix:      ctx.esmPath.GhPrefix,
 			PrPrefix:      ctx.esmPath.PrPrefix,
+			GitHost:       ctx.esmPath.GitHost,
+			TgzPrefix:     ctx.esmPath.TgzPrefix,
 			PkgName:       ctx.esmPath.PkgName,
 			PkgVersion:    ctx.esmPath.PkgVersion,
 			SubPath:       subPath,
@@ -780,6 +785,7 @@ func (ctx *BuildContext) resolveExternalModule(specifier string, kind esbuild.Re
 	// e.g. "@mark/html": "npm:@jsr/mark__html@^1.0.0"
 	// e.g. "tslib": "git+https://github.com/microsoft/tslib.git#v2.3.0"
 	// e.g. "react": "github:facebook/react#v18.2.0"
+	// e.g. "lib": "gitlab:owner/lib#v1.0.0"
 	p, err := npm.ResolveDependencyVersion(pkgVersion)
 	if err != nil {
 		resolvedPath = fmt.Sprintf("/error.js?type=%s&name=%s&importer=%s", strings.ReplaceAll(err.Error(), " ", "-"), pkgName, ctx.esmPath.Specifier())
@@ -788,14 +794,23 @@ func (ctx *BuildContext) resolveExternalModule(specifier string, kind esbuild.Re
 	if p.Name != "" {
 		dep.GhPrefix = p.Github
 		dep.PrPrefix = p.PkgPrNew
+		dep.GitHost = p.GitHost
 		dep.PkgName = p.Name
 		dep.PkgVersion = p.Version
 	}
 
 	// fetch the latest tag as the version of the repository
-	if dep.GhPrefix && dep.PkgVersion == "" {
+	if (dep.GhPrefix || dep.GitHost != "") && dep.PkgVersion == "" {
 		var refs []GitRef
-		refs, err = listGhRepoRefs(fmt.Sprintf("https://github.com/%s", dep.PkgName))
+		if dep.GitHost != "" {
+			gitHost, ok := ctx.npmrc.getGitHost(dep.GitHost)
+			if !ok {
+				return specifier, fmt.Errorf("git host '%s' is not allowed", dep.GitHost)
+			}
+			refs, err = gitHost.listRepoRefs(dep.PkgName)
+		} else {
+			refs, err = listGhRepoRefs(fmt.Sprintf("https://github.com/%s", dep.PkgName), ctx.npmrc.getGitToken("github.com"))
+		}
 		if err != nil {
 			return
 		}
@@ -837,6 +852,7 @@ func (ctx *BuildContext) resolveExternalModule(specifier string, kind esbuild.Re
 		Deps:       ctx.args.Deps,
 		External:   ctx.args.External,
 		Conditions: ctx.args.Conditions,
+		Define:     ctx.args.Define,
 	}
 	err = resolveBuildArgs(ctx.npmrc, ctx.wd, &args, dep)
 	if err != nil {
@@ -844,7 +860,7 @@ func (ctx *BuildContext) resolveExternalModule(specifier string, kind esbuild.Re
 	}
 
 	var exactVersion bool
-	if dep.GhPrefix {
+	if dep.GhPrefix || dep.GitHost != "" {
 		exactVersion = isCommitish(dep.PkgVersion) || npm.IsExactVersion(strings.TrimPrefix(dep.PkgVersion, "v"))
 	} else if dep.PrPrefix {
 		exactVersion = true
@@ -905,6 +921,9 @@ func (ctx *BuildContext) resolveExternalModule(specifier string, kind esbuild.Re
 		conditions.Sort()
 		params = append(params, "conditions="+strings.Join(conditions, ","))
 	}
+	if len(args.Define) > 0 {
+		params = append(params, "define="+url.QueryEscape(formatDefine(args.Define)))
+	}
 	if dep.SubModuleName != "" && strings.HasSuffix(dep.SubModuleName, ".json") {
 		params = append(params, "module")
 	} else {
@@ -1040,7 +1059,11 @@ func (ctx *BuildContext) isDenoTarget() bool {
 }
 
 func (ctx *BuildContext) isBrowserTarget() bool {
-	return strings.HasPrefix(ctx.target, "es")
+	if strings.HasPrefix(ctx.target, "es") {
+		return true
+	}
+	_, ok := parseEngineTarget(ctx.target)
+	return ok
 }
 
 func (ctx *BuildContext) existsPkgFile(fp ...string) bool {
@@ -1115,7 +1138,9 @@ lookup:
 	return
 }
 
-func (ctx *BuildContext) lexer(entry *BuildEntry) (ret *BuildMeta, cjsExports []string, cjsReexport string, err error) {
+// lexer returns the build meta and the export names of the entry module, the `exportStar` is true if the ES module
+// re-exports other modules with `export * from "..."` that the names are unknown.
+func (ctx *BuildContext) lexer(entry *BuildEntry) (ret *BuildMeta, exports []string, exportStar bool, cjsReexport string, err error) {
 	if entry.main != "" && entry.module {
 		if strings.HasSuffix(entry.main, ".vue") || strings.HasSuffix(entry.main, ".svelte") {
 			ret = &BuildMeta{
@@ -1126,7 +1151,7 @@ func (ctx *BuildContext) lexer(entry *BuildEntry) (ret *BuildMeta, cjsExports []
 
 		var isESM bool
 		var namedExports []string
-		isESM, namedExports, err = validateJSFile(path.Join(ctx.wd, "node_modules", ctx.esmPath.PkgName, entry.main))
+		isESM, namedExports, exportStar, err = validateJSFile(path.Join(ctx.wd, "node_modules", ctx.esmPath.PkgName, entry.main))
 		if err != nil {
 			return
 		}
@@ -1134,6 +1159,7 @@ func (ctx *BuildContext) lexer(entry *BuildEntry) (ret *BuildMeta, cjsExports []
 			ret = &BuildMeta{
 				ExportDefault: slices.Contains(namedExports, "default"),
 			}
+			exports = namedExports
 			return
 		}
 
@@ -1151,7 +1177,7 @@ func (ctx *BuildContext) lexer(entry *BuildEntry) (ret *BuildMeta, cjsExports []
 			ExportDefault: true,
 			CJS:           true,
 		}
-		cjsExports = cjs.Exports
+		exports = cjs.Exports
 		cjsReexport = cjs.Reexport
 		entry.module = false
 		return
@@ -1167,7 +1193,7 @@ func (ctx *BuildContext) lexer(entry *BuildEntry) (ret *BuildMeta, cjsExports []
 			ExportDefault: true,
 			CJS:           true,
 		}
-		cjsExports = cjs.Exports
+		exports = cjs.Exports
 		cjsReexport = cjs.Reexport
 		return
 	}
@@ -1280,8 +1306,9 @@ func normalizeImportSpecifier(specifier string) string {
 	return specifier
 }
 
-// validateJSFile validates javascript/typescript module from the given file.
-func validateJSFile(filename string) (isESM bool, namedExports []string, err error) {
+// validateJSFile validates javascript/typescript module from the given file, the `hasExportStar` is true if the module
+// has `export * from "..."` statements.
+func validateJSFile(filename string) (isESM bool, namedExports []string, hasExportStar bool, err error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return
@@ -1313,5 +1340,6 @@ func validateJSFile(filename string) (isESM bool, namedExports []string, err err
 		namedExports[i] = name
 		i++
 	}
+	hasExportStar = len(ast.ExportStarImportRecords) > 0
 	return
 }
@@ -1,10 +1,15 @@
 package server
 
 import (
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/Masterminds/semver/v3"
 	esbuild "github.com/ije/esbuild-internal/api"
+	"github.com/ije/esbuild-internal/compat"
 )
 
 var targets = map[string]esbuild.Target{
@@ -24,6 +29,247 @@ var targets = map[string]esbuild.Target{
 	"node":     esbuild.ESNext,
 }
 
+var (
+	regexpEngineTarget = regexp.MustCompile(`^([a-z]+)(\d+(?:\.\d+)?)$`)
+	regexpEngineQuery  = regexp.MustCompile(`^([a-z_]+)\s*(?:>=)?\s*(\d+(?:\.\d+)?)$`)
+)
+
+// the browserslist `defaults` query, which is mapped to the "widely available" baseline browsers
+var defaultEngineQueries = []string{"chrome>=107", "edge>=107", "firefox>=104", "safari>=16"}
+
+// browser name aliases of browserslist
+var browserAliases = map[string]string{
+	"and_chr":        "chrome",
+	"chromeandroid":  "chrome",
+	"and_ff":         "firefox",
+	"ff":             "firefox",
+	"firefoxandroid": "firefox",
+	"ios_saf":        "ios",
+	"op":             "opera",
+}
+
+// the minimal browser versions that support ES modules, older versions are not supported
+var engineMinVersions = map[esbuild.EngineName][2]int{
+	esbuild.EngineChrome:  {61, 0},
+	esbuild.EngineEdge:    {16, 0},
+	esbuild.EngineFirefox: {60, 0},
+	esbuild.EngineIOS:     {11, 0},
+	esbuild.EngineOpera:   {48, 0},
+	esbuild.EngineSafari:  {11, 0},
+}
+
+// cache of the engine targets by browser versions
+var engineTargetCache sync.Map
+
+var compatEngines = map[esbuild.EngineName]compat.Engine{
+	esbuild.EngineChrome:  compat.Chrome,
+	esbuild.EngineEdge:    compat.Edge,
+	esbuild.EngineFirefox: compat.Firefox,
+	esbuild.EngineIOS:     compat.IOS,
+	esbuild.EngineOpera:   compat.Opera,
+	esbuild.EngineSafari:  compat.Safari,
+}
+
+// engineVersion is a browser version with the major and minor parts.
+type engineVersion [2]int
+
+func (v engineVersion) String() string {
+	if v[1] > 0 {
+		return strconv.Itoa(v[0]) + "." + strconv.Itoa(v[1])
+	}
+	return strconv.Itoa(v[0])
+}
+
+func (v engineVersion) less(other engineVersion) bool {
+	return v[0] < other[0] || (v[0] == other[0] && v[1] < other[1])
+}
+
+func parseEngineVersion(s string) (v engineVersion, ok bool) {
+	a := strings.SplitN(s, ".", 3)
+	major, err := strconv.Atoi(a[0])
+	if err != nil || major <= 0 {
+		return
+	}
+	v[0] = major
+	if len(a) > 1 {
+		minor, err := strconv.Atoi(a[1])
+		if err != nil || minor < 0 {
+			return
+		}
+		v[1] = minor
+	}
+	return v, true
+}
+
+// getBuildTarget returns the esbuild target and engines of the given build target.
+func getBuildTarget(target string) (esbuild.Target, []esbuild.Engine) {
+	if t, ok := targets[target]; ok {
+		return t, nil
+	}
+	if engines, ok := parseEngineTarget(target); ok {
+		return esbuild.ESNext, engines
+	}
+	return esbuild.ESNext, nil
+}
+
+// isValidBuildTarget checks if the given string is a valid build target, either a predefined
+// target like `es2022` or an engine target like `chrome107-safari16`.
+func isValidBuildTarget(target string) bool {
+	if _, ok := targets[target]; ok {
+		return true
+	}
+	_, ok := parseEngineTarget(target)
+	return ok
+}
+
+// parseEngineTarget parses the canonical engine target key like `chrome107-safari16.4`.
+func parseEngineTarget(target string) (engines []esbuild.Engine, ok bool) {
+	if target == "" {
+		return nil, false
+	}
+	prevName := ""
+	for _, part := range strings.Split(target, "-") {
+		m := regexpEngineTarget.FindStringSubmatch(part)
+		if m == nil {
+			return nil, false
+		}
+		engine, ok := browsers[m[1]]
+		// the engine names must be sorted and unique
+		if !ok || m[1] <= prevName {
+			return nil, false
+		}
+		version, ok := parseEngineVersion(m[2])
+		if !ok || version.String() != m[2] || version.less(engineMinVersions[engine]) {
+			return nil, false
+		}
+		prevName = m[1]
+		engines = append(engines, esbuild.Engine{Name: engine, Version: m[2]})
+	}
+	return engines, true
+}
+
+// normalizeBuildTarget normalizes the `?target` query to a build target. Except the predefined targets,
+// a list of browser versions or browserslist-style queries are supported, e.g.
+// - chrome>=100,safari>=15
+// - chrome100,safari15.4
+// - defaults
+// The queries are converted to the canonical engine target key like `chrome100-safari15`.
+func normalizeBuildTarget(query string) (target string, ok bool) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return "", false
+	}
+	if _, ok := targets[query]; ok {
+		return query, true
+	}
+	if _, ok := parseEngineTarget(query); ok {
+		return query, true
+	}
+	engines := map[string]engineVersion{}
+	items := strings.FieldsFunc(strings.ReplaceAll(query, " or ", ","), func(r rune) bool { return r == ',' })
+	for i := 0; i < len(items); i++ {
+		item := strings.TrimSpace(items[i])
+		if item == "" {
+			continue
+		}
+		if item == "defaults" {
+			items = append(items, defaultEngineQueries...)
+			continue
+		}
+		m := regexpEngineQuery.FindStringSubmatch(item)
+		if m == nil {
+			return "", false
+		}
+		name := m[1]
+		if alias, ok := browserAliases[name]; ok {
+			name = alias
+		}
+		engine, ok := browsers[name]
+		if !ok {
+			return "", false
+		}
+		version, ok := parseEngineVersion(m[2])
+		if !ok {
+			return "", false
+		}
+		// browsers that don't support ES modules are ignored
+		if minVersion := engineMinVersions[engine]; version.less(minVersion) {
+			version = minVersion
+		}
+		if v, ok := engines[name]; !ok || version.less(v) {
+			engines[name] = version
+		}
+	}
+	if len(engines) == 0 {
+		return "", false
+	}
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + engines[name].String()
+	}
+	return strings.Join(parts, "-"), true
+}
+
+// getEngineTargetByBrowser returns the minimal engine target of the browser version that has the same
+// unsupported features, to share the builds between browser versions, e.g. "Chrome/120" -> "chrome119".
+func getEngineTargetByBrowser(engine esbuild.EngineName, version engineVersion) string {
+	name := ""
+	for n, e := range browsers {
+		if e == engine {
+			name = n
+			break
+		}
+	}
+	compatEngine, ok := compatEngines[engine]
+	if name == "" || !ok {
+		return ""
+	}
+	minVersion := engineMinVersions[engine]
+	if version.less(minVersion) {
+		return ""
+	}
+	cacheKey := name + version.String()
+	if v, ok := engineTargetCache.Load(cacheKey); ok {
+		return v.(string)
+	}
+	unsupported := func(v engineVersion) (compat.JSFeature, compat.CSSFeature) {
+		constraints := map[compat.Engine]compat.Semver{compatEngine: {Parts: []int{v[0], v[1]}}}
+		return compat.UnsupportedJSFeatures(constraints), compat.UnsupportedCSSFeatures(constraints)
+	}
+	// only safari checks the minor versions
+	withMinor := engine == esbuild.EngineSafari || engine == esbuild.EngineIOS
+	if !withMinor {
+		version[1] = 0
+	}
+	jsFeatures, cssFeatures := unsupported(version)
+	for {
+		prev := version
+		if prev[1] > 0 {
+			prev[1]--
+		} else if withMinor {
+			prev = engineVersion{prev[0] - 1, 9}
+		} else {
+			prev[0]--
+		}
+		if prev.less(minVersion) {
+			break
+		}
+		js, css := unsupported(prev)
+		if js != jsFeatures || css != cssFeatures {
+			break
+		}
+		version = prev
+	}
+	target := name + version.String()
+	engineTargetCache.Store(cacheKey, target)
+	return target
+}
+
 func getBuildTargetByUA(ua string) string {
 	if strings.HasPrefix(ua, "ES/") {
 		t := "es" + ua[3:]
@@ -42,5 +288,15 @@ func getBuildTargetByUA(ua string) string {
 	if ua == "undici" || strings.HasPrefix(ua, "Node.js/") || strings.HasPrefix(ua, "Node/") || strings.HasPrefix(ua, "Bun/") {
 		return "node"
 	}
+	if strings.HasPrefix(ua, "Mozilla/") {
+		name, version := getBrowserInfo(ua)
+		if engine, ok := browsers[strings.ToLower(name)]; ok {
+			if v, ok := parseEngineVersion(version); ok {
+				if target := getEngineTargetByBrowser(engine, v); target != "" {
+					return target
+				}
+			}
+		}
+	}
 	return "es2022"
 }
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestNormalizeBuildTarget(t *testing.T) {
+	for query, expected := range map[string]string{
+		"es2022":                      "es2022",
+		"DENONEXT":                    "denonext",
+		"chrome>=100,safari>=15":      "chrome100-safari15",
+		"safari >= 15.4, chrome 100":  "chrome100-safari15.4",
+		"chrome100,and_chr>=90":       "chrome90",
+		"ios_saf>=16 or ff>=110":      "firefox110-ios16",
+		"chrome>=40":                  "chrome61",
+		"defaults":                    "chrome107-edge107-firefox104-safari16",
+		"chrome107-edge107-safari16":  "chrome107-edge107-safari16",
+		"defaults,safari>=15":         "chrome107-edge107-firefox104-safari15",
+		"firefox >= 115.0, edge >= 1": "edge16-firefox115",
+	} {
+		target, ok := normalizeBuildTarget(query)
+		if !ok || target != expected {
+			t.Fatalf("normalizeBuildTarget(%q): expected %q, got %q", query, expected, target)
+		}
+		if !isValidBuildTarget(target) {
+			t.Fatalf("isValidBuildTarget(%q): should be valid", target)
+		}
+	}
+	for _, query := range []string{"", "es2000", "ie>=11", "chrome", "chrome>=abc", "last 2 versions"} {
+		if target, ok := normalizeBuildTarget(query); ok {
+			t.Fatalf("normalizeBuildTarget(%q): should be invalid, got %q", query, target)
+		}
+	}
+	for _, target := range []string{"safari16-chrome107", "chrome107-chrome108", "chrome50", "safari15.0", "chrome-107"} {
+		if isValidBuildTarget(target) {
+			t.Fatalf("isValidBuildTarget(%q): should be invalid", target)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	esbuild "github.com/ije/esbuild-internal/api"
+)
+
+func TestCSSModulesLoader(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.js":          `import styles from "./button.module.css"; export default styles;`,
+		"button.module.css": `.btn { color: red; } .primary { composes: btn; background: blue; } :global(.dark) .primary { color: white; }`,
+		"global.css":        `.btn { color: green; }`,
+		"with-global.js":    `import "./global.css";`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ret := esbuild.Build(esbuild.BuildOptions{
+		EntryPoints: []string{filepath.Join(dir, "index.js"), filepath.Join(dir, "with-global.js")},
+		Bundle:      true,
+		Format:      esbuild.FormatESModule,
+		Platform:    esbuild.PlatformBrowser,
+		Loader:      loaders,
+		Outdir:      "/esbuild",
+		Write:       false,
+	})
+	if len(ret.Errors) > 0 {
+		t.Fatal(ret.Errors[0].Text)
+	}
+	var js, css, globalCSS string
+	for _, file := range ret.OutputFiles {
+		switch filepath.Base(file.Path) {
+		case "index.js":
+			js = string(file.Contents)
+		case "index.css":
+			css = string(file.Contents)
+		case "with-global.css":
+			globalCSS = string(file.Contents)
+		}
+	}
+
+	// the class names are scoped to the file
+	btn := regexp.MustCompile(`btn"?:\s*"([\w-]+)"`).FindStringSubmatch(js)
+	primary := regexp.MustCompile(`primary"?:\s*"([\w-]+)`).FindStringSubmatch(js)
+	if btn == nil || primary == nil {
+		t.Fatalf("missing the class map in the js:\n%s", js)
+	}
+	if btn[1] == "btn" || !strings.Contains(btn[1], "btn") || primary[1] == "primary" {
+		t.Fatalf("the class names should be scoped, got %q and %q", btn[1], primary[1])
+	}
+	for _, s := range []string{"." + btn[1] + " {", "." + primary[1] + " {", ".dark ." + primary[1] + " {"} {
+		if !strings.Contains(css, s) {
+			t.Fatalf("missing %q in the css:\n%s", s, css)
+		}
+	}
+	if strings.Contains(css, "composes") {
+		t.Fatalf("the composes declarations should be removed:\n%s", css)
+	}
+
+	// the default export is the class map, `composes` adds the composed class names
+	if !strings.Contains(js, " as default") {
+		t.Fatalf("missing the default export of the class map:\n%s", js)
+	}
+	composed := regexp.MustCompile(`primary"?:([^,\n}]*)`).FindStringSubmatch(js)
+	if composed == nil || !strings.Contains(composed[1], primary[1]) || !strings.Contains(composed[1], btn[1]) {
+		t.Fatalf("the composed class names should be added to the class map:\n%s", js)
+	}
+
+	// the plain css files are not scoped
+	if !strings.Contains(globalCSS, ".btn {") {
+		t.Fatalf("the plain css should not be scoped:\n%s", globalCSS)
+	}
+}
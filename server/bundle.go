@@ -0,0 +1,220 @@
+package server
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/esm-dev/esm.sh/internal/importmap"
+	"github.com/goccy/go-json"
+	esbuild "github.com/ije/esbuild-internal/api"
+)
+
+// BundleOptions is the request body of the `POST /bundle` API.
+type BundleOptions struct {
+	Files           map[string]string `json:"files"`
+	Entry           string            `json:"entry"`
+	ImportMap       json.RawMessage   `json:"importMap"`
+	JsxImportSource string            `json:"jsxImportSource"`
+	Target          string            `json:"target"`
+}
+
+type ResolvedBundleOptions struct {
+	BundleOptions
+	importMap importmap.ImportMap
+	origin    string
+}
+
+// BundleOutput is the output of the `POST /bundle` API, the files are stored as `/+{hash}.mjs`, `/+{hash}.{chunk}.mjs`
+// and their source maps.
+type BundleOutput struct {
+	URL    string        `json:"url"`
+	Code   string        `json:"code"`
+	Map    string        `json:"map,omitempty"`
+	Chunks []BundleChunk `json:"chunks"`
+}
+
+// BundleChunk is a chunk or the css file of the bundle.
+type BundleChunk struct {
+	URL  string `json:"url"`
+	Code string `json:"code"`
+	Map  string `json:"map,omitempty"`
+}
+
+// Hash returns the content hash of the bundle options, the origin is hashed as the bare imports are resolved to it.
+func (options *BundleOptions) Hash(origin string) string {
+	filenames := make([]string, 0, len(options.Files))
+	for filename := range options.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	h := sha1.New()
+	for _, filename := range filenames {
+		h.Write([]byte(filename))
+		h.Write([]byte{0})
+		h.Write([]byte(options.Files[filename]))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(options.Entry))
+	h.Write([]byte{0})
+	h.Write(options.ImportMap)
+	h.Write([]byte{0})
+	h.Write([]byte(options.JsxImportSource))
+	h.Write([]byte{0})
+	h.Write([]byte(options.Target))
+	h.Write([]byte{0})
+	h.Write([]byte(origin))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bundle bundles the virtual files from the entry, the bare imports are resolved by the import map or
+// the esm.sh builds. The output files are keyed by the base name, e.g. "+{hash}.mjs", "+{hash}.mjs.map".
+func bundle(options *ResolvedBundleOptions, hash string) (files map[string][]byte, err error) {
+	target, engines := getBuildTarget(options.Target)
+	entry := normalizeBundleFilename(options.Entry)
+	if _, ok := options.Files[entry]; !ok {
+		err = errors.New("entry not found: " + options.Entry)
+		return
+	}
+
+	jsxImportSource := options.JsxImportSource
+	if jsxImportSource == "" {
+		jsxImportSource = resolveJsxImportSource(options.importMap)
+	}
+
+	ret := esbuild.Build(esbuild.BuildOptions{
+		EntryPoints:       []string{entry},
+		Platform:          esbuild.PlatformBrowser,
+		Format:            esbuild.FormatESModule,
+		Target:            target,
+		Engines:           engines,
+		JSX:               esbuild.JSXAutomatic,
+		JSXImportSource:   strings.TrimSuffix(jsxImportSource, "/"),
+		Bundle:            true,
+		Splitting:         true,
+		MinifyWhitespace:  true,
+		MinifySyntax:      true,
+		MinifyIdentifiers: true,
+		Sourcemap:         esbuild.SourceMapLinked,
+		EntryNames:        "+" + hash,
+		ChunkNames:        "+" + hash + ".[name]-[hash]",
+		OutExtension:      map[string]string{".js": ".mjs"},
+		Outdir:            "/esbuild",
+		Write:             false,
+		Plugins: []esbuild.Plugin{
+			{
+				Name: "bundle-resolver",
+				Setup: func(build esbuild.PluginBuild) {
+					build.OnResolve(esbuild.OnResolveOptions{Filter: ".*"}, func(args esbuild.OnResolveArgs) (esbuild.OnResolveResult, error) {
+						if args.Kind == esbuild.ResolveEntryPoint {
+							return esbuild.OnResolveResult{Path: args.Path, Namespace: "virtual"}, nil
+						}
+						if args.Namespace == "virtual" && (isRelPathSpecifier(args.Path) || strings.HasPrefix(args.Path, "/")) {
+							filename := args.Path
+							if isRelPathSpecifier(filename) {
+								filename = path.Join(path.Dir(args.Importer), filename)
+							}
+							filename = normalizeBundleFilename(filename)
+							if _, ok := options.Files[filename]; !ok {
+								return esbuild.OnResolveResult{}, errors.New("file not found: " + args.Path)
+							}
+							return esbuild.OnResolveResult{Path: filename, Namespace: "virtual"}, nil
+						}
+						if resolved, ok := options.importMap.Resolve(args.Path); ok {
+							return esbuild.OnResolveResult{Path: resolved, External: true}, nil
+						}
+						if isHttpSepcifier(args.Path) {
+							return esbuild.OnResolveResult{Path: args.Path, External: true}, nil
+						}
+						// resolve the bare import to the esm.sh build, e.g. "react" -> "https://esm.sh/react?target=es2022"
+						specifier := args.Path
+						if strings.HasPrefix(specifier, "node:") {
+							return esbuild.OnResolveResult{Path: options.origin + "/node/" + specifier[5:] + ".mjs", External: true}, nil
+						}
+						if options.Target != "" {
+							if strings.ContainsRune(specifier, '?') {
+								specifier += "&target=" + options.Target
+							} else {
+								specifier += "?target=" + options.Target
+							}
+						}
+						return esbuild.OnResolveResult{Path: options.origin + "/" + specifier, External: true}, nil
+					})
+					build.OnLoad(esbuild.OnLoadOptions{Filter: ".*", Namespace: "virtual"}, func(args esbuild.OnLoadArgs) (esbuild.OnLoadResult, error) {
+						contents := options.Files[args.Path]
+						loader := esbuild.LoaderJS
+						switch path.Ext(args.Path) {
+						case ".jsx":
+							loader = esbuild.LoaderJSX
+						case ".ts", ".mts", ".cts":
+							loader = esbuild.LoaderTS
+						case ".tsx":
+							loader = esbuild.LoaderTSX
+						case ".css":
+							loader = esbuild.LoaderCSS
+						case ".json":
+							loader = esbuild.LoaderJSON
+						case ".txt":
+							loader = esbuild.LoaderText
+						}
+						return esbuild.OnLoadResult{Contents: &contents, Loader: loader, ResolveDir: path.Dir(args.Path)}, nil
+					})
+				},
+			},
+		},
+	})
+	if len(ret.Errors) > 0 {
+		err = errors.New("failed to bundle: " + ret.Errors[0].Text)
+		return
+	}
+	files = make(map[string][]byte, len(ret.OutputFiles))
+	for _, file := range ret.OutputFiles {
+		files[path.Base(file.Path)] = file.Contents
+	}
+	return
+}
+
+// normalizeBundleFilename normalizes the filename of the virtual files, e.g. "./src/app.tsx" -> "/src/app.tsx"
+func normalizeBundleFilename(filename string) string {
+	return path.Join("/", filename)
+}
+
+// resolveJsxImportSource resolves the jsx import source by the import map, defaults to "react".
+func resolveJsxImportSource(importMap importmap.ImportMap) string {
+	for _, key := range []string{"@jsxRuntime", "@jsxImportSource", "preact", "react"} {
+		if jsxImportSource, ok := importMap.Resolve(key); ok {
+			return jsxImportSource
+		}
+	}
+	return "react"
+}
+
+// newBundleOutput creates the output of the `POST /bundle` API by the bundle files.
+func newBundleOutput(hash string, files map[string][]byte, origin string) *BundleOutput {
+	output := &BundleOutput{Chunks: []BundleChunk{}}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if !strings.HasSuffix(name, ".map") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		url := origin + "/" + name
+		if name == "+"+hash+".mjs" {
+			output.URL = url
+			output.Code = string(files[name])
+			output.Map = string(files[name+".map"])
+		} else {
+			output.Chunks = append(output.Chunks, BundleChunk{
+				URL:  url,
+				Code: string(files[name]),
+				Map:  string(files[name+".map"]),
+			})
+		}
+	}
+	return output
+}
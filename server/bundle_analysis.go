@@ -0,0 +1,277 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/esm-dev/esm.sh/internal/storage"
+	"github.com/goccy/go-json"
+)
+
+// BundleAnalysis is the bundle-size analysis of a built module, see the `?analyze` query.
+type BundleAnalysis struct {
+	Path    string           `json:"path"`
+	Outputs []AnalysisOutput `json:"outputs"`
+	Inputs  []AnalysisInput  `json:"inputs"`
+	Imports []string         `json:"imports"`
+}
+
+// AnalysisOutput is a file of the build output.
+type AnalysisOutput struct {
+	Path   string `json:"path"`
+	Bytes  int    `json:"bytes"`
+	Gzip   int    `json:"gzip"`
+	Brotli int    `json:"brotli"`
+}
+
+// AnalysisInput is a source file bundled into the build output.
+type AnalysisInput struct {
+	Path          string `json:"path"`
+	Package       string `json:"package"`
+	Bytes         int    `json:"bytes"`
+	BytesInOutput int    `json:"bytesInOutput"`
+}
+
+// esbuildMetafile is the partial of the metafile generated by esbuild.
+type esbuildMetafile struct {
+	Inputs map[string]struct {
+		Bytes int `json:"bytes"`
+	} `json:"inputs"`
+	Outputs map[string]struct {
+		Inputs map[string]struct {
+			BytesInOutput int `json:"bytesInOutput"`
+		} `json:"inputs"`
+	} `json:"outputs"`
+}
+
+// getAnalysisSavepath returns the storage path of the bundle analysis, next to the build.
+func (ctx *BuildContext) getAnalysisSavepath() string {
+	return ctx.getSavepath() + ".analyze.json"
+}
+
+// buildAnalysis rebuilds the module with the metafile output and saves the bundle analysis to the storage, the other
+// artifacts of the rebuild are discarded.
+func (ctx *BuildContext) buildAnalysis() (meta *BuildMeta, err error) {
+	// install the package
+	ctx.status = "install"
+	err = ctx.install()
+	if err != nil {
+		return
+	}
+
+	// analyze splitting modules
+	if !ctx.pkgJson.SideEffectsFalse && ctx.bundleMode == BundleDefault && ctx.pkgJson.Exports.Len() > 1 {
+		ctx.status = "analyze"
+		err = ctx.analyzeSplitting()
+		if err != nil {
+			return
+		}
+	}
+
+	// rebuild the module with the artifacts kept in memory, the stored(and signed) artifacts of the module
+	// must not be overwritten as they may be served or built concurrently
+	ctx.status = "build"
+	buildStorage := ctx.storage
+	dryRun := &dryRunStorage{Storage: buildStorage, files: map[string][]byte{}}
+	ctx.storage = dryRun
+	meta, _, err = ctx.buildModule(false)
+	ctx.storage = buildStorage
+	if err != nil {
+		return
+	}
+	if ctx.metafile == "" {
+		err = errors.New("the module is not bundled by esbuild")
+		return
+	}
+
+	var metafile esbuildMetafile
+	err = json.Unmarshal([]byte(ctx.metafile), &metafile)
+	if err != nil {
+		return
+	}
+
+	analysis := BundleAnalysis{
+		Path:    ctx.Path(),
+		Outputs: []AnalysisOutput{},
+		Inputs:  []AnalysisInput{},
+		Imports: []string{},
+	}
+	savePaths := []string{ctx.getSavepath()}
+	if meta.CSSInJS {
+		savePaths = append(savePaths, strings.TrimSuffix(ctx.getSavepath(), path.Ext(ctx.getSavepath()))+".css")
+	}
+	for _, savePath := range savePaths {
+		data, ok := dryRun.files[savePath]
+		if !ok {
+			err = errors.New("the output " + path.Base(savePath) + " is not found")
+			return
+		}
+		analysis.Outputs = append(analysis.Outputs, ctx.analyzeOutput(path.Base(savePath), data))
+	}
+
+	bytesInOutput := map[string]int{}
+	for _, output := range metafile.Outputs {
+		for name, input := range output.Inputs {
+			bytesInOutput[name] += input.BytesInOutput
+		}
+	}
+	for name, n := range bytesInOutput {
+		analysis.Inputs = append(analysis.Inputs, AnalysisInput{
+			Path:          name,
+			Package:       getInputPackageName(name, ctx.esmPath.PkgName),
+			Bytes:         metafile.Inputs[name].Bytes,
+			BytesInOutput: n,
+		})
+	}
+	sort.Slice(analysis.Inputs, func(i, j int) bool {
+		a, b := analysis.Inputs[i], analysis.Inputs[j]
+		if a.BytesInOutput != b.BytesInOutput {
+			return a.BytesInOutput > b.BytesInOutput
+		}
+		return a.Path < b.Path
+	})
+	analysis.Imports = append(analysis.Imports, meta.Imports...)
+
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return
+	}
+	err = ctx.storage.Put(ctx.getAnalysisSavepath(), bytes.NewReader(data))
+	if err != nil {
+		ctx.logger.Errorf("storage.put(%s): %v", ctx.getAnalysisSavepath(), err)
+		err = errors.New("storage: " + err.Error())
+		return
+	}
+
+	// the SBOM is created lazily by the rebuild, as the `?sbom` query is rare. A failed write doesn't fail the
+	// analysis, the SBOM is created again by the next `?sbom` request
+	if sbom, ok := dryRun.files[ctx.getSBOMSavepath()]; ok {
+		if _, e := ctx.storage.Stat(ctx.getSBOMSavepath()); e == storage.ErrNotFound {
+			if e := ctx.storage.Put(ctx.getSBOMSavepath(), bytes.NewReader(sbom)); e != nil {
+				ctx.logger.Errorf("storage.put(%s): %v", ctx.getSBOMSavepath(), e)
+			}
+		}
+	}
+	return
+}
+
+// analyzeOutput measures the raw and compressed sizes of the output file.
+func (ctx *BuildContext) analyzeOutput(name string, data []byte) AnalysisOutput {
+	gz := &byteCounter{}
+	br := &byteCounter{}
+	gw := gzip.NewWriter(gz)
+	bw := brotli.NewWriterLevel(br, brotli.DefaultCompression)
+	gw.Write(data)
+	bw.Write(data)
+	gw.Close()
+	bw.Close()
+	return AnalysisOutput{
+		Path:   path.Dir(ctx.Path()) + "/" + name,
+		Bytes:  len(data),
+		Gzip:   gz.n,
+		Brotli: br.n,
+	}
+}
+
+// getInputPackageName returns the package name of the metafile input, e.g.
+// "node_modules/@scope/foo/index.js" -> "@scope/foo"
+func getInputPackageName(input string, pkgName string) string {
+	i := strings.LastIndex(input, "node_modules/")
+	if i < 0 {
+		return pkgName
+	}
+	segments := strings.Split(input[i+len("node_modules/"):], "/")
+	if len(segments) > 1 && strings.HasPrefix(segments[0], "@") {
+		return segments[0] + "/" + segments[1]
+	}
+	return segments[0]
+}
+
+type byteCounter struct {
+	n int
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += len(p)
+	return len(p), nil
+}
+
+// dryRunStorage keeps the writes in memory, the reads of other files fall through to the underlying storage.
+type dryRunStorage struct {
+	storage.Storage
+	lock  sync.RWMutex
+	files map[string][]byte
+}
+
+type dryRunStat struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s *dryRunStat) Size() int64 {
+	return s.size
+}
+
+func (s *dryRunStat) ModTime() time.Time {
+	return s.modTime
+}
+
+func (s *dryRunStorage) Stat(key string) (storage.Stat, error) {
+	s.lock.RLock()
+	data, ok := s.files[key]
+	s.lock.RUnlock()
+	if ok {
+		return &dryRunStat{int64(len(data)), time.Now()}, nil
+	}
+	return s.Storage.Stat(key)
+}
+
+func (s *dryRunStorage) Get(key string) (io.ReadCloser, storage.Stat, error) {
+	s.lock.RLock()
+	data, ok := s.files[key]
+	s.lock.RUnlock()
+	if ok {
+		return io.NopCloser(bytes.NewReader(data)), &dryRunStat{int64(len(data)), time.Now()}, nil
+	}
+	return s.Storage.Get(key)
+}
+
+func (s *dryRunStorage) Put(key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.files[key] = data
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *dryRunStorage) Delete(keys ...string) error {
+	s.lock.Lock()
+	for _, key := range keys {
+		delete(s.files, key)
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *dryRunStorage) DeleteAll(prefix string) (deletedKeys []string, err error) {
+	s.lock.Lock()
+	for key := range s.files {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.files, key)
+			deletedKeys = append(deletedKeys, key)
+		}
+	}
+	s.lock.Unlock()
+	return
+}
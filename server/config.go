@@ -3,6 +3,8 @@ package server
 import (
 	"bytes"
 	"fmt"
+	"net"
+	"net/netip"
 	"net/url"
 	"os"
 	"path"
@@ -11,6 +13,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/esm-dev/esm.sh/internal/fetch"
+	"github.com/esm-dev/esm.sh/internal/npm"
 	"github.com/esm-dev/esm.sh/internal/storage"
 	"github.com/goccy/go-json"
 	"github.com/ije/gox/term"
@@ -23,33 +27,50 @@ var (
 
 // Config represents the configuration of esm.sh server.
 type Config struct {
-	Port                uint16                 `json:"port"`
-	TlsPort             uint16                 `json:"tlsPort"`
-	LegacyServer        string                 `json:"legacyServer"` // normally you don't need to set this
-	CustomLandingPage   LandingPageOptions     `json:"customLandingPage"`
-	WorkDir             string                 `json:"workDir"`
-	CorsAllowOrigins    []string               `json:"corsAllowOrigins"`
-	AllowList           AllowList              `json:"allowList"`
-	BanList             BanList                `json:"banList"`
-	BuildConcurrency    uint16                 `json:"buildConcurrency"`
-	BuildWaitTime       uint16                 `json:"buildWaitTime"`
-	Storage             storage.StorageOptions `json:"storage"`
-	CacheRawFile        bool                   `json:"cacheRawFile"`
-	LogDir              string                 `json:"logDir"`
-	LogLevel            string                 `json:"logLevel"`
-	AccessLog           bool                   `json:"accessLog"`
-	NpmRegistry         string                 `json:"npmRegistry"`
-	NpmToken            string                 `json:"npmToken"`
-	NpmUser             string                 `json:"npmUser"`
-	NpmPassword         string                 `json:"npmPassword"`
-	NpmScopedRegistries map[string]NpmRegistry `json:"npmScopedRegistries"`
-	NpmQueryCacheTTL    uint32                 `json:"npmQueryCacheTTL"`
-	MinifyRaw           json.RawMessage        `json:"minify"`
-	SourceMapRaw        json.RawMessage        `json:"sourceMap"`
-	CompressRaw         json.RawMessage        `json:"compress"`
-	Minify              bool                   `json:"-"`
-	SourceMap           bool                   `json:"-"`
-	Compress            bool                   `json:"-"`
+	Port                   uint16                                `json:"port"`
+	TlsPort                uint16                                `json:"tlsPort"`
+	LegacyServer           string                                `json:"legacyServer"` // normally you don't need to set this
+	CustomLandingPage      LandingPageOptions                    `json:"customLandingPage"`
+	WorkDir                string                                `json:"workDir"`
+	CorsAllowOrigins       []string                              `json:"corsAllowOrigins"`
+	AllowList              AllowList                             `json:"allowList"`
+	BanList                BanList                               `json:"banList"`
+	ApiKeys                ApiKeyOptions                         `json:"apiKeys"`
+	RateLimit              RateLimitOptions                      `json:"rateLimit"`
+	BuildConcurrency       uint16                                `json:"buildConcurrency"`
+	BuildWaitTime          uint16                                `json:"buildWaitTime"`
+	Storage                storage.StorageOptions                `json:"storage"`
+	CacheRawFile           bool                                  `json:"cacheRawFile"`
+	LogDir                 string                                `json:"logDir"`
+	LogLevel               string                                `json:"logLevel"`
+	AccessLog              bool                                  `json:"accessLog"`
+	NpmRC                  string                                `json:"npmrc"`
+	NpmRegistry            string                                `json:"npmRegistry"`
+	NpmToken               string                                `json:"npmToken"`
+	NpmUser                string                                `json:"npmUser"`
+	NpmPassword            string                                `json:"npmPassword"`
+	NpmScopedRegistries    map[string]NpmRegistry                `json:"npmScopedRegistries"`
+	NpmrcVaultKeys         []string                              `json:"npmrcVaultKeys"`
+	SigningKeys            []string                              `json:"signingKeys"`
+	NpmQueryCacheTTL       uint32                                `json:"npmQueryCacheTTL"`
+	JsrRegistry            string                                `json:"jsrRegistry"`
+	JsrNpmCompat           bool                                  `json:"jsrNpmCompat"`
+	GithubToken            string                                `json:"githubToken"`
+	GitHosts               map[string]GitHost                    `json:"gitHosts"`
+	TgzAllowHosts          []string                              `json:"tgzAllowHosts"`
+	TgzDenyHosts           []string                              `json:"tgzDenyHosts"`
+	HttpModuleAllowOrigins []string                              `json:"httpModuleAllowOrigins"`
+	HttpModuleDenyOrigins  []string                              `json:"httpModuleDenyOrigins"`
+	PreloadDepth           int                                   `json:"preloadDepth"`
+	PreloadLimit           int                                   `json:"preloadLimit"`
+	DefineRaw              map[string]map[string]json.RawMessage `json:"define"`
+	MinifyRaw              json.RawMessage                       `json:"minify"`
+	SourceMapRaw           json.RawMessage                       `json:"sourceMap"`
+	CompressRaw            json.RawMessage                       `json:"compress"`
+	Minify                 bool                                  `json:"-"`
+	SourceMap              bool                                  `json:"-"`
+	Compress               bool                                  `json:"-"`
+	Define                 map[string]map[string]string          `json:"-"`
 }
 
 type LandingPageOptions struct {
@@ -76,6 +97,41 @@ type AllowScope struct {
 	Name string `json:"name"`
 }
 
+type ApiKeyOptions struct {
+	// require an API key for all requests
+	Required bool `json:"required"`
+	// the package scopes(e.g. "@internal") or names that require an API key
+	ProtectedScopes []string `json:"protectedScopes"`
+	// the routes(e.g. "/transform", "/gh/*") that require an API key
+	ProtectedRoutes []string `json:"protectedRoutes"`
+	Keys            []ApiKey `json:"keys"`
+}
+
+type RateLimitOptions struct {
+	// the header of the client IP set by the proxy(e.g. "CF-Connecting-IP"), default uses the remote address
+	ClientIPHeader string `json:"clientIpHeader"`
+	// the maximum number of requests per minute per client, 0 means unlimited
+	Requests int `json:"requests"`
+	// the maximum number of new builds per minute per client
+	Builds int `json:"builds"`
+	// the maximum number of transform calls(`POST /transform`, `POST /bundle` and `/http(s)://` modules) per minute per client
+	Transforms int `json:"transforms"`
+	// the limits of requests per minute per client of the routes(e.g. {"/importmap": 30, "/gh/*": 60}), which
+	// override the `requests` and `transforms` limits
+	Routes map[string]int `json:"routes"`
+}
+
+type ApiKey struct {
+	Key  string `json:"key,omitempty"`
+	Name string `json:"name"`
+	// the allowed package scopes or names, empty means all packages
+	Scopes []string `json:"scopes,omitempty"`
+	// the allowed routes, empty means all routes except the admin endpoints
+	Routes []string `json:"routes,omitempty"`
+	// the maximum number of requests per minute, 0 means unlimited
+	RateLimit int `json:"rateLimit,omitempty"`
+}
+
 // LoadConfig loads config from the given file. Panic if failed to load.
 func LoadConfig(filename string) (*Config, error) {
 	file, err := os.Open(filename)
@@ -201,6 +257,36 @@ func normalizeConfig(config *Config) {
 	if !config.AccessLog {
 		config.AccessLog = os.Getenv("ACCESS_LOG") == "true"
 	}
+	if config.NpmRC == "" {
+		config.NpmRC = os.Getenv("NPMRC")
+	}
+	if config.NpmRC != "" {
+		// the explicit npm options take precedence over the `.npmrc` file
+		rc, err := npm.ReadRCFile(config.NpmRC)
+		if err != nil {
+			fmt.Println(term.Red("[error] failed to read npmrc file: " + err.Error()))
+		} else {
+			if config.NpmRegistry == "" {
+				config.NpmRegistry = rc.Registry
+			}
+			if config.NpmToken == "" && config.NpmUser == "" {
+				config.NpmToken = rc.Token
+				config.NpmUser = rc.User
+				config.NpmPassword = rc.Password
+			}
+			if config.GithubToken == "" {
+				config.GithubToken = rc.AuthTokens["github.com"]
+			}
+			for scope, reg := range rc.ScopedRegistries {
+				if _, ok := config.NpmScopedRegistries[scope]; !ok {
+					if config.NpmScopedRegistries == nil {
+						config.NpmScopedRegistries = map[string]NpmRegistry{}
+					}
+					config.NpmScopedRegistries[scope] = toNpmRegistry(reg)
+				}
+			}
+		}
+	}
 	if config.NpmRegistry != "" {
 		if isHttpSepcifier(config.NpmRegistry) {
 			config.NpmRegistry = strings.TrimRight(config.NpmRegistry, "/") + "/"
@@ -246,11 +332,147 @@ func normalizeConfig(config *Config) {
 		}
 		config.NpmQueryCacheTTL = 600
 	}
+	if config.JsrRegistry == "" {
+		config.JsrRegistry = os.Getenv("JSR_REGISTRY")
+	}
+	if config.JsrRegistry != "" && isHttpSepcifier(config.JsrRegistry) {
+		config.JsrRegistry = strings.TrimRight(config.JsrRegistry, "/") + "/"
+	} else {
+		config.JsrRegistry = "https://jsr.io/"
+	}
+	if !config.JsrNpmCompat {
+		config.JsrNpmCompat = os.Getenv("JSR_NPM_COMPAT") == "true"
+	}
+	if config.GithubToken == "" {
+		config.GithubToken = os.Getenv("GITHUB_TOKEN")
+	}
+	if len(config.GitHosts) > 0 {
+		hosts := make(map[string]GitHost)
+		for host, gh := range config.GitHosts {
+			if gh.Url != "" {
+				u, err := url.Parse(gh.Url)
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+					fmt.Printf("[error] invalid url for git host %s: %s\n", host, gh.Url)
+					continue
+				}
+				gh.Url = strings.TrimRight(gh.Url, "/")
+			}
+			switch gh.Type {
+			case "", "git", "gitlab", "bitbucket", "gitea":
+				hosts[host] = gh
+			default:
+				fmt.Printf("[error] invalid type for git host %s: %s\n", host, gh.Type)
+			}
+		}
+		config.GitHosts = hosts
+	}
+	if len(config.NpmrcVaultKeys) == 0 {
+		if v := os.Getenv("NPMRC_VAULT_KEYS"); v != "" {
+			for _, key := range strings.Split(v, ",") {
+				if key = strings.TrimSpace(key); key != "" {
+					config.NpmrcVaultKeys = append(config.NpmrcVaultKeys, key)
+				}
+			}
+		}
+	}
+	if len(config.SigningKeys) == 0 {
+		if v := os.Getenv("SIGNING_KEYS"); v != "" {
+			for _, key := range strings.Split(v, ",") {
+				if key = strings.TrimSpace(key); key != "" {
+					config.SigningKeys = append(config.SigningKeys, key)
+				}
+			}
+		}
+	}
+	if len(config.TgzAllowHosts) == 0 {
+		config.TgzAllowHosts = splitHostList(os.Getenv("TGZ_ALLOW_HOSTS"))
+	}
+	if len(config.TgzDenyHosts) == 0 {
+		config.TgzDenyHosts = splitHostList(os.Getenv("TGZ_DENY_HOSTS"))
+	}
+	if len(config.HttpModuleAllowOrigins) == 0 {
+		config.HttpModuleAllowOrigins = splitHostList(os.Getenv("HTTP_MODULE_ALLOW_ORIGINS"))
+	}
+	if len(config.HttpModuleDenyOrigins) == 0 {
+		config.HttpModuleDenyOrigins = splitHostList(os.Getenv("HTTP_MODULE_DENY_ORIGINS"))
+	}
+	if fetchGuardEnabled {
+		config.HttpModuleAllowOrigins = filterPrivateOrigins(config.HttpModuleAllowOrigins)
+	}
+	if config.PreloadDepth == 0 {
+		config.PreloadDepth = 2
+		if v := os.Getenv("PRELOAD_DEPTH"); v != "" {
+			if i, e := strconv.Atoi(v); e == nil {
+				config.PreloadDepth = i
+			}
+		}
+	}
+	if config.PreloadLimit == 0 {
+		config.PreloadLimit = 20
+		if v := os.Getenv("PRELOAD_LIMIT"); v != "" {
+			if i, e := strconv.Atoi(v); e == nil {
+				config.PreloadLimit = i
+			}
+		}
+	}
+	if len(config.DefineRaw) > 0 {
+		config.Define = make(map[string]map[string]string)
+		for pkgName, values := range config.DefineRaw {
+			pairs := make([]string, 0, len(values))
+			for key, value := range values {
+				pairs = append(pairs, key+":"+string(value))
+			}
+			define, err := parseDefine(strings.Join(pairs, ","))
+			if err != nil {
+				fmt.Printf("[error] invalid define for package %s: %v\n", pkgName, err)
+				continue
+			}
+			config.Define[pkgName] = define
+		}
+	}
 	config.Compress = !(bytes.Equal(config.CompressRaw, []byte("false")) || os.Getenv("COMPRESS") == "false")
 	config.SourceMap = !(bytes.Equal(config.SourceMapRaw, []byte("false")) || (os.Getenv("SOURCEMAP") == "false" || os.Getenv("SOURCE_MAP") == "false"))
 	config.Minify = !(bytes.Equal(config.MinifyRaw, []byte("false")) || os.Getenv("MINIFY") == "false")
 }
 
+// splitHostList splits the comma-separated host list, e.g. "example.com, *.example.org"
+func splitHostList(v string) (hosts []string) {
+	for _, p := range strings.Split(v, ",") {
+		host := strings.ToLower(strings.TrimSpace(p))
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return
+}
+
+// filterPrivateOrigins removes the origins of localhost and the non-public IP addresses, the guarded fetch client
+// always refuses to connect to them, see `newGuardedFetchClient`.
+func filterPrivateOrigins(origins []string) []string {
+	ret := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		_, host, ok := strings.Cut(strings.TrimSuffix(origin, "/"), "://")
+		if !ok {
+			host = origin
+		}
+		hostname := host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			hostname = h
+		}
+		hostname = strings.ToLower(strings.Trim(hostname, "[]"))
+		if hostname == "localhost" || strings.HasSuffix(hostname, ".localhost") {
+			fmt.Printf("[error] invalid http module origin %s: localhost is not allowed\n", origin)
+			continue
+		}
+		if addr, err := netip.ParseAddr(hostname); err == nil && !fetch.IsPublicAddr(addr) {
+			fmt.Printf("[error] invalid http module origin %s: non-public address is not allowed\n", origin)
+			continue
+		}
+		ret = append(ret, origin)
+	}
+	return ret
+}
+
 // extractPackageName Will take a packageName as input extract key parts and return them
 //
 // fullNameWithoutVersion  e.g. @github/faker
@@ -282,3 +282,20 @@ func TestBanList_IsPackageBanned(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterPrivateOrigins(t *testing.T) {
+	origins := filterPrivateOrigins([]string{
+		"https://deno.land",
+		"*.example.com",
+		"http://8.8.8.8:8080",
+		"http://localhost:3000",
+		"*.localhost",
+		"http://127.0.0.1",
+		"192.168.1.1:8080",
+		"http://[::1]:8080",
+		"https://169.254.169.254/",
+	})
+	if len(origins) != 3 || origins[0] != "https://deno.land" || origins[1] != "*.example.com" || origins[2] != "http://8.8.8.8:8080" {
+		t.Fatalf("unexpected origins: %v", origins)
+	}
+}
@@ -0,0 +1,845 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/esm-dev/esm.sh/internal/storage"
+)
+
+// the maximum number of files rolled up by the `?dts-bundle` query
+const maxDtsBundleFiles = 500
+
+var regexpDtsComment = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+
+var errDtsBundleUnsupported = errors.New("unsupported declaration file")
+
+// dtsBundler rolls up a `.d.ts` file and its relative imports into a single file. The relative imported files are
+// wrapped in `declare namespace` blocks, and the import/export declarations are rewritten to the import aliases,
+// e.g. `import { Foo } from "./foo.d.ts"` -> `import Foo = __esm_dts_1.Foo;`. The non-relative imports are kept as
+// they are, e.g. the esm.sh urls of other packages.
+type dtsBundler struct {
+	read    func(pathname string) ([]byte, error)
+	modules map[string]*dtsBundleModule
+	queue   []*dtsBundleModule
+	refs    []string
+	imports []string
+	globals []string
+}
+
+type dtsBundleModule struct {
+	pathname string
+	id       string
+	stmts    []*dtsStmt
+	queued   bool
+	exports  []string
+	locals   []string
+	stars    []string
+}
+
+type dtsStmtKind uint8
+
+const (
+	dtsStmtOther           dtsStmtKind = iota
+	dtsStmtImport                      // import ... from "..."
+	dtsStmtImportBare                  // import "..."
+	dtsStmtImportRequire               // [export] import Foo = require("...")
+	dtsStmtImportAlias                 // export import Foo = Bar.Foo
+	dtsStmtExportFrom                  // export ... from "..."
+	dtsStmtExportList                  // export { ... }
+	dtsStmtExportDefault               // export default ...
+	dtsStmtExportAssign                // export = ...
+	dtsStmtExportNamespace             // export as namespace ...
+	dtsStmtAmbientModule               // declare global/module "..."
+	dtsStmtDecl                        // [export] [declare] class/function/interface/...
+)
+
+// dtsStmt is a top-level statement of a `.d.ts` file.
+type dtsStmt struct {
+	kind      dtsStmtKind
+	trivia    string // the leading spaces and comments
+	code      string
+	clause    string // the import/export clause, e.g. `{ Foo, Bar as Baz }`
+	specifier string // the module specifier, the relative specifiers are resolved to the pathnames
+	name      string // the declaration name, or the alias name of `import Foo = ...`
+	exported  bool
+}
+
+// bundleDTS rolls up the `.d.ts` file of the pathname, e.g. "/react@19.0.0/index.d.ts". The `read` function
+// returns the content of the transformed `.d.ts` files, or `storage.ErrNotFound` if the file does not exist.
+func bundleDTS(pathname string, read func(pathname string) ([]byte, error)) ([]byte, error) {
+	b := &dtsBundler{read: read, modules: map[string]*dtsBundleModule{}}
+	entry, err := b.load(pathname)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.New("types not found")
+	}
+	entry.id = ""
+
+	body := bytes.NewBuffer(nil)
+	err = b.emit(body, entry)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(b.queue); i++ {
+		m := b.queue[i]
+		fmt.Fprintf(body, "\ndeclare namespace %s {\n", m.id)
+		err = b.emit(body, m)
+		if err != nil {
+			return nil, err
+		}
+		body.WriteString("\n}\n")
+	}
+
+	out := bytes.NewBuffer(nil)
+	for _, ref := range b.refs {
+		out.WriteString(ref)
+		out.WriteByte('\n')
+	}
+	for _, imp := range b.imports {
+		out.WriteString(imp)
+		out.WriteByte('\n')
+	}
+	out.Write(body.Bytes())
+	for _, g := range b.globals {
+		out.WriteByte('\n')
+		out.WriteString(g)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// load reads and parses the `.d.ts` file, returns nil if the file does not exist.
+func (b *dtsBundler) load(pathname string) (*dtsBundleModule, error) {
+	if m, ok := b.modules[pathname]; ok {
+		return m, nil
+	}
+	if len(b.modules) >= maxDtsBundleFiles {
+		return nil, errors.New("too many declaration files")
+	}
+	data, err := b.read(pathname)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	m := &dtsBundleModule{
+		pathname: pathname,
+		id:       fmt.Sprintf("__esm_dts_%d", len(b.modules)),
+	}
+	b.modules[pathname] = m
+	// resolve the relative specifiers to the pathnames with the dts lexer, the reference directives are hoisted to
+	// the top of the bundle
+	buf, recycle := newBuffer()
+	defer recycle()
+	err = parseDts(bytes.NewReader(data), buf, func(specifier string, kind TsImportKind, position int) (string, error) {
+		switch kind {
+		case TsReferencePath, TsReferenceTypes:
+			attr := "types"
+			if kind == TsReferencePath {
+				attr = "path"
+			}
+			if isRelPathSpecifier(specifier) {
+				specifier = "{ESM_CDN_ORIGIN}" + path.Join(path.Dir(pathname), specifier)
+			}
+			b.addRef(fmt.Sprintf(`/// <reference %s="%s" />`, attr, specifier))
+		case TsImportDecl, TsImportCall:
+			if isRelPathSpecifier(specifier) {
+				return path.Join(path.Dir(pathname), specifier), nil
+			}
+		}
+		return specifier, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.stmts = parseDtsStmts(buf.Bytes())
+	return m, nil
+}
+
+// require returns the module of the relative specifier, returns nil if the specifier is not relative or the
+// file does not exist.
+func (b *dtsBundler) require(specifier string) (*dtsBundleModule, error) {
+	if !strings.HasPrefix(specifier, "/") {
+		return nil, nil
+	}
+	dep, err := b.load(specifier)
+	if err != nil || dep == nil || dep.id == "" {
+		// the circular import of the entry module is kept as the url
+		return nil, err
+	}
+	if !dep.queued {
+		dep.queued = true
+		b.queue = append(b.queue, dep)
+	}
+	return dep, nil
+}
+
+// resolveUrl returns the url of the specifier, the relative specifier is resolved to the separated `.d.ts` file.
+func (b *dtsBundler) resolveUrl(specifier string) string {
+	if strings.HasPrefix(specifier, "/") {
+		return "{ESM_CDN_ORIGIN}" + specifier
+	}
+	return specifier
+}
+
+// emit writes the statements of the module, the entry module(id is empty) is written at the top level.
+func (b *dtsBundler) emit(w *bytes.Buffer, m *dtsBundleModule) error {
+	entry := m.id == ""
+	_, err := b.getExports(m, nil)
+	if err != nil {
+		return err
+	}
+	// the local exports take precedence over the names re-exported by `export *`
+	exported := map[string]bool{}
+	for _, name := range m.locals {
+		exported[name] = true
+	}
+
+	for _, s := range m.stmts {
+		if s.code == "" {
+			w.WriteString(s.trivia)
+			continue
+		}
+		code := regexpImportCallExpr.ReplaceAllStringFunc(s.code, func(call string) string {
+			q := call[len(call)-2]
+			i := strings.IndexByte(call, q)
+			specifier := call[i+1 : len(call)-2]
+			return call[:i+1] + b.resolveUrl(specifier) + call[len(call)-2:]
+		})
+		if s.specifier != "" {
+			for _, q := range []string{`"`, `'`} {
+				code = strings.Replace(code, q+s.specifier+q, q+b.resolveUrl(s.specifier)+q, 1)
+			}
+		}
+		ret, err := b.rewriteStmt(m, s, code, exported)
+		if err != nil {
+			return err
+		}
+		if ret != "" {
+			w.WriteString(strings.TrimPrefix(s.trivia, "\n"))
+			w.WriteString(ret)
+			w.WriteByte('\n')
+		}
+	}
+	if !entry {
+		for _, star := range m.stars {
+			b.addImport(fmt.Sprintf(`export * from "%s";`, star))
+		}
+	}
+	return nil
+}
+
+// rewriteStmt rewrites the statement of the module, returns an empty string to drop the statement.
+func (b *dtsBundler) rewriteStmt(m *dtsBundleModule, s *dtsStmt, code string, exported map[string]bool) (string, error) {
+	entry := m.id == ""
+
+	switch s.kind {
+	case dtsStmtImport:
+		dep, err := b.require(s.specifier)
+		if err != nil {
+			return "", err
+		}
+		def, ns, named := parseDtsImportClause(s.clause)
+		if dep != nil {
+			var aliases []string
+			if def != "" {
+				aliases = append(aliases, fmt.Sprintf("%simport %s = %s.__default;", m.aliasPrefix(def), def, dep.id))
+			}
+			if ns != "" {
+				aliases = append(aliases, fmt.Sprintf("%simport %s = %s;", m.aliasPrefix(ns), ns, dep.id))
+			}
+			for _, n := range named {
+				aliases = append(aliases, fmt.Sprintf("%simport %s = %s.%s;", m.aliasPrefix(n[1]), n[1], dep.id, dtsMemberName(n[0])))
+			}
+			return strings.Join(aliases, "\n"), nil
+		}
+		if entry {
+			return code, nil
+		}
+		// hoist the import declaration with prefixed names
+		var aliases []string
+		var clause []string
+		if def != "" {
+			clause = append(clause, m.id+"$"+def)
+			aliases = append(aliases, fmt.Sprintf("%simport %s = %s$%s;", m.aliasPrefix(def), def, m.id, def))
+		}
+		if ns != "" {
+			clause = append(clause, "* as "+m.id+"$"+ns)
+			aliases = append(aliases, fmt.Sprintf("%simport %s = %s$%s;", m.aliasPrefix(ns), ns, m.id, ns))
+		}
+		if len(named) > 0 {
+			items := make([]string, len(named))
+			for i, n := range named {
+				items[i] = n[0] + " as " + m.id + "$" + n[1]
+				aliases = append(aliases, fmt.Sprintf("%simport %s = %s$%s;", m.aliasPrefix(n[1]), n[1], m.id, n[1]))
+			}
+			clause = append(clause, "{ "+strings.Join(items, ", ")+" }")
+		}
+		if len(clause) > 0 {
+			b.addImport(fmt.Sprintf(`import %s from "%s";`, strings.Join(clause, ", "), b.resolveUrl(s.specifier)))
+		}
+		return strings.Join(aliases, "\n"), nil
+
+	case dtsStmtImportBare:
+		b.addImport(fmt.Sprintf(`import "%s";`, b.resolveUrl(s.specifier)))
+		return "", nil
+
+	case dtsStmtImportRequire:
+		export := ""
+		if s.exported {
+			export = "export "
+		}
+		dep, err := b.require(s.specifier)
+		if err != nil {
+			return "", err
+		}
+		if dep != nil {
+			return fmt.Sprintf("%simport %s = %s;", export, s.name, dep.id), nil
+		}
+		if entry {
+			return code, nil
+		}
+		b.addImport(fmt.Sprintf(`import %s$%s = require("%s");`, m.id, s.name, b.resolveUrl(s.specifier)))
+		return fmt.Sprintf("%simport %s = %s$%s;", export, s.name, m.id, s.name), nil
+
+	case dtsStmtExportFrom:
+		dep, err := b.require(s.specifier)
+		if err != nil {
+			return "", err
+		}
+		if dep == nil && entry {
+			return code, nil
+		}
+		target := dep
+		if dep == nil {
+			target = &dtsBundleModule{id: m.id + "$" + fmt.Sprintf("%x", len(b.imports))}
+		}
+		var lines []string
+		if strings.HasPrefix(s.clause, "*") {
+			if ns, ok := strings.CutPrefix(strings.TrimSpace(s.clause[1:]), "as"); ok {
+				ns = strings.TrimSpace(ns)
+				if dep == nil {
+					b.addImport(fmt.Sprintf(`import * as %s from "%s";`, target.id, b.resolveUrl(s.specifier)))
+				}
+				return fmt.Sprintf("export import %s = %s;", ns, target.id), nil
+			}
+			if dep == nil {
+				// the `export *` of the non-relative module is hoisted to the top level by the `emit` method
+				m.stars = append(m.stars, b.resolveUrl(s.specifier))
+				return "", nil
+			}
+			names, err := b.getExports(dep, nil)
+			if err != nil {
+				return "", err
+			}
+			for _, name := range names {
+				if !exported[name] && name != "default" {
+					exported[name] = true
+					lines = append(lines, fmt.Sprintf("export import %s = %s.%s;", name, dep.id, name))
+				}
+			}
+			if entry {
+				for _, star := range b.getStars(dep, nil) {
+					lines = append(lines, fmt.Sprintf(`export * from "%s";`, star))
+				}
+			}
+			return strings.Join(lines, "\n"), nil
+		}
+		_, _, named := parseDtsImportClause(s.clause)
+		if dep == nil {
+			items := make([]string, len(named))
+			for i, n := range named {
+				items[i] = n[0] + " as " + target.id + "$" + dtsMemberName(n[1])
+			}
+			b.addImport(fmt.Sprintf(`import { %s } from "%s";`, strings.Join(items, ", "), b.resolveUrl(s.specifier)))
+		}
+		for _, n := range named {
+			source := target.id + "." + dtsMemberName(n[0])
+			if dep == nil {
+				source = target.id + "$" + dtsMemberName(n[1])
+			}
+			if n[1] == "default" {
+				if entry {
+					lines = append(lines, fmt.Sprintf("export default %s;", source))
+				} else {
+					lines = append(lines, fmt.Sprintf("export import __default = %s;", source))
+				}
+			} else {
+				lines = append(lines, fmt.Sprintf("export import %s = %s;", n[1], source))
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	if entry {
+		return code, nil
+	}
+
+	// the following statements are rewritten for the namespace blocks
+	switch s.kind {
+	case dtsStmtExportAssign:
+		return "", errDtsBundleUnsupported
+	case dtsStmtExportNamespace:
+		return "", nil
+	case dtsStmtAmbientModule:
+		b.globals = append(b.globals, trimDtsModifiers(code, "export"))
+		return "", nil
+	case dtsStmtExportList:
+		_, _, named := parseDtsImportClause(s.clause)
+		var lines []string
+		for _, n := range named {
+			if n[1] == "default" {
+				lines = append(lines, fmt.Sprintf("export import __default = %s;", n[0]))
+			} else if n[0] != n[1] {
+				lines = append(lines, fmt.Sprintf("export import %s = %s;", n[1], n[0]))
+			}
+			// the local declarations are exported from the namespace, see below
+		}
+		return strings.Join(lines, "\n"), nil
+	case dtsStmtExportDefault:
+		rest := trimDtsModifiers(code, "export", "default", "declare")
+		if kw, name := parseDtsDecl(rest); name != "" && (kw == "class" || kw == "function" || kw == "interface") {
+			return fmt.Sprintf("export %s\nexport import __default = %s;", rest, name), nil
+		}
+		for _, kw := range []string{"function", "abstract class", "class"} {
+			if strings.HasPrefix(rest, kw) {
+				return "export " + kw + " __default" + rest[len(kw):], nil
+			}
+		}
+		expr := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), ";"))
+		if isDtsEntityName(expr) {
+			return fmt.Sprintf("export import __default = %s;", expr), nil
+		}
+		return "", errDtsBundleUnsupported
+	case dtsStmtDecl:
+		// strip the `declare` modifier in the namespace block, and export all the declarations
+		return "export " + trimDtsModifiers(code, "export", "declare"), nil
+	}
+	return code, nil
+}
+
+// getExports returns the exported names of the module, the `export *` of the relative modules are expanded.
+func (b *dtsBundler) getExports(m *dtsBundleModule, visited map[*dtsBundleModule]bool) ([]string, error) {
+	if m.exports != nil {
+		return m.exports, nil
+	}
+	if visited == nil {
+		visited = map[*dtsBundleModule]bool{}
+	}
+	if visited[m] {
+		return nil, nil
+	}
+	visited[m] = true
+
+	var names []string
+	var locals []string
+	var decls []string
+	var stars []*dtsBundleModule
+	explicit := false
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			locals = append(locals, name)
+		}
+	}
+	for _, s := range m.stmts {
+		switch s.kind {
+		case dtsStmtExportFrom:
+			if strings.HasPrefix(s.clause, "*") {
+				if ns, ok := strings.CutPrefix(strings.TrimSpace(s.clause[1:]), "as"); ok {
+					add(strings.TrimSpace(ns))
+				} else if strings.HasPrefix(s.specifier, "/") {
+					dep, err := b.load(s.specifier)
+					if err != nil {
+						return nil, err
+					}
+					if dep != nil {
+						stars = append(stars, dep)
+					}
+				}
+			} else {
+				_, _, named := parseDtsImportClause(s.clause)
+				for _, n := range named {
+					add(n[1])
+				}
+			}
+			explicit = true
+		case dtsStmtExportList:
+			_, _, named := parseDtsImportClause(s.clause)
+			for _, n := range named {
+				add(n[1])
+			}
+			explicit = true
+		case dtsStmtExportDefault:
+			add("default")
+		case dtsStmtImportAlias, dtsStmtImportRequire, dtsStmtDecl:
+			if s.exported {
+				add(s.name)
+			} else if s.kind == dtsStmtDecl {
+				decls = append(decls, s.name)
+			}
+		}
+	}
+	// the declarations in a `.d.ts` file are exported implicitly if there is no `export {}` statement
+	if !explicit {
+		for _, name := range decls {
+			add(name)
+		}
+	}
+	names = append(names, locals...)
+	for _, dep := range stars {
+		depNames, err := b.getExports(dep, visited)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range depNames {
+			if name != "default" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	if names == nil {
+		names = []string{}
+	}
+	m.exports = names
+	m.locals = locals
+	return names, nil
+}
+
+// getStars returns the non-relative modules re-exported by `export *` of the module.
+func (b *dtsBundler) getStars(m *dtsBundleModule, visited map[*dtsBundleModule]bool) (stars []string) {
+	if visited == nil {
+		visited = map[*dtsBundleModule]bool{}
+	}
+	if visited[m] {
+		return
+	}
+	visited[m] = true
+	for _, s := range m.stmts {
+		if s.kind == dtsStmtExportFrom && s.clause == "*" {
+			if strings.HasPrefix(s.specifier, "/") {
+				if dep := b.modules[s.specifier]; dep != nil {
+					stars = append(stars, b.getStars(dep, visited)...)
+				}
+			} else {
+				stars = append(stars, s.specifier)
+			}
+		}
+	}
+	return
+}
+
+func (b *dtsBundler) addRef(ref string) {
+	for _, r := range b.refs {
+		if r == ref {
+			return
+		}
+	}
+	b.refs = append(b.refs, ref)
+}
+
+func (b *dtsBundler) addImport(imp string) {
+	for _, i := range b.imports {
+		if i == imp {
+			return
+		}
+	}
+	b.imports = append(b.imports, imp)
+}
+
+// parseDtsStmts splits the top-level statements of a `.d.ts` file processed by the `parseDts` function. A statement
+// starts at the beginning of a line with a declaration keyword as emitted by tsc, the leading comments and spaces are
+// kept with the following statement, and the reference directives are dropped.
+func parseDtsStmts(dts []byte) (stmts []*dtsStmt) {
+	var code, trivia strings.Builder
+	var comment bool
+	flush := func() {
+		if code.Len() > 0 {
+			stmts = append(stmts, parseDtsStmt(trivia.String(), strings.TrimRight(code.String(), " \t\r\n")))
+			trivia.Reset()
+			code.Reset()
+		}
+	}
+	var tail strings.Builder // the comments and empty lines after the current statement
+	for _, line := range strings.SplitAfter(string(dts), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if comment || trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*") {
+			if strings.HasPrefix(trimmed, "/*") {
+				comment = true
+			}
+			if comment && strings.Contains(trimmed, "*/") {
+				comment = false
+			}
+			if strings.HasPrefix(trimmed, "///") && regexpTSReferenceTag.MatchString(trimmed[3:]) {
+				continue
+			}
+			tail.WriteString(line)
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' && isDtsStmtKeyword(peekDtsToken(trimmed)) {
+			flush()
+			trivia.WriteString(tail.String())
+		} else if code.Len() == 0 {
+			trivia.WriteString(tail.String())
+		} else {
+			code.WriteString(tail.String())
+		}
+		tail.Reset()
+		code.WriteString(line)
+	}
+	flush()
+	if tail.Len() > 0 {
+		stmts = append(stmts, &dtsStmt{trivia: tail.String()})
+	}
+	return
+}
+
+// parseDtsStmt parses the top-level statement by the leading tokens.
+func parseDtsStmt(trivia string, code string) *dtsStmt {
+	s := &dtsStmt{trivia: trivia, code: code}
+	clean := strings.TrimSpace(regexpDtsComment.ReplaceAllString(code, " "))
+	t0, rest := cutDtsToken(clean)
+	t1, rest1 := cutDtsToken(rest)
+	switch t0 {
+	case "import":
+		if t1 == `"` || t1 == "'" {
+			s.kind = dtsStmtImportBare
+			s.specifier = dtsQuoted(rest)
+		} else if loc := regexpFromExpr.FindStringIndex(clean); loc != nil {
+			s.kind = dtsStmtImport
+			s.clause = strings.TrimSpace(clean[len("import") : loc[0]+1])
+			if t1 == "type" {
+				if t2, _ := cutDtsToken(rest1); t2 != "," && t2 != "from" {
+					s.clause = strings.TrimSpace(strings.TrimPrefix(s.clause, "type"))
+				}
+			}
+			s.specifier = dtsQuoted(clean[loc[1]-1:])
+		} else {
+			s.parseImportEquals(rest)
+		}
+	case "export":
+		switch t1 {
+		case "=":
+			s.kind = dtsStmtExportAssign
+		case "as":
+			s.kind = dtsStmtExportNamespace
+		case "default":
+			s.kind = dtsStmtExportDefault
+		case "import":
+			s.parseImportEquals(rest1)
+			s.exported = true
+			if s.kind == dtsStmtOther && s.name != "" {
+				s.kind = dtsStmtImportAlias
+			}
+		default:
+			if a := regexpExportDecl.FindStringSubmatchIndex(clean); a != nil {
+				clause := clean[a[4]:]
+				if loc := regexpFromExpr.FindStringIndex(clean); loc != nil {
+					s.kind = dtsStmtExportFrom
+					s.clause = strings.TrimSpace(clean[a[4] : loc[0]+1])
+					s.specifier = dtsQuoted(clean[loc[1]-1:])
+				} else {
+					s.kind = dtsStmtExportList
+					s.clause = strings.TrimSuffix(clause, ";")
+				}
+			} else if isDtsAmbientModule(rest1) && t1 == "declare" {
+				s.kind = dtsStmtAmbientModule
+			} else if _, name := parseDtsDecl(rest); name != "" {
+				s.kind = dtsStmtDecl
+				s.name = name
+				s.exported = true
+			}
+		}
+	case "declare":
+		if isDtsAmbientModule(rest) {
+			s.kind = dtsStmtAmbientModule
+		} else if _, name := parseDtsDecl(clean); name != "" {
+			s.kind = dtsStmtDecl
+			s.name = name
+		}
+	default:
+		if _, name := parseDtsDecl(clean); name != "" {
+			s.kind = dtsStmtDecl
+			s.name = name
+		}
+	}
+	return s
+}
+
+// parseImportEquals parses the `import Foo = require("...")` or `import Foo = Bar.Foo` statement, the code is after
+// the `import` keyword.
+func (s *dtsStmt) parseImportEquals(code string) {
+	name, rest := cutDtsToken(code)
+	if eq, rest := cutDtsToken(rest); isDtsEntityName(name) && eq == "=" {
+		s.name = name
+		if fn, rest := cutDtsToken(rest); fn == "require" {
+			s.kind = dtsStmtImportRequire
+			s.specifier = dtsQuoted(rest)
+		}
+	}
+}
+
+// parseDtsDecl returns the keyword and the name of the declaration, e.g. `declare function foo(): void`.
+func parseDtsDecl(code string) (keyword string, name string) {
+	token, rest := cutDtsToken(code)
+	for token == "export" || token == "declare" || token == "abstract" {
+		token, rest = cutDtsToken(rest)
+	}
+	if !isDtsStmtKeyword(token) || token == "import" || token == "export" || token == "declare" {
+		return "", ""
+	}
+	keyword = token
+	name, rest = cutDtsToken(rest)
+	if (keyword == "const" && name == "enum") || (keyword == "function" && name == "*") {
+		name, _ = cutDtsToken(rest)
+	}
+	if !isDtsEntityName(name) {
+		return keyword, ""
+	}
+	return keyword, name
+}
+
+// isDtsAmbientModule checks if the code after the `declare` keyword is a `global` or `module "..."` block.
+func isDtsAmbientModule(code string) bool {
+	token, rest := cutDtsToken(code)
+	if token == "global" {
+		return true
+	}
+	q, _ := cutDtsToken(rest)
+	return token == "module" && (q == `"` || q == "'")
+}
+
+// isDtsStmtKeyword checks if the token starts a top-level statement of a `.d.ts` file.
+func isDtsStmtKeyword(token string) bool {
+	switch token {
+	case "import", "export", "declare", "interface", "type", "class", "abstract", "function", "const", "let", "var", "enum", "namespace", "module":
+		return true
+	}
+	return false
+}
+
+// isDtsEntityName checks if the name is an identifier or a qualified name, e.g. `Foo.Bar`.
+func isDtsEntityName(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return false
+		}
+		for _, c := range part {
+			if !(c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cutDtsToken cuts the first token of the code after the spaces, the token is an identifier or a single character.
+func cutDtsToken(code string) (token string, rest string) {
+	code = strings.TrimLeft(code, " \t\r\n")
+	if code == "" {
+		return "", ""
+	}
+	i := 0
+	for i < len(code) && isDtsEntityName(code[i:i+1]) {
+		i++
+	}
+	if i == 0 {
+		i = 1
+	}
+	return code[:i], code[i:]
+}
+
+// peekDtsToken returns the first token of the code.
+func peekDtsToken(code string) string {
+	token, _ := cutDtsToken(code)
+	return token
+}
+
+// dtsQuoted returns the content of the first string literal in the code.
+func dtsQuoted(code string) string {
+	i := strings.IndexAny(code, `"'`)
+	if i < 0 {
+		return ""
+	}
+	j := strings.IndexByte(code[i+1:], code[i])
+	if j < 0 {
+		return ""
+	}
+	return code[i+1 : i+1+j]
+}
+
+// trimDtsModifiers trims the leading modifiers of the code, e.g. `export declare const foo: string`.
+func trimDtsModifiers(code string, modifiers ...string) string {
+	for {
+		token, rest := cutDtsToken(code)
+		if !slices.Contains(modifiers, token) {
+			return code
+		}
+		code = strings.TrimLeft(rest, " \t\r\n")
+	}
+}
+
+// parseDtsImportClause parses the import clause, e.g. `React, { useState as useS, type FC }`.
+func parseDtsImportClause(clause string) (def string, ns string, named [][2]string) {
+	clause = strings.TrimSpace(regexpDtsComment.ReplaceAllString(clause, " "))
+	if i := strings.IndexByte(clause, '{'); i >= 0 {
+		j := strings.LastIndexByte(clause, '}')
+		if j < i {
+			j = len(clause)
+		}
+		for _, item := range strings.Split(clause[i+1:j], ",") {
+			item = strings.TrimSpace(item)
+			item = strings.TrimSpace(strings.TrimPrefix(item, "type "))
+			if item == "" {
+				continue
+			}
+			fields := strings.Fields(item)
+			if len(fields) == 3 && fields[1] == "as" {
+				named = append(named, [2]string{fields[0], fields[2]})
+			} else {
+				named = append(named, [2]string{fields[0], fields[0]})
+			}
+		}
+		clause = clause[:i] + clause[min(j+1, len(clause)):]
+	}
+	for _, part := range strings.Split(clause, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(part, "*"); ok {
+			ns = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), "as"))
+		} else {
+			def = part
+		}
+	}
+	return
+}
+
+// aliasPrefix returns the `export` keyword if the imported name is re-exported by the `export {}` statement of the
+// namespace block.
+func (m *dtsBundleModule) aliasPrefix(name string) string {
+	if m.id != "" && slices.Contains(m.locals, name) {
+		return "export "
+	}
+	return ""
+}
+
+// dtsMemberName returns the member name of the namespace block, the `default` export is renamed to `__default`.
+func dtsMemberName(name string) string {
+	if name == "default" {
+		return "__default"
+	}
+	return name
+}
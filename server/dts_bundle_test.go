@@ -0,0 +1,129 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/esm-dev/esm.sh/internal/storage"
+)
+
+func TestParseDtsStmts(t *testing.T) {
+	const dts = `/// <reference types="node" />
+import type { A } from "/pkg@1.0.0/a.d.ts";
+// comment
+export interface Foo {
+  a: A;
+  // member comment
+  b: string
+}
+export type Bar = "a;b" | ` + "`c${string}`" + `
+  | "d"
+declare function foo(
+  a: string,
+): void
+export { foo }
+export import Baz = require("/pkg@1.0.0/baz.d.ts");
+export * as utils from "{ESM_CDN_ORIGIN}/utils@1.0.0/index.d.ts";
+`
+	stmts := parseDtsStmts([]byte(dts))
+	if len(stmts) != 7 {
+		for _, s := range stmts {
+			t.Logf("%q", s.code)
+		}
+		t.Fatalf("expected 7 statements, got %d", len(stmts))
+	}
+	if s := stmts[0]; s.kind != dtsStmtImport || s.clause != "{ A }" || s.specifier != "/pkg@1.0.0/a.d.ts" || strings.Contains(s.trivia, "reference") {
+		t.Fatalf("unexpected statement: %+v", s)
+	}
+	if s := stmts[1]; s.kind != dtsStmtDecl || s.name != "Foo" || !s.exported || s.trivia != "// comment\n" || !strings.Contains(s.code, "// member comment") {
+		t.Fatalf("unexpected statement: %+v", s)
+	}
+	if s := stmts[2]; s.kind != dtsStmtDecl || s.name != "Bar" || !strings.HasSuffix(s.code, `| "d"`) {
+		t.Fatalf("unexpected statement: %+v", s)
+	}
+	if s := stmts[3]; s.kind != dtsStmtDecl || s.name != "foo" || s.exported {
+		t.Fatalf("unexpected statement: %+v", s)
+	}
+	if s := stmts[4]; s.kind != dtsStmtExportList || s.clause != "{ foo }" {
+		t.Fatalf("unexpected statement: %+v", s)
+	}
+	if s := stmts[5]; s.kind != dtsStmtImportRequire || s.name != "Baz" || !s.exported || s.specifier != "/pkg@1.0.0/baz.d.ts" {
+		t.Fatalf("unexpected statement: %+v", s)
+	}
+	if s := stmts[6]; s.kind != dtsStmtExportFrom || s.clause != "* as utils" || s.specifier != "{ESM_CDN_ORIGIN}/utils@1.0.0/index.d.ts" {
+		t.Fatalf("unexpected statement: %+v", s)
+	}
+}
+
+func TestBundleDTS(t *testing.T) {
+	files := map[string]string{
+		"/pkg@1.0.0/index.d.ts": `/// <reference path="./global.d.ts" />
+import { Foo } from "./foo.d.ts";
+export * from "./utils.d.ts";
+export { default as bar, type Bar } from "./bar.d.ts";
+export declare function useFoo(): Foo;
+`,
+		"/pkg@1.0.0/foo.d.ts": `import type { Options } from "{ESM_CDN_ORIGIN}/dep@2.0.0/index.d.ts";
+export interface Foo {
+  options: Options;
+}
+`,
+		"/pkg@1.0.0/utils.d.ts": `export declare const VERSION: string;
+export * from "./internal.d.ts";
+`,
+		"/pkg@1.0.0/internal.d.ts": `declare const internalFlag: boolean;
+type Internal = number;
+export { internalFlag, Internal as InternalNumber };
+`,
+		"/pkg@1.0.0/bar.d.ts": `import { Foo } from "./foo.d.ts";
+export interface Bar {
+  foo: Foo;
+}
+export default function bar(): Bar;
+`,
+	}
+	dts, err := bundleDTS("/pkg@1.0.0/index.d.ts", func(pathname string) ([]byte, error) {
+		if content, ok := files[pathname]; ok {
+			return []byte(content), nil
+		}
+		return nil, storage.ErrNotFound
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(dts)
+	for _, s := range []string{
+		`/// <reference path="{ESM_CDN_ORIGIN}/pkg@1.0.0/global.d.ts" />`,
+		`import { Options as __esm_dts_3$Options } from "{ESM_CDN_ORIGIN}/dep@2.0.0/index.d.ts";`,
+		`import Foo = __esm_dts_3.Foo;`,
+		`export import VERSION = __esm_dts_1.VERSION;`,
+		`export import internalFlag = __esm_dts_1.internalFlag;`,
+		`export import InternalNumber = __esm_dts_1.InternalNumber;`,
+		`export import bar = __esm_dts_4.__default;`,
+		`export import Bar = __esm_dts_4.Bar;`,
+		`export declare function useFoo(): Foo;`,
+		"declare namespace __esm_dts_3 {\nimport Options = __esm_dts_3$Options;\nexport interface Foo {",
+		`export import InternalNumber = Internal;`,
+		`export const internalFlag: boolean;`,
+		"export function bar(): Bar;\nexport import __default = bar;",
+	} {
+		if !strings.Contains(code, s) {
+			t.Fatalf("missing %q in bundled dts:\n%s", s, code)
+		}
+	}
+	if strings.Contains(code, "./") {
+		t.Fatalf("unexpected relative specifier in bundled dts:\n%s", code)
+	}
+
+	// the storage errors other than "not found" must not be ignored
+	_, err = bundleDTS("/pkg@1.0.0/index.d.ts", func(pathname string) ([]byte, error) {
+		if pathname == "/pkg@1.0.0/index.d.ts" {
+			return []byte(files[pathname]), nil
+		}
+		return nil, errors.New("storage unavailable")
+	})
+	if err == nil || err.Error() != "storage unavailable" {
+		t.Fatalf("expected the storage error, got %v", err)
+	}
+}
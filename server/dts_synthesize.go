@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/esm-dev/esm.sh/internal/storage"
+)
+
+// synthesizeDTS generates an `any`-typed declaration file for the untyped module with the export names collected by
+// the lexer, returns an empty string if the module exports nothing. The file is regenerated by the `buildTypes`
+// method if it is requested but missing in the storage.
+func (ctx *BuildContext) synthesizeDTS(exports []string, exportDefault bool) (string, error) {
+	dts := synthesizeDts(exports, exportDefault)
+	if dts == nil {
+		return "", nil
+	}
+
+	name := "index"
+	if ctx.esmPath.SubModuleName != "" {
+		name = ctx.esmPath.SubModuleName
+	}
+	dtsPath := fmt.Sprintf(
+		"/%s/%s%s.synthesized.d.ts",
+		ctx.esmPath.Name(),
+		ctx.getBuildArgsPrefix(true),
+		name,
+	)
+	savePath := normalizeSavePath(ctx.npmrc.zoneId, path.Join("types", dtsPath))
+	_, err := ctx.storage.Stat(savePath)
+	if err == nil {
+		return dtsPath, nil
+	}
+	if err != storage.ErrNotFound {
+		return "", err
+	}
+	err = ctx.storage.Put(savePath, bytes.NewReader(dts))
+	if err != nil {
+		ctx.logger.Errorf("storage.put(%s): %v", savePath, err)
+		return "", errors.New("storage: " + err.Error())
+	}
+	return dtsPath, nil
+}
+
+// synthesizeDts generates the declarations of the given export names, the capitalized names are declared as both
+// value and type since they are likely to be classes.
+func synthesizeDts(exports []string, exportDefault bool) []byte {
+	names := make([]string, 0, len(exports))
+	for _, name := range exports {
+		if name == "default" {
+			exportDefault = true
+		} else if isJsIdentifier(name) && !isJsReservedWord(name) && name != "__esModule" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && !exportDefault {
+		return nil
+	}
+	slices.Sort(names)
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("/* esm.sh - synthesized declarations of the untyped module */\n")
+	for _, name := range names {
+		fmt.Fprintf(buf, "export declare const %s: any;\n", name)
+		if c := name[0]; c >= 'A' && c <= 'Z' {
+			fmt.Fprintf(buf, "export type %s = any;\n", name)
+		}
+	}
+	if exportDefault {
+		buf.WriteString("declare const __default: any;\n")
+		buf.WriteString("export default __default;\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSynthesizeDts(t *testing.T) {
+	dts := string(synthesizeDts([]string{"foo", "Bar", "default", "__esModule", "foo-bar", "delete", "foo"}, false))
+	for _, s := range []string{
+		"export declare const foo: any;\n",
+		"export declare const Bar: any;\nexport type Bar = any;\n",
+		"declare const __default: any;\nexport default __default;\n",
+	} {
+		if !strings.Contains(dts, s) {
+			t.Fatalf("missing %q in synthesized dts:\n%s", s, dts)
+		}
+	}
+	for _, s := range []string{"__esModule", "foo-bar", "delete"} {
+		if strings.Contains(dts, s) {
+			t.Fatalf("unexpected %q in synthesized dts:\n%s", s, dts)
+		}
+	}
+	if strings.Count(dts, "const foo:") != 1 {
+		t.Fatalf("duplicate declarations in synthesized dts:\n%s", dts)
+	}
+	if synthesizeDts(nil, false) != nil {
+		t.Fatal("expected nil for the module without exports")
+	}
+}
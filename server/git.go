@@ -3,11 +3,17 @@ package server
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/esm-dev/esm.sh/internal/fetch"
@@ -19,14 +25,92 @@ type GitRef struct {
 	Sha string
 }
 
+// GitHost defines a git hosting service other than GitHub.
+type GitHost struct {
+	// Type is one of "gitlab", "bitbucket", "gitea" or "git" (plain git over https or file://)
+	Type  string `json:"type"`
+	Url   string `json:"url"`
+	Token string `json:"token"`
+	User  string `json:"user"`
+}
+
+var knownGitHosts = map[string]GitHost{
+	"gitlab.com":    {Type: "gitlab"},
+	"bitbucket.org": {Type: "bitbucket"},
+	"codeberg.org":  {Type: "gitea"},
+	"gitea.com":     {Type: "gitea"},
+}
+
+// getGitHost returns the git host by the given host name, only the hosts in config and the known hosts are allowed.
+func getGitHost(host string) (gitHost GitHost, ok bool) {
+	gitHost, ok = config.GitHosts[host]
+	if !ok {
+		gitHost, ok = knownGitHosts[host]
+	}
+	if ok {
+		if gitHost.Url == "" {
+			gitHost.Url = "https://" + host
+		}
+		if gitHost.Type == "" {
+			gitHost.Type = "git"
+		}
+	}
+	return
+}
+
+// repoUrl returns the clone url of the given repository.
+func (h *GitHost) repoUrl(repo string) string {
+	return h.Url + "/" + repo + ".git"
+}
+
+// gitAuthHeader returns the `Authorization` header used by the git http protocol.
+func (h *GitHost) gitAuthHeader() string {
+	if h.Token == "" {
+		return ""
+	}
+	user := h.User
+	if user == "" {
+		switch h.Type {
+		case "gitlab":
+			user = "oauth2"
+		case "bitbucket":
+			user = "x-token-auth"
+		default:
+			user = "git"
+		}
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+h.Token))
+}
+
+// list refs of a repository hosted by the git host
+func (h *GitHost) listRepoRefs(repo string) (refs []GitRef, err error) {
+	return listGitRepoRefs(h.repoUrl(repo), h.gitAuthHeader())
+}
+
 // list refs of a github repository using `git ls-remote repo`
-func listGhRepoRefs(repo string) (refs []GitRef, err error) {
-	return withCache("git ls-remote "+repo, time.Duration(config.NpmQueryCacheTTL)*time.Second, func() ([]GitRef, string, error) {
+func listGhRepoRefs(repo string, token string) (refs []GitRef, err error) {
+	var authHeader string
+	if token != "" {
+		authHeader = "Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:"+token))
+	}
+	return listGitRepoRefs(repo, authHeader)
+}
+
+// list refs of a git repository using `git ls-remote repo`
+func listGitRepoRefs(repo string, authHeader string) (refs []GitRef, err error) {
+	cacheKey := "git ls-remote " + repo
+	if authHeader != "" {
+		// the refs of private repositories must not be shared between different credentials
+		h := sha1.Sum([]byte(authHeader))
+		cacheKey += " " + hex.EncodeToString(h[:])
+	}
+	return withCache(cacheKey, time.Duration(config.NpmQueryCacheTTL)*time.Second, func() ([]GitRef, string, error) {
 		stdout, recycle := newBuffer()
 		defer recycle()
 		errout, recycle := newBuffer()
 		defer recycle()
 		cmd := exec.Command("git", "ls-remote", repo)
+		cmd.Env = gitEnv(authHeader)
 		cmd.Stdout = stdout
 		cmd.Stderr = errout
 		err = cmd.Run()
@@ -58,14 +142,21 @@ func listGhRepoRefs(repo string) (refs []GitRef, err error) {
 	})
 }
 
-func ghInstall(wd, name, tag string) (err error) {
-	u, err := url.Parse(fmt.Sprintf("https://codeload.github.com/%s/tar.gz/%s", name, tag))
+func ghInstall(wd, name, tag string, token string) (err error) {
+	tarballUrl := fmt.Sprintf("https://codeload.github.com/%s/tar.gz/%s", name, tag)
+	header := http.Header{}
+	if token != "" {
+		// private repositories are downloaded via the github api that redirects to a signed codeload url
+		tarballUrl = fmt.Sprintf("https://api.github.com/repos/%s/tarball/%s", name, tag)
+		header.Set("Authorization", "Bearer "+token)
+	}
+	u, err := url.Parse(tarballUrl)
 	if err != nil {
 		return
 	}
 	client, recycle := fetch.NewClient("esmd/"+VERSION, 30, false)
 	defer recycle()
-	res, err := client.Fetch(u, nil)
+	res, err := client.Fetch(u, header)
 	if err != nil {
 		return
 	}
@@ -82,3 +173,143 @@ func ghInstall(wd, name, tag string) (err error) {
 	err = extractPackageTarball(wd, name, io.LimitReader(res.Body, maxPackageTarballSize))
 	return
 }
+
+// gitInstall installs a repository from the git host, the `version` can be a commit sha, a tag or a branch.
+func gitInstall(wd string, gitHost GitHost, repo string, version string) (err error) {
+	ref := version
+	refs, err := gitHost.listRepoRefs(repo)
+	if err != nil {
+		return
+	}
+	if sha := resolveGitRef(refs, version); sha != "" {
+		ref = sha
+	}
+
+	if gitHost.Type == "git" || strings.HasPrefix(gitHost.Url, "file://") {
+		return gitArchiveInstall(wd, gitHost, repo, ref)
+	}
+
+	header := http.Header{}
+	var archiveUrl string
+	switch gitHost.Type {
+	case "gitlab":
+		archiveUrl = fmt.Sprintf("%s/api/v4/projects/%s/repository/archive.tar.gz?sha=%s", gitHost.Url, url.PathEscape(repo), url.QueryEscape(ref))
+		if gitHost.Token != "" {
+			header.Set("PRIVATE-TOKEN", gitHost.Token)
+		}
+	case "bitbucket":
+		archiveUrl = fmt.Sprintf("%s/%s/get/%s.tar.gz", gitHost.Url, repo, url.PathEscape(ref))
+		if gitHost.Token != "" {
+			header.Set("Authorization", "Bearer "+gitHost.Token)
+		}
+	case "gitea":
+		archiveUrl = fmt.Sprintf("%s/api/v1/repos/%s/archive/%s.tar.gz", gitHost.Url, repo, url.PathEscape(ref))
+		if gitHost.Token != "" {
+			header.Set("Authorization", "token "+gitHost.Token)
+		}
+	default:
+		return fmt.Errorf("git: unsupported host type \"%s\"", gitHost.Type)
+	}
+
+	u, err := url.Parse(archiveUrl)
+	if err != nil {
+		return
+	}
+	client, recycle := fetch.NewClient("esmd/"+VERSION, 30, false)
+	defer recycle()
+	res, err := client.Fetch(u, header)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 || res.StatusCode == 401 || res.StatusCode == 403 {
+		return fmt.Errorf("git: repo \"%s\" or tag \"%s\" not found", repo, version)
+	}
+
+	if res.StatusCode != 200 {
+		return fmt.Errorf("fetch %s failed: %s", u.Redacted(), res.Status)
+	}
+
+	err = extractPackageTarball(wd, repo, io.LimitReader(res.Body, maxPackageTarballSize))
+	return
+}
+
+// gitArchiveInstall fetches the ref of a repository using `git fetch` and extracts it using `git archive`.
+func gitArchiveInstall(wd string, gitHost GitHost, repo string, ref string) (err error) {
+	gitDir, err := os.MkdirTemp("", "esm-git-")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(gitDir)
+
+	env := gitEnv(gitHost.gitAuthHeader())
+	run := func(stdout io.Writer, args ...string) error {
+		errout, recycle := newBuffer()
+		defer recycle()
+		cmd := exec.Command("git", append([]string{"--git-dir", gitDir}, args...)...)
+		cmd.Env = env
+		cmd.Stdout = stdout
+		cmd.Stderr = errout
+		err := cmd.Run()
+		if err != nil && errout.Len() > 0 {
+			return errors.New(strings.TrimSpace(errout.String()))
+		}
+		return err
+	}
+
+	err = run(nil, "init", "--bare", "-q")
+	if err != nil {
+		return
+	}
+	if len(ref) == 40 {
+		err = run(nil, "fetch", "-q", "--depth", "1", gitHost.repoUrl(repo), ref)
+	} else {
+		// the ref is a short commit sha that is not at the tip of any branch or tag, fetch all
+		err = run(nil, "fetch", "-q", gitHost.repoUrl(repo), "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+	}
+	if err != nil {
+		return fmt.Errorf("git: repo \"%s\" or tag \"%s\" not found", repo, ref)
+	}
+
+	tarball, recycle := newBuffer()
+	defer recycle()
+	err = run(tarball, "archive", "--format=tar.gz", "--prefix=package/", ref)
+	if err != nil {
+		return fmt.Errorf("git: repo \"%s\" or tag \"%s\" not found", repo, ref)
+	}
+	if tarball.Len() > maxPackageTarballSize {
+		return fmt.Errorf("git: repo \"%s\" is too large", repo)
+	}
+	return extractPackageTarball(wd, repo, tarball)
+}
+
+// resolveGitRef returns the full commit sha of the given version that can be a sha prefix, a tag or a branch.
+func resolveGitRef(refs []GitRef, version string) string {
+	// prefer the peeled commit (`^{}`) of annotated tags
+	for _, name := range []string{"refs/tags/" + version + "^{}", "refs/tags/" + version, "refs/tags/v" + version + "^{}", "refs/tags/v" + version, "refs/heads/" + version} {
+		for _, ref := range refs {
+			if ref.Ref == name {
+				return ref.Sha
+			}
+		}
+	}
+	if isCommitish(version) {
+		for _, ref := range refs {
+			if strings.HasPrefix(ref.Sha, version) {
+				return ref.Sha
+			}
+		}
+	}
+	return ""
+}
+
+// gitEnv returns the environment variables for git commands, the auth header is passed by
+// the `GIT_CONFIG_*` variables instead of the command line to avoid leaking the token.
+func gitEnv(authHeader string) []string {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if authHeader != "" {
+		env = append(env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=Authorization: "+authHeader)
+	}
+	return env
+}
@@ -2,15 +2,17 @@ package server
 
 import (
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/ije/gox/crypto/rand"
 )
 
 func TestListRepoRefs(t *testing.T) {
-	refs, err := listGhRepoRefs("https://github.com/esm-dev/esm.sh")
+	refs, err := listGhRepoRefs("https://github.com/esm-dev/esm.sh", "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -29,7 +31,7 @@ func TestListRepoRefs(t *testing.T) {
 func TestGhInstall(t *testing.T) {
 	dir := filepath.Join(os.TempDir(), rand.Hex.String(8))
 	defer os.RemoveAll(dir)
-	err := ghInstall(dir, "esm-dev/esm.sh", "main")
+	err := ghInstall(dir, "esm-dev/esm.sh", "main", "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -37,3 +39,130 @@ func TestGhInstall(t *testing.T) {
 		t.Fatal("README.md not found")
 	}
 }
+
+func TestGitInstall(t *testing.T) {
+	dir := filepath.Join(os.TempDir(), rand.Hex.String(8))
+	defer os.RemoveAll(dir)
+
+	// create a bare repository that can be accessed via `file://`
+	src := filepath.Join(dir, "src")
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = src
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=esm.sh", "GIT_AUTHOR_EMAIL=test@esm.sh", "GIT_COMMITTER_NAME=esm.sh", "GIT_COMMITTER_EMAIL=test@esm.sh")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	os.MkdirAll(src, 0755)
+	git("init", "-q")
+	os.WriteFile(filepath.Join(src, "package.json"), []byte(`{"name":"repo","version":"1.0.0","module":"index.js"}`), 0644)
+	os.WriteFile(filepath.Join(src, "index.js"), []byte(`export default "v1";`), 0644)
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "-a", "v1.0.0", "-m", "v1.0.0")
+	v1 := git("rev-parse", "HEAD")
+	os.WriteFile(filepath.Join(src, "index.js"), []byte(`export default "v2";`), 0644)
+	git("commit", "-q", "-am", "v2")
+	head := git("rev-parse", "HEAD")
+	git("clone", "-q", "--bare", src, filepath.Join(dir, "owner", "repo.git"))
+
+	config.GitHosts = map[string]GitHost{"git.local": {Type: "git", Url: "file://" + dir}}
+	defer func() { config.GitHosts = nil }()
+
+	gitHost, ok := getGitHost("git.local")
+	if !ok {
+		t.Fatal("git host not found")
+	}
+	refs, err := gitHost.listRepoRefs("owner/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sha := resolveGitRef(refs, "1.0.0"); sha != v1 {
+		t.Fatalf("invalid sha of tag v1.0.0: %s", sha)
+	}
+	if sha := resolveGitRef(refs, head[:7]); sha != head {
+		t.Fatalf("invalid sha of HEAD: %s", sha)
+	}
+
+	for version, content := range map[string]string{"v1.0.0": `export default "v1";`, head[:7]: `export default "v2";`, v1[:7]: `export default "v1";`} {
+		wd := filepath.Join(dir, "install", version)
+		err = gitInstall(wd, gitHost, "owner/repo", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path.Join(wd, "node_modules/owner/repo/index.js"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("invalid content of %s: %s", version, data)
+		}
+	}
+
+	if _, ok := getGitHost("unknown.host"); ok {
+		t.Fatal("unknown git host should not be allowed")
+	}
+}
+
+func TestNpmRCGitTokens(t *testing.T) {
+	npmrc, err := NewNpmRcFromIni([]byte("//github.com/:_authToken=gh-token\n//gitlab.com/:_authToken=gl-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token := npmrc.getGitToken("github.com"); token != "gh-token" {
+		t.Fatalf("invalid github token: %s", token)
+	}
+	if gitHost, ok := npmrc.getGitHost("gitlab.com"); !ok || gitHost.Token != "gl-token" || gitHost.Type != "gitlab" {
+		t.Fatalf("invalid gitlab host: %+v", gitHost)
+	}
+
+	// the server token is only used for the requests with a zone
+	defer func(token string) { config.GithubToken = token }(config.GithubToken)
+	config.GithubToken = "server-token"
+	npmrc = &NpmRC{}
+	if token := npmrc.getGitToken("github.com"); token != "" {
+		t.Fatalf("the server token should not be used without a zone: %s", token)
+	}
+	npmrc.zoneId = "example.com"
+	if token := npmrc.getGitToken("github.com"); token != "server-token" {
+		t.Fatalf("invalid github token: %s", token)
+	}
+	if gitHostOfPath("/*gh/owner/repo@main/index.js") != "github.com" || gitHostOfPath("/git/gitlab.com/owner/repo") != "gitlab.com" || gitHostOfPath("/react") != "" {
+		t.Fatal("invalid git host of path")
+	}
+}
+
+func TestNpmRCWithZone(t *testing.T) {
+	publicRC := &NpmRC{NpmRegistry: NpmRegistry{Registry: npmRegistry}}
+	if rc := publicRC.withZone("example.com", ""); rc.zoneId != "" {
+		t.Fatal("the zone should not be applied without credentials")
+	}
+	if rc := publicRC.withZone("example.com", "/react@19.0.0"); rc.zoneId != "" {
+		t.Fatal("the zone should not be applied to public packages")
+	}
+
+	privateRC := &NpmRC{
+		NpmRegistry:      NpmRegistry{Registry: npmRegistry},
+		ScopedRegistries: map[string]NpmRegistry{"@private": {Registry: "https://npm.example.com/", Token: "token"}},
+	}
+	if rc := privateRC.withZone("example.com", ""); rc.zoneId != "example.com" || privateRC.zoneId != "" {
+		t.Fatal("the zone should be applied to a copy of the npmrc with credentials")
+	}
+	if rc := privateRC.withZone("example.com", "/@private/pkg@1.0.0"); rc.zoneId != "example.com" {
+		t.Fatal("the zone should be applied to private packages")
+	}
+	if rc := privateRC.withZone("example.com", "/react@19.0.0"); rc.zoneId != "" {
+		t.Fatal("the zone should not be applied to public packages")
+	}
+	if rc := privateRC.withZone("not a domain", ""); rc.zoneId != "" {
+		t.Fatal("invalid zone id should be ignored")
+	}
+
+	gitRC := &NpmRC{GitTokens: map[string]string{"github.com": "gh-token"}}
+	if rc := gitRC.withZone("", "/gh/owner/repo"); len(rc.GitTokens) != 0 || len(gitRC.GitTokens) == 0 {
+		t.Fatal("the git tokens should be ignored without a zone")
+	}
+}
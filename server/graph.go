@@ -0,0 +1,198 @@
+package server
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ije/rex"
+)
+
+// the maximum number of modules in the dependency graph
+const maxGraphModules = 200
+
+// ModuleGraph is the dependency graph of a module, see the `/graph/` route.
+type ModuleGraph struct {
+	Root    string        `json:"root"`
+	Modules []GraphModule `json:"modules"`
+}
+
+// GraphModule is a module in the dependency graph, the modules are listed in breadth-first order.
+type GraphModule struct {
+	URL     string   `json:"url"`
+	Package string   `json:"package,omitempty"`
+	Version string   `json:"version,omitempty"`
+	Size    int64    `json:"size"`
+	Types   string   `json:"types,omitempty"`
+	Imports []string `json:"imports"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// walkModuleGraph walks the imports of the build recursively, the missing builds are triggered and charged to the
+// build budget of the client, the walk stops once the budget is exhausted.
+func walkModuleGraph(ctx *rex.Context, buildQueue *BuildQueue, root *BuildContext, meta *BuildMeta, origin string) *ModuleGraph {
+	graph := &ModuleGraph{Root: origin + root.Path()}
+	budget := &buildBudget{ctx: ctx}
+	seen := map[string]bool{root.Path(): true}
+	level := []string{root.Path()}
+	metas := []*BuildMeta{meta}
+	graph.Modules = append(graph.Modules, root.newGraphModule(origin+root.Path(), meta, origin))
+
+	for len(level) > 0 && !budget.exhausted {
+		var next []string
+		for _, meta := range metas {
+			if meta == nil {
+				continue
+			}
+			for _, importPath := range meta.Imports {
+				if !seen[importPath] && len(seen) < maxGraphModules {
+					seen[importPath] = true
+					next = append(next, importPath)
+				}
+			}
+		}
+
+		// resolve the modules of the next level in parallel, the number of workers is limited by the build concurrency
+		modules := make([]GraphModule, len(next))
+		metas = make([]*BuildMeta, len(next))
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, max(int(config.BuildConcurrency), 1))
+		for i, importPath := range next {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				url := origin + importPath
+				// node builtin polyfills, e.g. "/node/process.mjs"
+				if strings.HasPrefix(importPath, "/node/") {
+					modules[i] = GraphModule{URL: url, Imports: []string{}}
+					return
+				}
+				b, meta, err := resolveGraphModule(ctx, buildQueue, budget, root, importPath)
+				if err != nil {
+					modules[i] = GraphModule{URL: url, Imports: []string{}, Error: err.Error()}
+					return
+				}
+				modules[i] = b.newGraphModule(url, meta, origin)
+				metas[i] = meta
+			}()
+		}
+		wg.Wait()
+
+		graph.Modules = append(graph.Modules, modules...)
+		level = next
+	}
+	return graph
+}
+
+// resolveGraphModule returns the build meta of the import path, the module is built if it doesn't exist.
+func resolveGraphModule(ctx *rex.Context, buildQueue *BuildQueue, budget *buildBudget, root *BuildContext, importPath string) (b *BuildContext, meta *BuildMeta, err error) {
+	b, err = root.newImportBuildContext(importPath)
+	if err != nil {
+		return
+	}
+	// the imported packages are checked like the requested package, see `checkPackageAccess`
+	err = checkPackageAccess(ctx.R, b.esmPath.PkgName)
+	if err != nil {
+		return
+	}
+	meta, ok, err := b.Exists()
+	if err != nil || ok {
+		return
+	}
+	if !budget.charge() {
+		err = errBuildRateLimited
+		return
+	}
+	select {
+	case output := <-buildQueue.Add(b):
+		meta, err = output.meta, output.err
+	case <-time.After(time.Duration(config.BuildWaitTime) * time.Second):
+		err = errors.New("timeout, the module is waiting to be built")
+	}
+	return
+}
+
+// newImportBuildContext creates a build context by the import path of a build, e.g.
+// "/react-dom@19.0.0/X-ZHJlYWN0QDE5LjAuMA/es2022/client.development.mjs"
+func (ctx *BuildContext) newImportBuildContext(importPath string) (*BuildContext, error) {
+	pathname, externalAll := splitAsteriskPrefix(importPath)
+	esm, _, _, hasTargetSegment, err := praseEsmPath(ctx.npmrc, pathname)
+	if err != nil {
+		return nil, err
+	}
+	if !hasTargetSegment || !strings.HasSuffix(esm.SubPath, ".mjs") {
+		return nil, errors.New("invalid build path")
+	}
+
+	var args BuildArgs
+	segments := strings.Split(esm.SubModuleName, "/")
+	if strings.HasPrefix(segments[0], "X-") {
+		args, err = decodeBuildArgs(strings.TrimPrefix(segments[0], "X-"))
+		if err != nil {
+			return nil, errors.New("invalid build args")
+		}
+		segments = segments[1:]
+		esm.SubPath = strings.Join(strings.Split(esm.SubPath, "/")[1:], "/")
+	}
+	target := segments[0]
+	submodule := strings.Join(segments[1:], "/")
+	bundleMode := BundleDefault
+	if s, ok := strings.CutSuffix(submodule, ".bundle"); ok {
+		submodule = s
+		bundleMode = BundleDeps
+	} else if s, ok := strings.CutSuffix(submodule, ".nobundle"); ok {
+		submodule = s
+		bundleMode = BundleFalse
+	}
+	dev := false
+	if s, ok := strings.CutSuffix(submodule, ".development"); ok {
+		submodule = s
+		dev = true
+	}
+	basename := strings.TrimSuffix(path.Base(esm.PkgName), ".js")
+	if submodule == basename {
+		submodule = ""
+	} else if submodule == "__"+basename {
+		// the sub-module name is same as the package name
+		submodule = basename
+	}
+	esm.SubModuleName = submodule
+
+	return &BuildContext{
+		npmrc:       ctx.npmrc,
+		logger:      ctx.logger,
+		db:          ctx.db,
+		storage:     ctx.storage,
+		esmPath:     esm,
+		args:        args,
+		bundleMode:  bundleMode,
+		externalAll: externalAll,
+		target:      target,
+		dev:         dev,
+	}, nil
+}
+
+func (ctx *BuildContext) newGraphModule(url string, meta *BuildMeta, origin string) GraphModule {
+	module := GraphModule{
+		URL:     url,
+		Package: ctx.esmPath.PkgName,
+		Version: ctx.esmPath.PkgVersion,
+		Imports: []string{},
+	}
+	if stat, err := ctx.storage.Stat(ctx.getSavepath()); err == nil {
+		module.Size = stat.Size()
+	}
+	if meta.Dts != "" {
+		module.Types = origin + meta.Dts
+	}
+	for _, p := range meta.Imports {
+		module.Imports = append(module.Imports, origin+p)
+	}
+	return module
+}
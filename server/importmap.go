@@ -0,0 +1,477 @@
+package server
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/esm-dev/esm.sh/internal/npm"
+	"github.com/esm-dev/esm.sh/internal/storage"
+	"github.com/ije/gox/log"
+	"github.com/ije/rex"
+)
+
+// the maximum number of packages resolved by the `POST /importmap` API
+const maxImportMapPackages = 500
+
+// ImportMapOptions is the request body of the `POST /importmap` API.
+type ImportMapOptions struct {
+	Packages  []string `json:"packages"`
+	Target    string   `json:"target"`
+	Dev       bool     `json:"dev"`
+	Integrity bool     `json:"integrity"`
+}
+
+// ImportMapOutput is the import map generated by the `POST /importmap` API.
+type ImportMapOutput struct {
+	Imports   map[string]string            `json:"imports"`
+	Scopes    map[string]map[string]string `json:"scopes,omitempty"`
+	Integrity map[string]string            `json:"integrity,omitempty"`
+}
+
+// importMapDep is a dependency of a package, the `url` is set for the non-npm dependencies.
+type importMapDep struct {
+	specifier string
+	pkgName   string
+	version   string
+	peer      bool
+	url       string
+}
+
+// importMapEntry is a module of the import map, either a resolved package or a url of the non-npm dependency.
+type importMapEntry struct {
+	pkg *npm.PackageJSON
+	url string
+}
+
+type importMapGenerator struct {
+	ctx        *rex.Context
+	budget     *buildBudget
+	npmrc      *NpmRC
+	zoneId     string
+	logger     *log.Logger
+	db         Database
+	storage    storage.Storage
+	buildQueue *BuildQueue
+	origin     string
+	options    ImportMapOptions
+	lock       sync.Mutex
+	resolved   map[string]*npm.PackageJSON
+}
+
+// resolve returns the package info of the version range, the results are cached by the generator.
+// The packages are checked by the allow/ban lists and the scopes of the API key, see `checkPackageAccess`.
+func (g *importMapGenerator) resolve(pkgName string, version string) (*npm.PackageJSON, error) {
+	err := checkPackageAccess(g.ctx.R, pkgName)
+	if err != nil {
+		return nil, err
+	}
+	key := pkgName + "@" + version
+	g.lock.Lock()
+	p, ok := g.resolved[key]
+	g.lock.Unlock()
+	if ok {
+		return p, nil
+	}
+	p, err = g.npmrc.getPackageInfo(pkgName, version)
+	if err != nil {
+		return nil, err
+	}
+	g.lock.Lock()
+	g.resolved[key] = p
+	g.lock.Unlock()
+	return p, nil
+}
+
+// deps returns the dependencies and peer dependencies of the package, sorted by the specifier.
+func (g *importMapGenerator) deps(p *npm.PackageJSON) []importMapDep {
+	deps := make([]importMapDep, 0, len(p.Dependencies)+len(p.PeerDependencies))
+	add := func(specifier string, version string, peer bool) {
+		dep := importMapDep{specifier: specifier, pkgName: specifier, version: version, peer: peer}
+		pkg, err := npm.ResolveDependencyVersion(version)
+		if err != nil {
+			return
+		}
+		if strings.HasPrefix(dep.pkgName, "@types/") || strings.HasPrefix(pkg.Name, "@types/") {
+			return
+		}
+		if pkg.Name != "" {
+			if pkg.Github || pkg.GitHost != "" || pkg.PkgPrNew || pkg.Tgz {
+				dep.url = g.origin + "/" + pkg.String()
+			}
+			dep.pkgName = pkg.Name
+			dep.version = pkg.Version
+		}
+		deps = append(deps, dep)
+	}
+	for specifier, version := range p.Dependencies {
+		add(specifier, version, false)
+	}
+	for specifier, version := range p.PeerDependencies {
+		if _, ok := p.Dependencies[specifier]; !ok {
+			add(specifier, version, true)
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].specifier < deps[j].specifier })
+	return deps
+}
+
+// generate resolves the packages and their dependencies, the shared dependencies use the version that is compatible
+// with most of the dependents, the others are added to the `scopes` of the dependents.
+func (g *importMapGenerator) generate() (*ImportMapOutput, error) {
+	g.resolved = map[string]*npm.PackageJSON{}
+
+	// resolve the top-level packages
+	var packages []*npm.PackageJSON
+	shared := map[string]*npm.PackageJSON{}
+	for _, spec := range g.options.Packages {
+		pkgName, version, _, _ := splitEsmPath("/" + strings.TrimSpace(spec))
+		if !npm.ValidatePackageName(pkgName) {
+			return nil, fmt.Errorf("invalid package name '%s'", pkgName)
+		}
+		if _, ok := shared[pkgName]; ok {
+			return nil, fmt.Errorf("duplicate package '%s'", pkgName)
+		}
+		p, err := g.resolve(pkgName, version)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, p)
+		shared[pkgName] = p
+	}
+
+	// collect the version ranges of the dependencies
+	ranges := map[string][]importMapDep{}
+	visited := map[string]bool{}
+	queue := append([]*npm.PackageJSON{}, packages...)
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if visited[p.Name+"@"+p.Version] {
+			continue
+		}
+		visited[p.Name+"@"+p.Version] = true
+		if len(visited) > maxImportMapPackages {
+			return nil, errors.New("too many packages")
+		}
+		for _, dep := range g.deps(p) {
+			if dep.url != "" {
+				continue
+			}
+			c, err := g.resolve(dep.pkgName, dep.version)
+			if err != nil {
+				// the peer dependencies may be optional
+				if dep.peer {
+					continue
+				}
+				return nil, err
+			}
+			ranges[dep.specifier] = append(ranges[dep.specifier], dep)
+			queue = append(queue, c)
+		}
+	}
+
+	// select the shared version of the dependencies
+	for specifier, deps := range ranges {
+		if _, ok := shared[specifier]; ok {
+			continue
+		}
+		var best *npm.PackageJSON
+		bestCount := 0
+		for _, dep := range deps {
+			c, _ := g.resolve(dep.pkgName, dep.version)
+			count := 0
+			for _, d := range deps {
+				if d.pkgName == c.Name && (d.peer || satisfiesVersion(d.version, c.Version)) {
+					count++
+				}
+			}
+			if best == nil || count > bestCount || (count == bestCount && compareVersion(c.Version, best.Version) > 0) {
+				best = c
+				bestCount = count
+			}
+		}
+		shared[specifier] = best
+	}
+
+	// walk the dependency graph with the selected versions
+	imports := map[string]importMapEntry{}
+	sharedScope := map[string]importMapEntry{}
+	scopes := map[*npm.PackageJSON]map[string]importMapEntry{}
+	for _, p := range packages {
+		imports[p.Name] = importMapEntry{pkg: p}
+	}
+	visited = map[string]bool{}
+	queue = append([]*npm.PackageJSON{}, packages...)
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if visited[p.Name+"@"+p.Version] {
+			continue
+		}
+		visited[p.Name+"@"+p.Version] = true
+		for _, dep := range g.deps(p) {
+			if dep.url != "" {
+				if _, ok := sharedScope[dep.specifier]; !ok && imports[dep.specifier].pkg == nil {
+					sharedScope[dep.specifier] = importMapEntry{url: dep.url}
+				} else if sharedScope[dep.specifier].url != dep.url {
+					addScopeEntry(scopes, p, dep.specifier, importMapEntry{url: dep.url})
+				}
+				continue
+			}
+			s := shared[dep.specifier]
+			if s == nil {
+				// unresolved optional peer dependency
+				continue
+			}
+			if s.Name == dep.pkgName && (dep.peer || satisfiesVersion(dep.version, s.Version)) {
+				if _, ok := imports[dep.specifier]; !ok {
+					sharedScope[dep.specifier] = importMapEntry{pkg: s}
+				}
+				queue = append(queue, s)
+				continue
+			}
+			c, err := g.resolve(dep.pkgName, dep.version)
+			if err != nil {
+				return nil, err
+			}
+			addScopeEntry(scopes, p, dep.specifier, importMapEntry{pkg: c})
+			queue = append(queue, c)
+		}
+	}
+
+	// resolve the module urls, the modules are built if the integrity is required
+	urls := map[string]string{}
+	integrity := map[string]string{}
+	var pkgs []*npm.PackageJSON
+	for _, m := range []map[string]importMapEntry{imports, sharedScope} {
+		for _, e := range m {
+			if e.pkg != nil {
+				pkgs = append(pkgs, e.pkg)
+			}
+		}
+	}
+	for _, m := range scopes {
+		for _, e := range m {
+			if e.pkg != nil {
+				pkgs = append(pkgs, e.pkg)
+			}
+		}
+	}
+	// collect the unique packages first, then resolve the urls in parallel with the workers limited by the build concurrency
+	var targets []*npm.PackageJSON
+	for _, p := range pkgs {
+		key := p.Name + "@" + p.Version
+		if _, ok := urls[key]; !ok {
+			urls[key] = ""
+			targets = append(targets, p)
+		}
+	}
+	type moduleUrl struct {
+		url  string
+		hash string
+		err  error
+	}
+	results := make([]moduleUrl, len(targets))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(int(config.BuildConcurrency), 1))
+	for i, p := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			url, hash, err := g.getModuleUrl(p)
+			results[i] = moduleUrl{url, hash, err}
+		}()
+	}
+	wg.Wait()
+	for i, p := range targets {
+		ret := results[i]
+		if ret.err != nil {
+			return nil, fmt.Errorf("%s@%s: %w", p.Name, p.Version, ret.err)
+		}
+		urls[p.Name+"@"+p.Version] = ret.url
+		if ret.hash != "" {
+			integrity[ret.url] = ret.hash
+		}
+	}
+
+	output := &ImportMapOutput{Imports: map[string]string{}}
+	setEntries := func(m map[string]string, specifier string, e importMapEntry) {
+		if e.pkg == nil {
+			m[specifier] = e.url
+			m[specifier+"/"] = e.url + "/"
+			return
+		}
+		m[specifier] = urls[e.pkg.Name+"@"+e.pkg.Version]
+		// use `&` as the query prefix of the trailing slash url, e.g. "https://esm.sh/react-dom@19.0.0&dev/"
+		if query := g.getQuery(); query != "" {
+			m[specifier+"/"] = g.getPackageUrl(e.pkg) + "&" + query + "/"
+		} else {
+			m[specifier+"/"] = g.getPackageUrl(e.pkg) + "/"
+		}
+	}
+	for specifier, e := range imports {
+		setEntries(output.Imports, specifier, e)
+	}
+	if len(sharedScope) > 0 || len(scopes) > 0 {
+		output.Scopes = map[string]map[string]string{}
+	}
+	if len(sharedScope) > 0 {
+		m := map[string]string{}
+		for specifier, e := range sharedScope {
+			setEntries(m, specifier, e)
+		}
+		output.Scopes[g.origin+"/"] = m
+	}
+	for p, entries := range scopes {
+		m := map[string]string{}
+		for specifier, e := range entries {
+			setEntries(m, specifier, e)
+		}
+		output.Scopes[g.getPackageUrl(p)+"/"] = m
+	}
+	if len(integrity) > 0 {
+		output.Integrity = integrity
+	}
+	return output, nil
+}
+
+// getPackageUrl returns the url of the package, the `*` prefix marks the dependencies as external
+// that are resolved by the import map.
+func (g *importMapGenerator) getPackageUrl(p *npm.PackageJSON) string {
+	if len(p.Dependencies) > 0 || len(p.PeerDependencies) > 0 {
+		return g.origin + "/*" + p.Name + "@" + p.Version
+	}
+	return g.origin + "/" + p.Name + "@" + p.Version
+}
+
+// getQuery returns the build query of the module urls.
+func (g *importMapGenerator) getQuery() string {
+	var query []string
+	if g.options.Target != "" {
+		query = append(query, "target="+g.options.Target)
+	}
+	if g.options.Dev {
+		query = append(query, "dev")
+	}
+	return strings.Join(query, "&")
+}
+
+// getModuleUrl returns the url of the package module. If the integrity is required, the module is built
+// and the url of the build is returned with the SRI hash.
+func (g *importMapGenerator) getModuleUrl(p *npm.PackageJSON) (url string, hash string, err error) {
+	if !g.options.Integrity {
+		url = g.getPackageUrl(p)
+		if query := g.getQuery(); query != "" {
+			url += "?" + query
+		}
+		return
+	}
+
+	b := g.newBuildContext(p)
+	meta, ok, err := b.Exists()
+	if err != nil {
+		return
+	}
+	if !ok {
+		if !g.budget.charge() {
+			err = errBuildRateLimited
+			return
+		}
+		select {
+		case output := <-g.buildQueue.Add(b):
+			if output.err != nil {
+				err = output.err
+				return
+			}
+			meta = output.meta
+		case <-time.After(time.Duration(config.BuildWaitTime) * time.Second):
+			err = errors.New("timeout, the module is waiting to be built")
+			return
+		}
+	}
+	// the types-only and css packages have no module to verify
+	if meta.TypesOnly || meta.CSSEntry != "" {
+		url = g.getPackageUrl(p)
+		return
+	}
+	r, _, err := g.storage.Get(b.getSavepath())
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	h := sha512.New384()
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return
+	}
+	url = g.origin + b.Path()
+	hash = "sha384-" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return
+}
+
+// newBuildContext creates the build context of the package module, the builds of the private packages are scoped to
+// the zone of the request like the main route, see `NpmRC.withZone`.
+func (g *importMapGenerator) newBuildContext(p *npm.PackageJSON) *BuildContext {
+	return &BuildContext{
+		npmrc:       g.npmrc.withZone(g.zoneId, "/"+p.Name),
+		logger:      g.logger,
+		db:          g.db,
+		storage:     g.storage,
+		esmPath:     EsmPath{PkgName: p.Name, PkgVersion: p.Version},
+		externalAll: len(p.Dependencies) > 0 || len(p.PeerDependencies) > 0,
+		target:      g.options.Target,
+		dev:         g.options.Dev,
+	}
+}
+
+func addScopeEntry(scopes map[*npm.PackageJSON]map[string]importMapEntry, p *npm.PackageJSON, specifier string, e importMapEntry) {
+	m, ok := scopes[p]
+	if !ok {
+		m = map[string]importMapEntry{}
+		scopes[p] = m
+	}
+	m[specifier] = e
+}
+
+// satisfiesVersion checks if the version satisfies the version range, the dist-tags are satisfied by any version.
+func satisfiesVersion(versionRange string, version string) bool {
+	versionRange = npm.NormalizePackageVersion(versionRange)
+	if versionRange == version {
+		return true
+	}
+	c, err := semver.NewConstraint(versionRange)
+	if err != nil {
+		// e.g. "latest", "next"
+		return true
+	}
+	v, err := semver.NewVersion(version)
+	return err == nil && c.Check(v)
+}
+
+// compareVersion compares two semver versions, the invalid version is less than any valid version.
+func compareVersion(a string, b string) int {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+	if errA != nil || errB != nil {
+		if errA == nil {
+			return 1
+		}
+		if errB == nil {
+			return -1
+		}
+		return 0
+	}
+	return va.Compare(vb)
+}
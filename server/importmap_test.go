@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/esm-dev/esm.sh/internal/npm"
+)
+
+func TestImportMapBuildZone(t *testing.T) {
+	g := &importMapGenerator{
+		npmrc: &NpmRC{
+			NpmRegistry:      NpmRegistry{Registry: npmRegistry},
+			ScopedRegistries: map[string]NpmRegistry{"@private": {Registry: "https://npm.example.com/", Token: "token"}},
+		},
+		zoneId:  "example.com",
+		options: ImportMapOptions{Target: "es2022", Integrity: true},
+	}
+
+	b := g.newBuildContext(&npm.PackageJSON{Name: "@private/pkg", Version: "1.0.0"})
+	if b.npmrc.zoneId != "example.com" {
+		t.Fatalf("the build of the private package should be scoped to the zone, got %q", b.npmrc.zoneId)
+	}
+	if g.npmrc.zoneId != "" {
+		t.Fatal("the npmrc of the generator should not be changed")
+	}
+
+	b = g.newBuildContext(&npm.PackageJSON{Name: "react", Version: "19.0.0"})
+	if b.npmrc.zoneId != "" {
+		t.Fatalf("the build of the public package should be shared, got zone %q", b.npmrc.zoneId)
+	}
+
+	g.zoneId = ""
+	b = g.newBuildContext(&npm.PackageJSON{Name: "@private/pkg", Version: "1.0.0"})
+	if b.npmrc.zoneId != "" {
+		t.Fatal("the zone should not be applied without the X-Zone-Id header")
+	}
+}
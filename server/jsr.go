@@ -0,0 +1,361 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/esm-dev/esm.sh/internal/fetch"
+	"github.com/esm-dev/esm.sh/internal/jsonc"
+	"github.com/esm-dev/esm.sh/internal/npm"
+	"github.com/goccy/go-json"
+	"github.com/ije/gox/utils"
+)
+
+var regexpJsrSourceImport = regexp.MustCompile(`((?:import|export)\s[^"';]*?from\s*|import\s*\(\s*|import\s+)(["'])([^"']+)["']`)
+
+// JsrPackageMeta defines the `meta.json` of a JSR package.
+// see https://jsr.io/docs/api#package-metadata
+type JsrPackageMeta struct {
+	Scope    string                    `json:"scope"`
+	Name     string                    `json:"name"`
+	Latest   string                    `json:"latest"`
+	Versions map[string]JsrVersionInfo `json:"versions"`
+}
+
+// JsrVersionInfo defines the version info in the `meta.json` of a JSR package.
+type JsrVersionInfo struct {
+	Yanked bool `json:"yanked"`
+}
+
+// JsrVersionMeta defines the `<version>_meta.json` of a JSR package.
+// see https://jsr.io/docs/api#package-version-metadata
+type JsrVersionMeta struct {
+	Manifest map[string]JsrManifestFile `json:"manifest"`
+	Exports  json.RawMessage            `json:"exports"`
+}
+
+// JsrManifestFile defines a file in the manifest of a JSR package version.
+type JsrManifestFile struct {
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// isNativeJsr returns true if the package should be resolved by the JSR registry API directly
+// instead of the npm compatibility layer (npm.jsr.io).
+func (npmrc *NpmRC) isNativeJsr(pkgName string) bool {
+	if config.JsrNpmCompat || !strings.HasPrefix(pkgName, "@jsr/") {
+		return false
+	}
+	// use the npm compatibility layer if the `@jsr` scope is mapped to another registry
+	reg, ok := npmrc.ScopedRegistries["@jsr"]
+	return !ok || reg.Registry == jsrRegistry
+}
+
+// toJsrName converts the npm-style jsr package name to the jsr name, e.g. "@jsr/std__path" -> "@std/path".
+func toJsrName(pkgName string) string {
+	return "@" + strings.Replace(strings.TrimPrefix(pkgName, "@jsr/"), "__", "/", 1)
+}
+
+func fetchJsrJSON(pathname string, v any) (err error) {
+	u, err := url.Parse(config.JsrRegistry + pathname)
+	if err != nil {
+		return
+	}
+
+	fetchClient, recycle := fetch.NewClient("esmd/"+VERSION, 15, false)
+	defer recycle()
+
+	retryTimes := 0
+RETRY:
+	res, err := fetchClient.Fetch(u, nil)
+	if err != nil {
+		if retryTimes < 3 {
+			retryTimes++
+			time.Sleep(time.Duration(retryTimes) * 100 * time.Millisecond)
+			goto RETRY
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return errors.New("not found")
+	}
+
+	if res.StatusCode != 200 {
+		msg, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("could not get %s (%s: %s)", pathname, res.Status, string(msg))
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func getJsrVersionMeta(jsrName string, version string) (*JsrVersionMeta, error) {
+	return withCache(config.JsrRegistry+jsrName+"/"+version+"_meta.json", time.Duration(config.NpmQueryCacheTTL)*time.Second, func() (*JsrVersionMeta, string, error) {
+		var meta JsrVersionMeta
+		err := fetchJsrJSON(jsrName+"/"+version+"_meta.json", &meta)
+		if err != nil {
+			if err.Error() == "not found" {
+				err = fmt.Errorf("version %s of '%s' not found", version, jsrName)
+			}
+			return nil, "", err
+		}
+		return &meta, "", nil
+	})
+}
+
+// getJsrPackageInfo resolves the version of a JSR package using the `meta.json` API.
+func (npmrc *NpmRC) getJsrPackageInfo(pkgName string, version string) (packageJson *npm.PackageJSON, err error) {
+	jsrName := toJsrName(pkgName)
+	getCacheKey := func(pkgVersion string) string {
+		return config.JsrRegistry + jsrName + "@" + pkgVersion
+	}
+
+	version = npm.NormalizePackageVersion(version)
+	return withCache(getCacheKey(version), time.Duration(config.NpmQueryCacheTTL)*time.Second, func() (*npm.PackageJSON, string, error) {
+		// check if the package has been installed
+		if npm.IsExactVersion(version) {
+			var raw npm.PackageJSONRaw
+			pkgJsonPath := path.Join(npmrc.StoreDir(), pkgName+"@"+version, "node_modules", pkgName, "package.json")
+			if utils.ParseJSONFile(pkgJsonPath, &raw) == nil {
+				return raw.ToNpmPackage(), "", nil
+			}
+		}
+
+		var meta JsrPackageMeta
+		err := fetchJsrJSON(jsrName+"/meta.json", &meta)
+		if err != nil {
+			if err.Error() == "not found" {
+				err = fmt.Errorf("package '%s' not found", jsrName)
+			}
+			return nil, "", err
+		}
+
+		resolvedVersion := ""
+		if version == "latest" {
+			resolvedVersion = meta.Latest
+		} else if _, ok := meta.Versions[version]; ok {
+			resolvedVersion = version
+		} else if c, e := semver.NewConstraint(version); e == nil {
+			vs := make([]*semver.Version, 0, len(meta.Versions))
+			for v, info := range meta.Versions {
+				// ignore yanked and prerelease versions
+				if info.Yanked || (!strings.ContainsRune(version, '-') && strings.ContainsRune(v, '-')) {
+					continue
+				}
+				ver, err := semver.NewVersion(v)
+				if err == nil && c.Check(ver) {
+					vs = append(vs, ver)
+				}
+			}
+			if len(vs) > 0 {
+				sort.Sort(semver.Collection(vs))
+				resolvedVersion = vs[len(vs)-1].Original()
+			}
+		}
+		if resolvedVersion == "" {
+			return nil, "", fmt.Errorf("version %s of '%s' not found", version, jsrName)
+		}
+
+		versionMeta, err := getJsrVersionMeta(jsrName, resolvedVersion)
+		if err != nil {
+			return nil, "", err
+		}
+
+		raw := npm.PackageJSONRaw{
+			Name:    pkgName,
+			Version: resolvedVersion,
+			Type:    "module",
+			Exports: versionMeta.Exports,
+		}
+		return raw.ToNpmPackage(), getCacheKey(resolvedVersion), nil
+	})
+}
+
+// installJsrPackage downloads the source files of a JSR package listed in the version manifest,
+// and creates a `package.json` with the `exports` of the version meta and the `imports` of the `deno.json`.
+func (npmrc *NpmRC) installJsrPackage(installDir string, pkg npm.Package) (err error) {
+	jsrName := toJsrName(pkg.Name)
+	meta, err := getJsrVersionMeta(jsrName, pkg.Version)
+	if err != nil {
+		return
+	}
+
+	pkgDir := path.Join(installDir, "node_modules", pkg.Name)
+	files := make([]string, 0, len(meta.Manifest))
+	for filename, file := range meta.Manifest {
+		extname := path.Ext(filename)
+		if file.Size > maxAssetFileSize || !(extname != "" && (assetExts[extname[1:]] || slices.Contains(moduleExts, extname) || extname == ".map" || extname == ".css")) {
+			// ignore large files and unsupported formats
+			continue
+		}
+		files = append(files, filename)
+	}
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	sem := make(chan struct{}, 8)
+	for _, filename := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(filename string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			e := downloadJsrFile(pkgDir, jsrName, pkg.Version, filename, meta.Manifest[filename].Checksum)
+			if e != nil {
+				errOnce.Do(func() { err = e })
+			}
+		}(filename)
+	}
+	wg.Wait()
+	if err != nil {
+		os.RemoveAll(installDir)
+		return
+	}
+
+	var imports map[string]any
+	for _, name := range []string{"deno.json", "deno.jsonc", "jsr.json", "jsr.jsonc"} {
+		data, e := os.ReadFile(path.Join(pkgDir, name))
+		if e == nil {
+			var denoJson struct {
+				Imports map[string]any `json:"imports"`
+			}
+			if json.Unmarshal(jsonc.StripJSONC(data), &denoJson) == nil && len(denoJson.Imports) > 0 {
+				imports = denoJson.Imports
+			}
+			break
+		}
+	}
+
+	packageJson, err := json.Marshal(map[string]any{
+		"name":    pkg.Name,
+		"version": pkg.Version,
+		"type":    "module",
+		"exports": meta.Exports,
+		"imports": imports,
+	})
+	if err != nil {
+		return
+	}
+	ensureDir(pkgDir)
+	return os.WriteFile(path.Join(pkgDir, "package.json"), packageJson, 0644)
+}
+
+func downloadJsrFile(pkgDir string, jsrName string, version string, filename string, checksum string) (err error) {
+	savePath := path.Join(pkgDir, filename)
+	if !strings.HasPrefix(savePath, pkgDir+"/") {
+		return fmt.Errorf("invalid file path '%s'", filename)
+	}
+
+	u, err := url.Parse(config.JsrRegistry + jsrName + "/" + version + filename)
+	if err != nil {
+		return
+	}
+
+	fetchClient, recycle := fetch.NewClient("esmd/"+VERSION, 30, false)
+	defer recycle()
+
+	res, err := fetchClient.Fetch(u, nil)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return fmt.Errorf("could not download %s of '%s@%s' (%s)", filename, jsrName, version, res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxAssetFileSize))
+	if err != nil {
+		return
+	}
+
+	// verify the file checksum, e.g. "sha256-..."
+	if algorithm, hash := utils.SplitByFirstByte(checksum, '-'); algorithm == "sha256" {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != hash {
+			return fmt.Errorf("checksum mismatch of %s in '%s@%s'", filename, jsrName, version)
+		}
+	}
+
+	ensureDir(path.Dir(savePath))
+	return os.WriteFile(savePath, data, 0644)
+}
+
+// rewriteJsrSourceImports rewrites the import specifiers of the JSR package sources to esm.sh urls, the bare specifiers
+// are resolved by the import map of the `deno.json` which is stored as the `imports` of the package.json at install.
+// e.g. `import { join } from "@std/path"` -> `import { join } from "https://esm.sh/jsr/@std/path@^1.0.0"`
+func rewriteJsrSourceImports(code []byte, origin string, pkgPath string, imports map[string]any) []byte {
+	return regexpJsrSourceImport.ReplaceAllFunc(code, func(m []byte) []byte {
+		sub := regexpJsrSourceImport.FindSubmatch(m)
+		prefix, quote, specifier := sub[1], sub[2], string(sub[3])
+		url, ok := resolveJsrSourceImport(specifier, origin, pkgPath, imports)
+		if !ok {
+			return m
+		}
+		buf := bytes.NewBuffer(nil)
+		buf.Write(prefix)
+		buf.Write(quote)
+		buf.WriteString(url)
+		buf.Write(quote)
+		return buf.Bytes()
+	})
+}
+
+// resolveJsrSourceImport resolves the import specifier of the JSR package sources to an esm.sh url, it returns false
+// if the specifier should be kept as it is, e.g. relative paths, urls, and `node:` builtin modules.
+func resolveJsrSourceImport(specifier string, origin string, pkgPath string, imports map[string]any) (string, bool) {
+	if registry, name, ok := strings.Cut(specifier, ":"); ok && (registry == "jsr" || registry == "npm") {
+		if registry == "jsr" {
+			return origin + "/jsr/" + strings.TrimPrefix(name, "/"), true
+		}
+		return origin + "/" + strings.TrimPrefix(name, "/"), true
+	}
+	if isRelPathSpecifier(specifier) || isAbsPathSpecifier(specifier) || strings.Contains(specifier, ":") {
+		return "", false
+	}
+	// resolve the bare specifier by the import map, e.g. `{ "@std/path": "jsr:@std/path@^1.0.0", "utils/": "./src/utils/" }`
+	mapped := ""
+	if v, ok := imports[specifier].(string); ok {
+		mapped = v
+	} else {
+		prefix := ""
+		for key, v := range imports {
+			if s, ok := v.(string); ok && strings.HasSuffix(key, "/") && strings.HasPrefix(specifier, key) && len(key) > len(prefix) {
+				prefix = key
+				mapped = s + specifier[len(key):]
+			}
+		}
+	}
+	if mapped == "" || mapped == specifier {
+		return "", false
+	}
+	if isRelPathSpecifier(mapped) || strings.HasPrefix(mapped, "/") {
+		// the local paths are relative to the `deno.json` in the package root
+		pathname := path.Join(pkgPath, mapped)
+		if !strings.HasPrefix(pathname, pkgPath+"/") {
+			return "", false
+		}
+		return origin + pathname, true
+	}
+	if isHttpSepcifier(mapped) {
+		return mapped, true
+	}
+	return resolveJsrSourceImport(mapped, origin, pkgPath, nil)
+}
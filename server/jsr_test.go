@@ -0,0 +1,109 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/esm-dev/esm.sh/internal/npm"
+	"github.com/ije/gox/crypto/rand"
+	"github.com/ije/gox/utils"
+)
+
+func TestJsrRegistry(t *testing.T) {
+	files := map[string]string{
+		"/mod.ts":    `import { join } from "jsr:@std/path@^1.0.0";` + "\n" + `export const hello = (name: string): string => join("hello", name);`,
+		"/deno.json": `{"name":"@esm/example","version":"1.1.0","exports":"./mod.ts","imports":{"@std/path":"jsr:@std/path@^1.0.0"}}`,
+	}
+	manifest := map[string]any{}
+	for name, content := range files {
+		sum := sha256.Sum256([]byte(content))
+		manifest[name] = map[string]any{"size": len(content), "checksum": "sha256-" + hex.EncodeToString(sum[:])}
+	}
+
+	// a local stand-in server of the JSR registry
+	jsr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/@esm/example/meta.json":
+			w.Write([]byte(`{"scope":"esm","name":"example","latest":"1.1.0","versions":{"1.0.0":{},"1.1.0":{},"1.2.0":{"yanked":true},"2.0.0-beta.1":{}}}`))
+		case "/@esm/example/1.1.0_meta.json":
+			w.Write(utils.MustEncodeJSON(map[string]any{"manifest": manifest, "exports": map[string]string{".": "./mod.ts"}}))
+		default:
+			if content, ok := files[strings.TrimPrefix(r.URL.Path, "/@esm/example/1.1.0")]; ok {
+				w.Write([]byte(content))
+				return
+			}
+			http.NotFound(w, r)
+		}
+	}))
+	defer jsr.Close()
+
+	jsrRegistry := config.JsrRegistry
+	config.JsrRegistry = jsr.URL + "/"
+	defer func() { config.JsrRegistry = jsrRegistry }()
+
+	npmrc := &NpmRC{ScopedRegistries: map[string]NpmRegistry{}}
+	if !npmrc.isNativeJsr("@jsr/esm__example") || npmrc.isNativeJsr("react") {
+		t.Fatal("isNativeJsr: unexpected result")
+	}
+
+	// the yanked `1.2.0` and the prerelease `2.0.0-beta.1` should be ignored
+	for _, version := range []string{"", "^1.0.0", ">=1.1.0"} {
+		p, err := npmrc.getJsrPackageInfo("@jsr/esm__example", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Version != "1.1.0" {
+			t.Fatalf("invalid version of '%s': %s", version, p.Version)
+		}
+		if v, ok := p.Exports.Get("."); !ok || v != "./mod.ts" {
+			t.Fatalf("invalid exports: %v", v)
+		}
+	}
+	if _, err := npmrc.getJsrPackageInfo("@jsr/esm__example", "3"); err == nil || !strings.HasSuffix(err.Error(), " not found") {
+		t.Fatalf("should return a not found error: %v", err)
+	}
+
+	installDir := filepath.Join(os.TempDir(), rand.Hex.String(8))
+	defer os.RemoveAll(installDir)
+	err := npmrc.installJsrPackage(installDir, npm.Package{Name: "@jsr/esm__example", Version: "1.1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgDir := path.Join(installDir, "node_modules/@jsr/esm__example")
+	if data, err := os.ReadFile(path.Join(pkgDir, "mod.ts")); err != nil || string(data) != files["/mod.ts"] {
+		t.Fatalf("invalid mod.ts: %s", data)
+	}
+	var raw npm.PackageJSONRaw
+	if err := utils.ParseJSONFile(path.Join(pkgDir, "package.json"), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if p := raw.ToNpmPackage(); p.Version != "1.1.0" || p.Type != "module" || p.Imports["@std/path"] != "jsr:@std/path@^1.0.0" {
+		t.Fatalf("invalid package.json: %+v", p)
+	}
+
+	imports := map[string]any{"@std/path": "jsr:@std/path@^1.0.0", "@std/fmt/": "jsr:@std/fmt@1/", "utils/": "./src/utils/", "chalk": "npm:chalk@5"}
+	code := rewriteJsrSourceImports([]byte(files["/mod.ts"]+"\nimport React from 'npm:react@18';\nawait import(\"jsr:@std/fmt@1/colors\");\nimport { dirname } from \"@std/path\";\nimport { red } from \"@std/fmt/colors\";\nimport \"utils/log.ts\";\nexport * from \"chalk\";\nimport \"./local.ts\";\nimport \"node:fs\";\nimport \"unknown\";"), "https://esm.sh", "/jsr/@esm/example@1.1.0", imports)
+	for _, s := range []string{
+		`from "https://esm.sh/jsr/@std/path@^1.0.0"`,
+		`from 'https://esm.sh/react@18'`,
+		`import("https://esm.sh/jsr/@std/fmt@1/colors")`,
+		`import { dirname } from "https://esm.sh/jsr/@std/path@^1.0.0"`,
+		`import { red } from "https://esm.sh/jsr/@std/fmt@1/colors"`,
+		`import "https://esm.sh/jsr/@esm/example@1.1.0/src/utils/log.ts"`,
+		`export * from "https://esm.sh/chalk@5"`,
+		`import "./local.ts"`,
+		`import "node:fs"`,
+		`import "unknown"`,
+	} {
+		if !strings.Contains(string(code), s) {
+			t.Fatalf("missing %s in rewritten code:\n%s", s, code)
+		}
+	}
+}
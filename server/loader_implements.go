@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os/exec"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -15,6 +16,8 @@ import (
 	"github.com/ije/gox/term"
 )
 
+var regexpJsxPragma = regexp.MustCompile(`/\*\s*@jsx(Runtime|ImportSource)\s+[^*]*\*/\n?`)
+
 func transformSvelte(npmrc *NpmRC, svelteVersion string, filename string, code string) (output *LoaderOutput, err error) {
 	loaderExecPath := path.Join(npmrc.StoreDir(), "svelte@"+svelteVersion, "loader.js")
 
@@ -172,6 +175,151 @@ func resolveVueVersion(npmrc *NpmRC, importMap importmap.ImportMap) (vueVersion
 	return
 }
 
+func transformMDX(npmrc *NpmRC, mdxVersion string, filename string, code string) (output *LoaderOutput, err error) {
+	loaderExecPath := path.Join(npmrc.StoreDir(), "@mdx-js/mdx@"+mdxVersion, "loader.js")
+
+	err = doOnce(loaderExecPath, func() (err error) {
+		if !existsFile(loaderExecPath) {
+			if DEBUG {
+				fmt.Println(term.Dim("Compiling mdx loader..."))
+			}
+			err = compileMDXLoader(npmrc, mdxVersion, loaderExecPath)
+		}
+		return
+	})
+	if err != nil {
+		err = errors.New("failed to compile mdx loader: " + err.Error())
+		return
+	}
+
+	output, err = runLoader(loaderExecPath, filename, code)
+	if err != nil {
+		return
+	}
+	// remove the `@jsxRuntime` and `@jsxImportSource` pragmas, the jsx import source is resolved by the import map
+	output.Code = regexpJsxPragma.ReplaceAllString(output.Code, "")
+	return
+}
+
+func compileMDXLoader(npmrc *NpmRC, mdxVersion string, loaderExecPath string) (err error) {
+	wd := path.Join(npmrc.StoreDir(), "@mdx-js/mdx@"+mdxVersion)
+
+	// install mdx compiler
+	pkgJson, err := npmrc.installPackage(npm.Package{Name: "@mdx-js/mdx", Version: mdxVersion})
+	if err != nil {
+		return
+	}
+	npmrc.installDependencies(wd, pkgJson, false, nil)
+
+	loaderJS := `
+	  import { compile } from "@mdx-js/mdx";
+	  const { stdin, stdout } = Deno;
+	  const write = data => stdout.write(new TextEncoder().encode(data));
+	  try {
+	    let sourceCode = "";
+	    for await (const text of stdin.readable.pipeThrough(new TextDecoderStream())) {
+	      sourceCode += text;
+	    }
+	    const file = await compile({ path: Deno.args[0], value: sourceCode }, { jsx: true });
+	    await write("1\n" + String(file));
+	  } catch (err) {
+	    await write("0\n" + err.message);
+	  }
+	`
+	err = buildLoader(wd, loaderJS, loaderExecPath)
+	return
+}
+
+func resolveMDXVersion(npmrc *NpmRC, importMap importmap.ImportMap) (mdxVersion string, err error) {
+	mdxVersion = "3"
+	if len(importMap.Imports) > 0 {
+		mdxUrl, ok := importMap.Imports["@mdx-js/mdx"]
+		if ok && isHttpSepcifier(mdxUrl) {
+			u, e := url.Parse(mdxUrl)
+			if e == nil {
+				_, v, _, _ := splitEsmPath(u.Path)
+				if len(v) > 0 {
+					mdxVersion = v
+				}
+			}
+		}
+	}
+	if !npm.IsExactVersion(mdxVersion) {
+		var info *npm.PackageJSON
+		info, err = npmrc.getPackageInfo("@mdx-js/mdx", mdxVersion)
+		if err != nil {
+			return
+		}
+		mdxVersion = info.Version
+	}
+	if semverLessThan(mdxVersion, "2.0.0") {
+		err = errors.New("unsupported mdx version, only 2.0.0+ is supported")
+	}
+	return
+}
+
+func transformDeclaration(npmrc *NpmRC, filename string, code string) (output *LoaderOutput, err error) {
+	tsVersion := "5.7.3" // requires `transpileDeclaration` API of typescript 5.5+
+	loaderExecPath := path.Join(npmrc.StoreDir(), "typescript@"+tsVersion, "dts-loader.js")
+
+	err = doOnce(loaderExecPath, func() (err error) {
+		if !existsFile(loaderExecPath) {
+			if DEBUG {
+				fmt.Println(term.Dim("Compiling typescript declaration loader..."))
+			}
+			err = compileDeclarationLoader(npmrc, tsVersion, loaderExecPath)
+		}
+		return
+	})
+	if err != nil {
+		err = errors.New("failed to compile typescript declaration loader: " + err.Error())
+		return
+	}
+
+	output, err = runLoader(loaderExecPath, filename, code)
+	if err != nil {
+		return
+	}
+	output.Lang = "d.ts"
+	return
+}
+
+func compileDeclarationLoader(npmrc *NpmRC, tsVersion string, loaderExecPath string) (err error) {
+	wd := path.Join(npmrc.StoreDir(), "typescript@"+tsVersion)
+
+	// install typescript
+	pkgJson, err := npmrc.installPackage(npm.Package{Name: "typescript", Version: tsVersion})
+	if err != nil {
+		return
+	}
+	npmrc.installDependencies(wd, pkgJson, false, nil)
+
+	loaderJS := `
+	  import ts from "typescript";
+	  const { stdin, stdout } = Deno;
+	  const write = data => stdout.write(new TextEncoder().encode(data));
+	  try {
+	    let sourceCode = "";
+	    for await (const text of stdin.readable.pipeThrough(new TextDecoderStream())) {
+	      sourceCode += text;
+	    }
+	    const { outputText, diagnostics } = ts.transpileDeclaration(sourceCode, {
+	      fileName: Deno.args[0],
+	      reportDiagnostics: true,
+	      compilerOptions: { isolatedDeclarations: true, jsx: ts.JsxEmit.ReactJSX },
+	    });
+	    if (diagnostics && diagnostics.length > 0) {
+	      throw new Error(ts.flattenDiagnosticMessageText(diagnostics[0].messageText, "\n"));
+	    }
+	    await write("1\n" + outputText);
+	  } catch (err) {
+	    await write("0\n" + err.message);
+	  }
+	`
+	err = buildLoader(wd, loaderJS, loaderExecPath)
+	return
+}
+
 func generateUnoCSS(npmrc *NpmRC, configCSS string, content string) (out *LoaderOutput, err error) {
 	loaderVersion := "0.5.0"
 	loaderExecPath := path.Join(config.WorkDir, "bin", "unocss-"+loaderVersion)
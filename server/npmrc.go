@@ -26,6 +26,7 @@ import (
 	"github.com/ije/gox/set"
 	syncx "github.com/ije/gox/sync"
 	"github.com/ije/gox/utils"
+	"github.com/ije/gox/valid"
 )
 
 const (
@@ -48,7 +49,9 @@ type NpmRegistry struct {
 type NpmRC struct {
 	NpmRegistry
 	ScopedRegistries map[string]NpmRegistry `json:"scopedRegistries"`
+	GitTokens        map[string]string      `json:"gitTokens"`
 	zoneId           string
+	guarded          bool // the registries are provided by the request, see `newFetchClient`
 }
 
 func DefaultNpmRC() *NpmRC {
@@ -87,6 +90,57 @@ func NewNpmRcFromJSON(jsonData []byte) (npmrc *NpmRC, err error) {
 	if err != nil {
 		return nil, err
 	}
+	rc.normalize()
+	return &rc, nil
+}
+
+// NewNpmRcFromIni creates a NpmRC from the content of a `.npmrc` file.
+// The `${VAR}` references in the content are not expanded.
+func NewNpmRcFromIni(data []byte) (npmrc *NpmRC, err error) {
+	rc, err := npm.ParseRC(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	npmrc = &NpmRC{
+		NpmRegistry:      toNpmRegistry(rc.RCRegistry),
+		ScopedRegistries: make(map[string]NpmRegistry, len(rc.ScopedRegistries)),
+		// e.g. `//github.com/:_authToken=***`
+		GitTokens: rc.AuthTokens,
+	}
+	for scope, reg := range rc.ScopedRegistries {
+		npmrc.ScopedRegistries[scope] = toNpmRegistry(reg)
+	}
+	npmrc.normalize()
+	return
+}
+
+// NewNpmRcFromHeader creates a NpmRC from the `X-Npmrc` header.
+// The header value can be a JSON object, or a base64 encoded `.npmrc` file.
+func NewNpmRcFromHeader(value string) (npmrc *NpmRC, err error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		return NewNpmRcFromJSON([]byte(value))
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		data, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil {
+			return nil, errors.New("invalid npmrc, require JSON or base64 encoded `.npmrc` file")
+		}
+	}
+	return NewNpmRcFromIni(data)
+}
+
+func toNpmRegistry(reg npm.RCRegistry) NpmRegistry {
+	return NpmRegistry{
+		Registry: reg.Registry,
+		Token:    reg.Token,
+		User:     reg.User,
+		Password: reg.Password,
+	}
+}
+
+func (rc *NpmRC) normalize() {
 	if rc.Registry == "" {
 		rc.Registry = config.NpmRegistry
 	} else if !strings.HasSuffix(rc.Registry, "/") {
@@ -100,12 +154,115 @@ func NewNpmRcFromJSON(jsonData []byte) (npmrc *NpmRC, err error) {
 			Registry: jsrRegistry,
 		}
 	}
-	for _, reg := range rc.ScopedRegistries {
+	for scope, reg := range rc.ScopedRegistries {
 		if reg.Registry != "" && !strings.HasSuffix(reg.Registry, "/") {
 			reg.Registry += "/"
+			rc.ScopedRegistries[scope] = reg
 		}
 	}
-	return &rc, nil
+}
+
+// getGitToken returns the token of the given git host, the token of the npmrc takes precedence over the global config.
+// The server tokens are only used for the requests with a zone, as the builds of private repositories must be scoped
+// to the zone, only public repositories are accessible without a zone.
+func (rc *NpmRC) getGitToken(host string) string {
+	if token := rc.GitTokens[host]; token != "" {
+		return token
+	}
+	if rc.zoneId == "" {
+		return ""
+	}
+	return getServerGitToken(host)
+}
+
+// getServerGitToken returns the token of the given git host from the global config.
+func getServerGitToken(host string) string {
+	if host == "github.com" {
+		return config.GithubToken
+	}
+	if gitHost, ok := getGitHost(host); ok {
+		return gitHost.Token
+	}
+	return ""
+}
+
+// getGitHost returns the git host with the token of the npmrc.
+func (rc *NpmRC) getGitHost(host string) (gitHost GitHost, ok bool) {
+	gitHost, ok = getGitHost(host)
+	if ok {
+		gitHost.Token = rc.getGitToken(host)
+	}
+	return
+}
+
+// withZone returns the npmrc scoped to the given zone id of the `X-Zone-Id` header. The zone is only applied if the
+// request accesses private packages with the credentials of the npmrc or the server, the given pathname is empty for
+// the requests that may import any package, e.g. `POST /transform`.
+func (rc *NpmRC) withZone(zoneId string, pathname string) *NpmRC {
+	if zoneId != "" {
+		if !valid.IsDomain(zoneId) {
+			zoneId = ""
+		} else if pathname == "" {
+			if !rc.hasCredentials() {
+				zoneId = ""
+			}
+		} else if gitHost := gitHostOfPath(pathname); gitHost != "" {
+			// only the repositories that are accessed with a git token(of the npmrc or the server) are scoped to the zone
+			if rc.GitTokens[gitHost] == "" && getServerGitToken(gitHost) == "" {
+				zoneId = ""
+			}
+		} else {
+			var scopeName string
+			if pkgName := toPackageName(pathname[1:]); strings.HasPrefix(pkgName, "@") {
+				scopeName = pkgName[:strings.Index(pkgName, "/")]
+			}
+			if scopeName != "" {
+				reg, ok := rc.ScopedRegistries[scopeName]
+				if !ok || reg.isPublic() {
+					zoneId = ""
+				}
+			} else if rc.NpmRegistry.isPublic() {
+				zoneId = ""
+			}
+		}
+	}
+	if zoneId != "" {
+		// copy the npmrc to not change the shared default npmrc
+		npmrc := *rc
+		npmrc.zoneId = zoneId
+		return &npmrc
+	}
+	if len(rc.GitTokens) > 0 {
+		// the builds of private repositories must be scoped to a zone,
+		// ignore the git tokens of the npmrc if no zone is specified
+		npmrc := *rc
+		npmrc.GitTokens = nil
+		return &npmrc
+	}
+	return rc
+}
+
+// hasCredentials returns true if the npmrc has any credentials of the registries or git hosts.
+func (rc *NpmRC) hasCredentials() bool {
+	if !rc.NpmRegistry.isPublic() || len(rc.GitTokens) > 0 {
+		return true
+	}
+	for _, reg := range rc.ScopedRegistries {
+		if !reg.isPublic() {
+			return true
+		}
+	}
+	return false
+}
+
+// newFetchClient creates a fetch client for the registries of the npmrc. The npmrc provided by the request(the
+// `X-Npmrc` header or the vault) may point to any url, so the client refuses to connect to the internal addresses
+// like the `/http(s)://` and `/tgz/` routes.
+func (rc *NpmRC) newFetchClient(timeout int) (client *fetch.FetchClient, recycle func()) {
+	if rc.guarded {
+		return newGuardedFetchClient("esmd/"+VERSION, timeout, nil)
+	}
+	return fetch.NewClient("esmd/"+VERSION, timeout, false)
 }
 
 func (rc *NpmRC) StoreDir() string {
@@ -115,6 +272,20 @@ func (rc *NpmRC) StoreDir() string {
 	return path.Join(config.WorkDir, "npm")
 }
 
+// isPublic returns true if the registry is the public npm/jsr registry without credentials.
+func (reg *NpmRegistry) isPublic() bool {
+	return (reg.Registry == npmRegistry || reg.Registry == jsrRegistry) && reg.Token == "" && (reg.User == "" || reg.Password == "")
+}
+
+// setAuthHeader sets the `Authorization` header of the registry.
+func (reg *NpmRegistry) setAuthHeader(header http.Header) {
+	if reg.Token != "" {
+		header.Set("Authorization", "Bearer "+reg.Token)
+	} else if reg.User != "" && reg.Password != "" {
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(reg.User+":"+reg.Password)))
+	}
+}
+
 func (npmrc *NpmRC) getRegistryByPackageName(packageName string) *NpmRegistry {
 	if strings.HasPrefix(packageName, "@") {
 		scope, _ := utils.SplitByFirstByte(packageName, '/')
@@ -127,6 +298,10 @@ func (npmrc *NpmRC) getRegistryByPackageName(packageName string) *NpmRegistry {
 }
 
 func (npmrc *NpmRC) getPackageInfo(pkgName string, version string) (packageJson *npm.PackageJSON, err error) {
+	if npmrc.isNativeJsr(pkgName) {
+		return npmrc.getJsrPackageInfo(pkgName, version)
+	}
+
 	reg := npmrc.getRegistryByPackageName(pkgName)
 	getCacheKey := func(pkgName string, pkgVersion string) string {
 		return reg.Registry + pkgName + "@" + pkgVersion
@@ -156,13 +331,9 @@ func (npmrc *NpmRC) getPackageInfo(pkgName string, version string) (packageJson
 		}
 
 		header := http.Header{}
-		if reg.Token != "" {
-			header.Set("Authorization", "Bearer "+reg.Token)
-		} else if reg.User != "" && reg.Password != "" {
-			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(reg.User+":"+reg.Password)))
-		}
+		reg.setAuthHeader(header)
 
-		fetchClient, recycle := fetch.NewClient("esmd/"+VERSION, 15, false)
+		fetchClient, recycle := npmrc.newFetchClient(15)
 		defer recycle()
 
 		retryTimes := 0
@@ -282,9 +453,17 @@ func (npmrc *NpmRC) installPackage(pkg npm.Package) (packageJson *npm.PackageJSO
 		return
 	}
 
-	if pkg.Github {
-		err = ghInstall(installDir, pkg.Name, pkg.Version)
-		// ensure 'package.json' file if not exists after installing from github
+	if pkg.Github || pkg.GitHost != "" {
+		if pkg.GitHost != "" {
+			gitHost, ok := npmrc.getGitHost(pkg.GitHost)
+			if !ok {
+				return nil, fmt.Errorf("git: host \"%s\" is not allowed", pkg.GitHost)
+			}
+			err = gitInstall(installDir, gitHost, pkg.Name, pkg.Version)
+		} else {
+			err = ghInstall(installDir, pkg.Name, pkg.Version, npmrc.getGitToken("github.com"))
+		}
+		// ensure 'package.json' file if not exists after installing from git
 		if err == nil && !existsFile(packageJsonPath) {
 			buf := bytes.NewBuffer(nil)
 			buf.WriteString(`{"name":"` + pkg.Name + `","version":"` + pkg.Version + `"`)
@@ -334,7 +513,12 @@ func (npmrc *NpmRC) installPackage(pkg npm.Package) (packageJson *npm.PackageJSO
 			}
 		}
 	} else if pkg.PkgPrNew {
-		err = fetchPackageTarball(&NpmRegistry{}, installDir, pkg.Name, "https://pkg.pr.new/"+pkg.Name+"@"+pkg.Version)
+		err = npmrc.fetchPackageTarball(&NpmRegistry{}, installDir, pkg.Name, "https://pkg.pr.new/"+pkg.Name+"@"+pkg.Version)
+	} else if pkg.Tgz {
+		// the tarball packages are installed by the `/tgz/<url>` route with the content-hash version
+		err = fmt.Errorf("tarball of package '%s' not found", pkg.String())
+	} else if npmrc.isNativeJsr(pkg.Name) {
+		err = npmrc.installJsrPackage(installDir, pkg)
 	} else {
 		info, fetchErr := npmrc.getPackageInfo(pkg.Name, pkg.Version)
 		if fetchErr != nil {
@@ -343,7 +527,11 @@ func (npmrc *NpmRC) installPackage(pkg npm.Package) (packageJson *npm.PackageJSO
 		if info.Deprecated != "" {
 			os.WriteFile(path.Join(installDir, "deprecated.txt"), []byte(info.Deprecated), 0644)
 		}
-		err = fetchPackageTarball(npmrc.getRegistryByPackageName(pkg.Name), installDir, info.Name, info.Dist.Tarball)
+		err = npmrc.fetchPackageTarball(npmrc.getRegistryByPackageName(pkg.Name), installDir, info.Name, info.Dist.Tarball)
+		if err == nil && info.Dist.Integrity != "" {
+			// the integrity hash is used by the SBOM, see `getPackageIntegrity`
+			os.WriteFile(path.Join(installDir, "integrity.txt"), []byte(info.Dist.Integrity), 0644)
+		}
 	}
 	if err != nil {
 		return
@@ -391,7 +579,7 @@ func (npmrc *NpmRC) installDependencies(wd string, pkgJson *npm.PackageJSON, npm
 				// skip installing `@types/*` packages
 				return
 			}
-			if !npm.IsExactVersion(pkg.Version) && !pkg.Github && !pkg.PkgPrNew {
+			if !npm.IsExactVersion(pkg.Version) && !pkg.Github && !pkg.PkgPrNew && pkg.GitHost == "" && !pkg.Tgz {
 				p, e := npmrc.getPackageInfo(pkg.Name, pkg.Version)
 				if e != nil {
 					return
@@ -438,21 +626,29 @@ func (npmrc *NpmRC) isDeprecated(pkgName string, pkgVersion string) (string, err
 	return string(data), nil
 }
 
-func fetchPackageTarball(reg *NpmRegistry, installDir string, pkgName string, tarballUrl string) (err error) {
+func (npmrc *NpmRC) fetchPackageTarball(reg *NpmRegistry, installDir string, pkgName string, tarballUrl string) (err error) {
+	fetchClient, recycle := npmrc.newFetchClient(30)
+	defer recycle()
+
+	return downloadPackageTarball(fetchClient, reg, tarballUrl, path.Base(installDir), func(tarball io.Reader) error {
+		err := extractPackageTarball(installDir, pkgName, tarball)
+		if err != nil {
+			// clear installDir if failed to extract tarball
+			os.RemoveAll(installDir)
+		}
+		return err
+	})
+}
+
+// downloadPackageTarball downloads the tarball of the given url and passes the response body to the `handle` function.
+func downloadPackageTarball(fetchClient *fetch.FetchClient, reg *NpmRegistry, tarballUrl string, name string, handle func(tarball io.Reader) error) (err error) {
 	u, err := url.Parse(tarballUrl)
 	if err != nil {
 		return
 	}
 
 	header := http.Header{}
-	if reg.Token != "" {
-		header.Set("Authorization", "Bearer "+reg.Token)
-	} else if reg.User != "" && reg.Password != "" {
-		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(reg.User+":"+reg.Password)))
-	}
-
-	fetchClient, recycle := fetch.NewClient("esmd/"+VERSION, 30, false)
-	defer recycle()
+	reg.setAuthHeader(header)
 
 	retryTimes := 0
 RETRY:
@@ -468,22 +664,17 @@ RETRY:
 	defer res.Body.Close()
 
 	if res.StatusCode == 404 || res.StatusCode == 401 {
-		err = fmt.Errorf("tarball of package '%s' not found", path.Base(installDir))
+		err = fmt.Errorf("tarball of package '%s' not found", name)
 		return
 	}
 
 	if res.StatusCode != 200 {
 		msg, _ := io.ReadAll(res.Body)
-		err = fmt.Errorf("could not download tarball of package '%s' (%s: %s)", path.Base(installDir), res.Status, string(msg))
+		err = fmt.Errorf("could not download tarball of package '%s' (%s: %s)", name, res.Status, string(msg))
 		return
 	}
 
-	err = extractPackageTarball(installDir, pkgName, io.LimitReader(res.Body, maxPackageTarballSize))
-	if err != nil {
-		// clear installDir if failed to extract tarball
-		os.RemoveAll(installDir)
-	}
-	return
+	return handle(io.LimitReader(res.Body, maxPackageTarballSize))
 }
 
 func extractPackageTarball(installDir string, pkgName string, tarball io.Reader) (err error) {
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/ije/gox/valid"
+	"github.com/ije/rex"
+)
+
+// npmrcVault stores the npm credentials encrypted at rest in the database, the clients register an npmrc once and
+// reference it by the opaque id via the `X-Npmrc-Id` header, instead of sending the raw tokens in the `X-Npmrc`
+// header on every request.
+type npmrcVault struct {
+	db   Database
+	keys []npmrcVaultKey // the first key encrypts, all keys decrypt for the key rotation
+}
+
+type npmrcVaultKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+type npmrcVaultRecord struct {
+	Owner     string `json:"owner"`
+	KeyId     string `json:"kid"`
+	Data      []byte `json:"data"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+var errNpmrcNotFound = errors.New("npmrc not found")
+
+// newNpmrcVault creates a vault with the secrets of the `npmrcVaultKeys` config, returns nil if no secret is set.
+func newNpmrcVault(db Database, secrets []string) (*npmrcVault, error) {
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+	vault := &npmrcVault{db: db}
+	for _, secret := range secrets {
+		if len(secret) < 16 {
+			return nil, errors.New("npmrc vault key must be at least 16 characters")
+		}
+		key := sha256.Sum256([]byte(secret))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		kid := sha256.Sum256(key[:])
+		vault.keys = append(vault.keys, npmrcVaultKey{id: hex.EncodeToString(kid[:4]), aead: aead})
+	}
+	return vault, nil
+}
+
+// Get returns the decrypted npmrc and the owner of the id.
+func (v *npmrcVault) Get(id string) (npmrc *NpmRC, owner string, err error) {
+	record, err := v.getRecord(id)
+	if err != nil {
+		return
+	}
+	for _, key := range v.keys {
+		if key.id != record.KeyId {
+			continue
+		}
+		nonceSize := key.aead.NonceSize()
+		if len(record.Data) < nonceSize {
+			return nil, "", errors.New("invalid npmrc record")
+		}
+		var data []byte
+		data, err = key.aead.Open(nil, record.Data[:nonceSize], record.Data[nonceSize:], []byte(id))
+		if err != nil {
+			return
+		}
+		npmrc, err = NewNpmRcFromJSON(data)
+		return npmrc, record.Owner, err
+	}
+	return nil, "", errors.New("npmrc vault key not found")
+}
+
+// Put encrypts the npmrc with the current key and stores it, the creation time is kept if the id exists.
+func (v *npmrcVault) Put(id string, owner string, npmrc *NpmRC) error {
+	data, err := json.Marshal(npmrc)
+	if err != nil {
+		return err
+	}
+	key := v.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	record := npmrcVaultRecord{
+		Owner:     owner,
+		KeyId:     key.id,
+		Data:      key.aead.Seal(nonce, nonce, data, []byte(id)),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if prev, err := v.getRecord(id); err == nil {
+		record.CreatedAt = prev.CreatedAt
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return v.db.Put("npmrc:"+id, value)
+}
+
+// Delete revokes the npmrc of the id.
+func (v *npmrcVault) Delete(id string) error {
+	return v.db.Delete("npmrc:" + id)
+}
+
+func (v *npmrcVault) getRecord(id string) (record *npmrcVaultRecord, err error) {
+	if len(id) != 64 || !valid.IsHexString(id) {
+		return nil, errNpmrcNotFound
+	}
+	value, err := v.db.Get("npmrc:" + id)
+	if err != nil {
+		return
+	}
+	if value == nil {
+		return nil, errNpmrcNotFound
+	}
+	record = &npmrcVaultRecord{}
+	err = json.Unmarshal(value, record)
+	return
+}
+
+// getRequestNpmRC returns the npmrc of the request from the `X-Npmrc-Id` header(stored in the vault) or the
+// `X-Npmrc` header, returns the default npmrc if neither is present.
+func getRequestNpmRC(r *http.Request, vault *npmrcVault) (*NpmRC, error) {
+	if id := r.Header.Get("X-Npmrc-Id"); id != "" {
+		if vault == nil {
+			return nil, errors.New("npmrc vault is not enabled")
+		}
+		npmrc, _, err := vault.Get(id)
+		if err != nil {
+			if err == errNpmrcNotFound {
+				return nil, errors.New("invalid npmrc id")
+			}
+			return nil, err
+		}
+		npmrc.guarded = true
+		return npmrc, nil
+	}
+	if v := r.Header.Get("X-Npmrc"); v != "" {
+		npmrc, err := NewNpmRcFromHeader(v)
+		if err != nil {
+			return nil, errors.New("invalid npmrc header")
+		}
+		npmrc.guarded = true
+		return npmrc, nil
+	}
+	return DefaultNpmRC(), nil
+}
+
+// handleNpmrcVault handles the vault endpoints, which require an API key:
+//   - POST /npmrc registers an npmrc, returns the opaque id
+//   - POST /npmrc/rotate replaces the credentials of the id, and re-encrypts them with the current key
+//   - POST /npmrc/revoke revokes the id
+func handleNpmrcVault(ctx *rex.Context, vault *npmrcVault, pathname string) any {
+	if vault == nil {
+		return rex.Err(404, "npmrc vault is not enabled")
+	}
+	apiKey := getRequestApiKey(ctx.R)
+	if apiKey == nil {
+		ctx.SetHeader("WWW-Authenticate", "Bearer")
+		return rex.Err(401, "API Key Required")
+	}
+	var input struct {
+		Id    string `json:"id"`
+		Npmrc string `json:"npmrc"`
+	}
+	err := json.NewDecoder(io.LimitReader(ctx.R.Body, MB)).Decode(&input)
+	if err != nil {
+		return rex.Err(400, "require valid json body")
+	}
+	ctx.SetHeader("Cache-Control", "private, no-store")
+
+	var npmrc *NpmRC
+	if pathname != "/npmrc/revoke" {
+		if input.Npmrc == "" {
+			return rex.Err(400, "Missing npmrc")
+		}
+		npmrc, err = NewNpmRcFromHeader(input.Npmrc)
+		if err != nil {
+			return rex.Err(400, "Invalid npmrc")
+		}
+	}
+
+	if pathname == "/npmrc" {
+		buf := make([]byte, 32)
+		_, err = rand.Read(buf)
+		if err != nil {
+			return rex.Err(500, err.Error())
+		}
+		id := hex.EncodeToString(buf)
+		err = vault.Put(id, apiKey.Name, npmrc)
+		if err != nil {
+			return rex.Err(500, err.Error())
+		}
+		return map[string]any{"id": id}
+	}
+
+	// only the owner can rotate or revoke the npmrc
+	record, err := vault.getRecord(input.Id)
+	if err != nil {
+		if err == errNpmrcNotFound {
+			return rex.Err(404, "npmrc not found")
+		}
+		return rex.Err(500, err.Error())
+	}
+	if record.Owner != apiKey.Name {
+		return rex.Err(403, "forbidden")
+	}
+	if pathname == "/npmrc/revoke" {
+		err = vault.Delete(input.Id)
+		if err != nil {
+			return rex.Err(500, err.Error())
+		}
+		return map[string]any{"revoked": true}
+	}
+	err = vault.Put(input.Id, apiKey.Name, npmrc)
+	if err != nil {
+		return rex.Err(500, err.Error())
+	}
+	return map[string]any{"id": input.Id}
+}
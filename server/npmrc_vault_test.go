@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+type memDB struct {
+	m sync.Map
+}
+
+func (db *memDB) Get(key string) ([]byte, error) {
+	if v, ok := db.m.Load(key); ok {
+		return v.([]byte), nil
+	}
+	return nil, nil
+}
+
+func (db *memDB) Put(key string, value []byte) error {
+	db.m.Store(key, value)
+	return nil
+}
+
+func (db *memDB) Delete(key string) error {
+	db.m.Delete(key)
+	return nil
+}
+
+func (db *memDB) Close() error {
+	return nil
+}
+
+func TestNpmrcVault(t *testing.T) {
+	db := &memDB{}
+	vault, err := newNpmrcVault(db, []string{"old-secret-0123456789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	err = vault.Put(id, "ci", &NpmRC{NpmRegistry: NpmRegistry{Registry: "https://npm.example.com/", Token: "secret-token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := db.Get("npmrc:" + id)
+	if len(raw) == 0 || bytes.Contains(raw, []byte("secret-token")) {
+		t.Fatal("the npmrc should be stored encrypted")
+	}
+
+	// rotate the vault secret, the records encrypted by the old secret can still be decrypted
+	vault, err = newNpmrcVault(db, []string{"new-secret-0123456789", "old-secret-0123456789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	npmrc, owner, err := vault.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != "ci" || npmrc.Registry != "https://npm.example.com/" || npmrc.Token != "secret-token" {
+		t.Fatalf("unexpected npmrc: %+v, owner: %s", npmrc, owner)
+	}
+
+	// the record can not be moved to another id
+	other := "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	db.Put("npmrc:"+other, raw)
+	if _, _, err = vault.Get(other); err == nil {
+		t.Fatal("the record should be bound to the id")
+	}
+
+	err = vault.Delete(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = vault.Get(id); err != errNpmrcNotFound {
+		t.Fatalf("expected errNpmrcNotFound, got %v", err)
+	}
+	if _, _, err = vault.Get("invalid"); err != errNpmrcNotFound {
+		t.Fatalf("expected errNpmrcNotFound, got %v", err)
+	}
+}
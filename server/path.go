@@ -15,6 +15,8 @@ import (
 type EsmPath struct {
 	GhPrefix      bool
 	PrPrefix      bool
+	GitHost       string
+	TgzPrefix     bool
 	PkgName       string
 	PkgVersion    string
 	SubPath       string
@@ -25,6 +27,8 @@ func (p EsmPath) Package() npm.Package {
 	return npm.Package{
 		Github:   p.GhPrefix,
 		PkgPrNew: p.PrPrefix,
+		GitHost:  p.GitHost,
+		Tgz:      p.TgzPrefix,
 		Name:     p.PkgName,
 		Version:  p.PkgVersion,
 	}
@@ -41,6 +45,12 @@ func (p EsmPath) Name() string {
 	if p.PrPrefix {
 		return "pr/" + name
 	}
+	if p.GitHost != "" {
+		return "git/" + p.GitHost + "/" + name
+	}
+	if p.TgzPrefix {
+		return "tgz/" + name
+	}
 	return name
 }
 
@@ -81,7 +91,13 @@ func praseEsmPath(npmrc *NpmRC, pathname string) (esm EsmPath, extraQuery string
 		return
 	}
 
+	// e.g. /tgz/https://example.com/pkg-1.0.0.tgz
+	if strings.HasPrefix(pathname, "/tgz/") {
+		return parseTgzPath(npmrc, pathname[5:])
+	}
+
 	var ghPrefix bool
+	var gitHost string
 	if strings.HasPrefix(pathname, "/gh/") {
 		if !strings.ContainsRune(pathname[4:], '/') {
 			err = errors.New("invalid path")
@@ -98,6 +114,20 @@ func praseEsmPath(npmrc *NpmRC, pathname string) (esm EsmPath, extraQuery string
 		// add a leading `@` to the package name
 		pathname = "/@" + pathname[12:]
 		ghPrefix = true
+	} else if strings.HasPrefix(pathname, "/git/") {
+		// e.g. /git/gitlab.com/owner/repo@tag/path
+		host, rest := utils.SplitByFirstByte(pathname[5:], '/')
+		if _, ok := getGitHost(host); !ok {
+			err = fmt.Errorf("invalid git host '%s'", host)
+			return
+		}
+		if !strings.ContainsRune(rest, '/') {
+			err = errors.New("invalid path")
+			return
+		}
+		// add a leading `@` to the package name
+		pathname = "/@" + rest
+		gitHost = host
 	} else if strings.HasPrefix(pathname, "/jsr/") {
 		segs := strings.Split(pathname[5:], "/")
 		if len(segs) < 2 || !strings.HasPrefix(segs[0], "@") {
@@ -127,7 +157,7 @@ func praseEsmPath(npmrc *NpmRC, pathname string) (esm EsmPath, extraQuery string
 	}
 
 	// strip the leading `@` added before
-	if ghPrefix {
+	if ghPrefix || gitHost != "" {
 		pkgName = pkgName[1:]
 	}
 
@@ -142,6 +172,7 @@ func praseEsmPath(npmrc *NpmRC, pathname string) (esm EsmPath, extraQuery string
 		SubPath:       subPath,
 		SubModuleName: stripEntryModuleExt(subPath),
 		GhPrefix:      ghPrefix,
+		GitHost:       gitHost,
 	}
 
 	// workaround for es5-ext "../#/.." path
@@ -149,13 +180,18 @@ func praseEsmPath(npmrc *NpmRC, pathname string) (esm EsmPath, extraQuery string
 		esm.SubModuleName = strings.ReplaceAll(esm.SubModuleName, "/%23/", "/#/")
 	}
 
-	if ghPrefix {
+	if ghPrefix || gitHost != "" {
 		if npm.IsExactVersion(strings.TrimPrefix(esm.PkgVersion, "v")) {
 			exactVersion = true
 			return
 		}
 		var refs []GitRef
-		refs, err = listGhRepoRefs(fmt.Sprintf("https://github.com/%s", esm.PkgName))
+		if gitHost != "" {
+			gh, _ := npmrc.getGitHost(gitHost)
+			refs, err = gh.listRepoRefs(esm.PkgName)
+		} else {
+			refs, err = listGhRepoRefs(fmt.Sprintf("https://github.com/%s", esm.PkgName), npmrc.getGitToken("github.com"))
+		}
 		if err != nil {
 			return
 		}
@@ -218,6 +254,52 @@ func praseEsmPath(npmrc *NpmRC, pathname string) (esm EsmPath, extraQuery string
 	return
 }
 
+// gitHostOfPath returns the git host of the `/gh/`, `/github.com/` or `/git/<host>/` pathname.
+func gitHostOfPath(pathname string) string {
+	if strings.HasPrefix(pathname, "/*") {
+		pathname = "/" + pathname[2:]
+	}
+	if strings.HasPrefix(pathname, "/gh/") || strings.HasPrefix(pathname, "/github.com/") {
+		return "github.com"
+	}
+	if strings.HasPrefix(pathname, "/git/") {
+		host, _ := utils.SplitByFirstByte(pathname[5:], '/')
+		return host
+	}
+	return ""
+}
+
+// splitAsteriskPrefix strips the asterisk prefix that marks all dependencies as external, e.g.
+// "/*react-dom@19.0.0" -> "/react-dom@19.0.0", "/gh/*owner/repo" -> "/gh/owner/repo"
+func splitAsteriskPrefix(pathname string) (string, bool) {
+	if strings.HasPrefix(pathname, "/*") {
+		return "/" + pathname[2:], true
+	}
+	if strings.HasPrefix(pathname, "/gh/*") {
+		return "/gh/" + pathname[5:], true
+	}
+	if strings.HasPrefix(pathname, "/github.com/*") {
+		return "/gh/" + pathname[13:], true
+	}
+	if strings.HasPrefix(pathname, "/pr/*") {
+		return "/pr/" + pathname[5:], true
+	}
+	if strings.HasPrefix(pathname, "/pkg.pr.new/*") {
+		return "/pr/" + pathname[13:], true
+	}
+	if strings.HasPrefix(pathname, "/tgz/*") {
+		return "/tgz/" + pathname[6:], true
+	}
+	if strings.HasPrefix(pathname, "/git/") {
+		// e.g. /git/gitlab.com/*owner/repo
+		host, rest := utils.SplitByFirstByte(pathname[5:], '/')
+		if strings.HasPrefix(rest, "*") {
+			return "/git/" + host + "/" + rest[1:], true
+		}
+	}
+	return pathname, false
+}
+
 func splitEsmPath(pathname string) (pkgName string, version string, subPath string, hasTargetSegment bool) {
 	a := strings.Split(strings.TrimPrefix(pathname, "/"), "/")
 	nameAndVersion := ""
@@ -247,11 +329,9 @@ func validateTargetSegment(segments []string) bool {
 		return false
 	}
 	if strings.HasPrefix(segments[0], "X-") && len(segments) > 2 {
-		_, ok := targets[segments[1]]
-		return ok
+		return isValidBuildTarget(segments[1])
 	}
-	_, ok := targets[segments[0]]
-	return ok
+	return isValidBuildTarget(segments[0])
 }
 
 func toPackageName(specifier string) string {
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ije/gox/set"
+)
+
+// getModulePreloadLinks returns the `rel=modulepreload` links of the transitive static imports in breadth-first order,
+// up to the `preloadDepth` and `preloadLimit` config. The build metas of the imports are read from the database, the
+// missing builds are not triggered. The links are cached once all the imports are built, as the builds are immutable.
+func (ctx *BuildContext) getModulePreloadLinks(imports []string, origin string) []string {
+	if config.PreloadDepth <= 0 || config.PreloadLimit <= 0 || len(imports) == 0 {
+		return nil
+	}
+	cacheKey := "preload:" + ctx.npmrc.zoneId + ":" + origin + ":" + strings.Join(imports, ",")
+	if v, ok := cacheLRU.Get(cacheKey); ok {
+		return v.([]string)
+	}
+	links := make([]string, 0, min(len(imports), config.PreloadLimit))
+	seen := set.New[string]()
+	level := imports
+	complete := true
+	for depth := 1; depth <= config.PreloadDepth && len(level) > 0; depth++ {
+		var next []string
+		for _, importPath := range level {
+			if seen.Has(importPath) {
+				continue
+			}
+			seen.Add(importPath)
+			links = append(links, fmt.Sprintf("<%s%s>; rel=modulepreload", origin, importPath))
+			if len(links) >= config.PreloadLimit {
+				break
+			}
+			// node builtin polyfills have no dependencies, e.g. "/node/process.mjs"
+			if depth < config.PreloadDepth && strings.HasPrefix(importPath, "/") && !strings.HasPrefix(importPath, "/node/") {
+				b := &BuildContext{
+					npmrc:  ctx.npmrc,
+					logger: ctx.logger,
+					db:     ctx.db,
+					path:   strings.SplitN(importPath, "?", 2)[0],
+				}
+				if meta, ok, _ := b.Exists(); ok {
+					next = append(next, meta.Imports...)
+				} else {
+					complete = false
+				}
+			}
+		}
+		if len(links) >= config.PreloadLimit {
+			break
+		}
+		level = next
+	}
+	if complete {
+		cacheLRU.Add(cacheKey, links)
+	}
+	return links
+}
+
+// setModulePreloadHeaders adds the `Link` headers of the module preload links to the response.
+func setModulePreloadHeaders(w http.ResponseWriter, links []string) {
+	for _, link := range links {
+		w.Header().Add("Link", link)
+	}
+}
+
+// sendEarlyHints sends the module preload links as the HTTP 103 Early Hints, it should be called before the slow work
+// of the request, e.g. waiting on the build. The informational response is written to the innermost writer that is
+// found by the `Unwrap` method(like `http.ResponseController`), which must be capable of hijacking(HTTP/1.x) or pushing
+// (HTTP/2) like the writers of `net/http`, other writers may treat the informational status as the final one.
+func sendEarlyHints(w http.ResponseWriter, r *http.Request, links []string) {
+	if len(links) == 0 || !r.ProtoAtLeast(1, 1) {
+		return
+	}
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	_, isHijacker := w.(http.Hijacker)
+	_, isPusher := w.(http.Pusher)
+	if !isHijacker && !isPusher {
+		return
+	}
+	header := w.Header()
+	for _, link := range links {
+		header.Add("Link", link)
+	}
+	w.WriteHeader(http.StatusEarlyHints)
+	// the final response adds the links again, see `setModulePreloadHeaders`
+	header.Del("Link")
+}
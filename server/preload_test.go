@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+)
+
+func TestSendEarlyHints(t *testing.T) {
+	links := []string{"<https://esm.sh/react@19.0.0/es2022/react.mjs>; rel=modulepreload"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/wrapped" {
+			// the wrapped writer that doesn't expose the underlying writer is skipped
+			w = struct{ http.ResponseWriter }{w}
+		}
+		sendEarlyHints(w, r, links)
+		setModulePreloadHeaders(w, links)
+		w.Write([]byte("export default 1;"))
+	}))
+	defer server.Close()
+
+	for _, c := range []struct {
+		path       string
+		earlyHints bool
+	}{{"/", true}, {"/wrapped", false}} {
+		var earlyHints []textproto.MIMEHeader
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				if code == http.StatusEarlyHints {
+					earlyHints = append(earlyHints, header)
+				}
+				return nil
+			},
+		}
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", server.URL+c.path, nil)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != 200 || len(res.Header.Values("Link")) != 1 || res.Header.Get("Link") != links[0] {
+			t.Fatalf("%s: unexpected response: %d %v", c.path, res.StatusCode, res.Header)
+		}
+		if c.earlyHints && (len(earlyHints) != 1 || earlyHints[0].Get("Link") != links[0]) {
+			t.Fatalf("%s: unexpected early hints: %v", c.path, earlyHints)
+		}
+		if !c.earlyHints && len(earlyHints) != 0 {
+			t.Fatalf("%s: unexpected early hints: %v", c.path, earlyHints)
+		}
+	}
+}
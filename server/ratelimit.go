@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ije/rex"
+)
+
+type rateLimitContextKey struct{}
+
+// rateLimitClient charges the budgets of a client, it's stored in the request context by the `rateLimit` middleware.
+type rateLimitClient struct {
+	id      string
+	options *RateLimitOptions
+	limiter *rateLimiter
+}
+
+// rateLimit returns a middleware that limits the requests per client(IP address or API key) with separate budgets
+// for the requests, the new builds and the transform calls.
+func rateLimit(options *RateLimitOptions) rex.Handle {
+	limiter := newRateLimiter(time.Minute)
+	return func(ctx *rex.Context) any {
+		client := &rateLimitClient{
+			id:      getRateLimitClientId(ctx.R, options.ClientIPHeader),
+			options: options,
+			limiter: limiter,
+		}
+		pathname := ctx.R.URL.Path
+		budget, limit := "requests", options.Requests
+		if route := matchRateLimitRoute(options.Routes, pathname); route != "" {
+			budget, limit = "route:"+route, options.Routes[route]
+		} else if isTransformCall(ctx.R) {
+			budget, limit = "transforms", options.Transforms
+		}
+		if res := client.charge(ctx, budget, limit); res != nil {
+			return res
+		}
+		ctx.R = ctx.R.WithContext(context.WithValue(ctx.R.Context(), rateLimitContextKey{}, client))
+		return ctx.Next()
+	}
+}
+
+// Enabled returns true if any limit is configured.
+func (options *RateLimitOptions) Enabled() bool {
+	return options.Requests > 0 || options.Builds > 0 || options.Transforms > 0 || len(options.Routes) > 0
+}
+
+// charge counts a request of the budget, returns the 429 response if the limit is exceeded.
+func (c *rateLimitClient) charge(ctx *rex.Context, budget string, limit int) any {
+	if limit <= 0 {
+		return nil
+	}
+	ok, retryAfter := c.limiter.Allow(budget+"|"+c.id, limit)
+	if !ok {
+		ctx.SetHeader("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+		ctx.SetHeader("Cache-Control", ccMustRevalidate)
+		return rex.Status(http.StatusTooManyRequests, "Too Many Requests")
+	}
+	return nil
+}
+
+// checkBuildRateLimit charges the build budget of the client before a new build, returns the 429 response if the
+// limit is exceeded.
+func checkBuildRateLimit(ctx *rex.Context) any {
+	client, ok := ctx.R.Context().Value(rateLimitContextKey{}).(*rateLimitClient)
+	if !ok {
+		return nil
+	}
+	return client.charge(ctx, "builds", client.options.Builds)
+}
+
+// errBuildRateLimited is returned when the build budget of the client is exhausted, see `buildBudget`.
+var errBuildRateLimited = errors.New("too many builds, please try again later")
+
+// buildBudget charges the builds triggered on behalf of the client by the APIs that build many modules in parallel,
+// e.g. `/importmap` and `/graph/`. The remaining builds are refused once the budget is exhausted.
+type buildBudget struct {
+	ctx       *rex.Context
+	lock      sync.Mutex
+	exhausted bool
+}
+
+// charge charges a build to the budget, returns false if the budget is exhausted.
+func (b *buildBudget) charge() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.exhausted && checkBuildRateLimit(b.ctx) != nil {
+		b.exhausted = true
+	}
+	return !b.exhausted
+}
+
+// getRateLimitClientId returns the API key name of the authenticated request, or the client IP address.
+func getRateLimitClientId(r *http.Request, clientIPHeader string) string {
+	if apiKey := getRequestApiKey(r); apiKey != nil {
+		return "apikey:" + apiKey.Name
+	}
+	if clientIPHeader != "" {
+		if v := r.Header.Get(clientIPHeader); v != "" {
+			// the `X-Forwarded-For` header may contain a list of IP addresses
+			ip, _, _ := strings.Cut(v, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// matchRateLimitRoute returns the longest route pattern that matches the pathname.
+func matchRateLimitRoute(routes map[string]int, pathname string) (matched string) {
+	for route := range routes {
+		if len(route) > len(matched) && matchRoutes([]string{route}, pathname) {
+			matched = route
+		}
+	}
+	return
+}
+
+// isTransformCall checks if the request calls the transform APIs or imports a http module.
+func isTransformCall(r *http.Request) bool {
+	pathname := r.URL.Path
+	if r.Method == "POST" {
+		return pathname == "/transform" || pathname == "/bundle"
+	}
+	return strings.HasPrefix(pathname, "/http://") || strings.HasPrefix(pathname, "/https://")
+}
+
+// rateLimiter is a fixed-window rate limiter keyed by an arbitrary string, e.g. the API key name.
+type rateLimiter struct {
+	lock     sync.Mutex
+	window   time.Duration
+	counters map[string]*rateCounter
+}
+
+type rateCounter struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(window time.Duration) *rateLimiter {
+	return &rateLimiter{window: window, counters: map[string]*rateCounter{}}
+}
+
+// Allow counts a request of the key, returns false and the duration until the next window if the limit is exceeded.
+func (l *rateLimiter) Allow(key string, limit int) (ok bool, retryAfter time.Duration) {
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	c, found := l.counters[key]
+	if !found || now.Sub(c.start) >= l.window {
+		if len(l.counters) > 10000 {
+			l.gc(now)
+		}
+		c = &rateCounter{start: now}
+		l.counters[key] = c
+	}
+	if c.count >= limit {
+		return false, c.start.Add(l.window).Sub(now)
+	}
+	c.count++
+	return true, 0
+}
+
+// gc removes the expired counters.
+func (l *rateLimiter) gc(now time.Time) {
+	for key, c := range l.counters {
+		if now.Sub(c.start) >= l.window {
+			delete(l.counters, key)
+		}
+	}
+}
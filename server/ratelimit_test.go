@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("a", 3); !ok {
+			t.Fatalf("request #%d should be allowed", i+1)
+		}
+	}
+	ok, retryAfter := limiter.Allow("a", 3)
+	if ok || retryAfter <= 0 || retryAfter > 50*time.Millisecond {
+		t.Fatalf("request #4 should be limited, retryAfter: %v", retryAfter)
+	}
+	if ok, _ := limiter.Allow("b", 3); !ok {
+		t.Fatal("the budgets of different keys should be separated")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := limiter.Allow("a", 3); !ok {
+		t.Fatal("request should be allowed in the next window")
+	}
+
+	routes := map[string]int{"/gh/*": 10, "/gh/owner/*": 5, "/importmap": 30}
+	if route := matchRateLimitRoute(routes, "/gh/owner/repo"); route != "/gh/owner/*" {
+		t.Fatalf("unexpected route: %s", route)
+	}
+	if route := matchRateLimitRoute(routes, "/react"); route != "" {
+		t.Fatalf("unexpected route: %s", route)
+	}
+
+	r := httptest.NewRequest("GET", "/react", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	r.Header.Set("X-Forwarded-For", "5.6.7.8, 10.0.0.1")
+	if id := getRateLimitClientId(r, ""); id != "1.2.3.4" {
+		t.Fatalf("unexpected client id: %s", id)
+	}
+	if id := getRateLimitClientId(r, "X-Forwarded-For"); id != "5.6.7.8" {
+		t.Fatalf("unexpected client id: %s", id)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"path"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -46,6 +47,8 @@ const (
 	EsmSourceMap
 	// *.d.ts
 	EsmDts
+	// external assets of the build, e.g. /pkg@1.0.0/es2022/logo-XXXXXXXX.png
+	EsmAsset
 	// package raw file
 	RawFile
 )
@@ -68,6 +71,11 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 		buildQueue = NewBuildQueue(int(config.BuildConcurrency))
 	)
 
+	npmrcVault, err := newNpmrcVault(db, config.NpmrcVaultKeys)
+	if err != nil {
+		logger.Fatalf("init npmrc vault: %v", err)
+	}
+
 	return func(ctx *rex.Context) any {
 		pathname := ctx.R.URL.Path
 
@@ -95,7 +103,9 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				if len(options.Code) > MB {
 					return rex.Err(429, "Code is too large")
 				}
-				if targets[options.Target] == 0 {
+				if target, ok := normalizeBuildTarget(options.Target); ok {
+					options.Target = target
+				} else {
 					options.Target = "esnext"
 				}
 				if options.Lang == "" && options.Filename != "" {
@@ -110,10 +120,20 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				h.Write([]byte(options.JsxImportSource))
 				h.Write([]byte(options.SourceMap))
 				h.Write([]byte(fmt.Sprintf("%v", options.Minify)))
+				if options.Declaration {
+					h.Write([]byte("declaration"))
+				}
 				hash := hex.EncodeToString(h.Sum(nil))
 
+				npmrc, err := getRequestNpmRC(ctx.R, npmrcVault)
+				if err != nil {
+					return rex.Err(400, err.Error())
+				}
+				npmrc = npmrc.withZone(ctx.R.Header.Get("X-Zone-Id"), "")
+
 				// if previous build exists, return it directly
-				savePath := normalizeSavePath(ctx.R.Header.Get("X-Zone-Id"), fmt.Sprintf("modules/transform/%s.mjs", hash))
+				savePath := normalizeSavePath(npmrc.zoneId, fmt.Sprintf("modules/transform/%s.mjs", hash))
+				dtsSavePath := strings.TrimSuffix(savePath, ".mjs") + ".d.ts"
 				if file, _, err := buildStorage.Get(savePath); err == nil {
 					data, err := io.ReadAll(file)
 					file.Close()
@@ -131,6 +151,16 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 							output.Map = string(data)
 						}
 					}
+					if options.Declaration {
+						file, _, err = buildStorage.Get(dtsSavePath)
+						if err == nil {
+							data, err = io.ReadAll(file)
+							file.Close()
+							if err == nil {
+								output.Dts = string(data)
+							}
+						}
+					}
 					return output
 				}
 
@@ -144,6 +174,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 
 				output, err := transform(&ResolvedTransformOptions{
 					TransformOptions: options,
+					npmrc:            npmrc,
 					importMap:        importMap,
 				})
 				if err != nil {
@@ -153,10 +184,173 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 					output.Code = fmt.Sprintf("%s//# sourceMappingURL=+%s", output.Code, path.Base(savePath)+".map")
 					go buildStorage.Put(savePath+".map", strings.NewReader(output.Map))
 				}
+				if len(output.Dts) > 0 {
+					// the declaration must be stored before the module for the `X-TypeScript-Types` header
+					err = buildStorage.Put(dtsSavePath, strings.NewReader(output.Dts))
+					if err != nil {
+						return rex.Err(500, "Storage error, please try again")
+					}
+				}
 				go buildStorage.Put(savePath, strings.NewReader(output.Code))
 				ctx.SetHeader("Cache-Control", ccMustRevalidate)
 				return output
 
+			case "/bundle":
+				var options BundleOptions
+				err := json.NewDecoder(io.LimitReader(ctx.R.Body, 5*MB)).Decode(&options)
+				ctx.R.Body.Close()
+				if err != nil {
+					return rex.Err(400, "require valid json body")
+				}
+				if len(options.Files) == 0 {
+					return rex.Err(400, "Files is required")
+				}
+				if len(options.Files) > 1000 {
+					return rex.Err(429, "Too many files")
+				}
+				if options.Entry == "" {
+					return rex.Err(400, "Entry is required")
+				}
+				if options.Target != "" {
+					target, ok := normalizeBuildTarget(options.Target)
+					if !ok {
+						return rex.Err(400, "Invalid target")
+					}
+					options.Target = target
+				}
+				files := make(map[string]string, len(options.Files))
+				for filename, code := range options.Files {
+					files[normalizeBundleFilename(filename)] = code
+				}
+				options.Files = files
+
+				npmrc, err := getRequestNpmRC(ctx.R, npmrcVault)
+				if err != nil {
+					return rex.Err(400, err.Error())
+				}
+				zoneId := npmrc.withZone(ctx.R.Header.Get("X-Zone-Id"), "").zoneId
+
+				origin := getOrigin(ctx)
+				hash := options.Hash(origin)
+				manifestPath := normalizeSavePath(zoneId, fmt.Sprintf("modules/transform/%s.bundle.json", hash))
+
+				// if previous bundle exists, return it directly
+				if file, _, err := buildStorage.Get(manifestPath); err == nil {
+					var names []string
+					err = json.NewDecoder(file).Decode(&names)
+					file.Close()
+					if err == nil {
+						bundleFiles := make(map[string][]byte, len(names))
+						for _, name := range names {
+							f, _, e := buildStorage.Get(normalizeSavePath(zoneId, "modules/transform/"+name[1:]))
+							if e != nil {
+								err = e
+								break
+							}
+							bundleFiles[name], err = io.ReadAll(f)
+							f.Close()
+							if err != nil {
+								break
+							}
+						}
+						if err == nil {
+							ctx.SetHeader("Cache-Control", ccMustRevalidate)
+							return newBundleOutput(hash, bundleFiles, origin)
+						}
+					}
+				}
+
+				importMap := importmap.ImportMap{Imports: map[string]string{}}
+				if len(options.ImportMap) > 0 {
+					err = json.Unmarshal(options.ImportMap, &importMap)
+					if err != nil {
+						return rex.Err(400, "Invalid ImportMap")
+					}
+				}
+
+				bundleFiles, err := bundle(&ResolvedBundleOptions{
+					BundleOptions: options,
+					importMap:     importMap,
+					origin:        origin,
+				}, hash)
+				if err != nil {
+					return rex.Err(400, err.Error())
+				}
+				names := make([]string, 0, len(bundleFiles))
+				for name, data := range bundleFiles {
+					// strip the "+" prefix of the file name, e.g. "+{hash}.mjs" -> "modules/transform/{hash}.mjs"
+					err = buildStorage.Put(normalizeSavePath(zoneId, "modules/transform/"+name[1:]), bytes.NewReader(data))
+					if err != nil {
+						return rex.Err(500, "Storage error, please try again")
+					}
+					names = append(names, name)
+				}
+				data, err := json.Marshal(names)
+				if err == nil {
+					go buildStorage.Put(manifestPath, bytes.NewReader(data))
+				}
+				ctx.SetHeader("Cache-Control", ccMustRevalidate)
+				return newBundleOutput(hash, bundleFiles, origin)
+
+			case "/npmrc", "/npmrc/rotate", "/npmrc/revoke":
+				return handleNpmrcVault(ctx, npmrcVault, pathname)
+			case "/importmap":
+				var options ImportMapOptions
+				err := json.NewDecoder(io.LimitReader(ctx.R.Body, MB)).Decode(&options)
+				ctx.R.Body.Close()
+				if err != nil {
+					return rex.Err(400, "require valid json body")
+				}
+				if len(options.Packages) == 0 {
+					return rex.Err(400, "Packages is required")
+				}
+				if len(options.Packages) > 100 {
+					return rex.Err(400, "Too many packages")
+				}
+				if options.Target != "" {
+					target, ok := normalizeBuildTarget(options.Target)
+					if !ok {
+						return rex.Err(400, "Invalid target")
+					}
+					options.Target = target
+				} else if options.Integrity {
+					return rex.Err(400, "Target is required for integrity")
+				}
+
+				npmrc, err := getRequestNpmRC(ctx.R, npmrcVault)
+				if err != nil {
+					return rex.Err(400, err.Error())
+				}
+
+				g := &importMapGenerator{
+					ctx:        ctx,
+					budget:     &buildBudget{ctx: ctx},
+					npmrc:      npmrc,
+					zoneId:     ctx.R.Header.Get("X-Zone-Id"),
+					logger:     logger,
+					db:         db,
+					storage:    buildStorage,
+					buildQueue: buildQueue,
+					origin:     getOrigin(ctx),
+					options:    options,
+				}
+				output, err := g.generate()
+				if err != nil {
+					if err == errApiKeyRequired {
+						ctx.SetHeader("WWW-Authenticate", "Bearer")
+						return rex.Err(401, err.Error())
+					}
+					if errors.Is(err, errPackageForbidden) {
+						return rex.Err(403, err.Error())
+					}
+					if errors.Is(err, errBuildRateLimited) {
+						return rex.Err(429, err.Error())
+					}
+					return rex.Err(400, err.Error())
+				}
+				ctx.SetHeader("Cache-Control", ccMustRevalidate)
+				return output
+
 			default:
 				return rex.Status(404, "not found")
 			}
@@ -260,6 +454,13 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			ctx.SetHeader("Etag", globalETag)
 			return indexHTML
 
+		case signingKeysPath:
+			if buildSigner == nil {
+				return rex.Status(404, "not found")
+			}
+			ctx.SetHeader("Cache-Control", fmt.Sprintf("public, max-age=%d", config.NpmQueryCacheTTL))
+			return buildSigner.JWKS()
+
 		case "/status.json":
 			q := make([]map[string]any, buildQueue.queue.Len())
 			i := 0
@@ -362,8 +563,8 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			}
 
 			// determine build target by `?target` query or `User-Agent` header
-			target := strings.ToLower(ctx.Query().Get("target"))
-			targetFromUA := targets[target] == 0
+			target, ok := normalizeBuildTarget(ctx.Query().Get("target"))
+			targetFromUA := !ok
 			if targetFromUA {
 				target = getBuildTargetByUA(ctx.UserAgent())
 			}
@@ -383,7 +584,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				}
 				// replace `$TARGET` with the target
 				data = bytes.ReplaceAll(data, []byte("$TARGET"), []byte(target))
-				js, err = minify(string(data), esbuild.LoaderTS, targets[target])
+				js, err = minify(string(data), esbuild.LoaderTS, target)
 				return
 			})
 			if err != nil {
@@ -402,21 +603,41 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			return js
 		}
 
-		// module generated by the `/transform` API
+		// module generated by the `/transform` or `/bundle` API
 		if strings.HasPrefix(pathname, "/+") {
 			hash, ext := utils.SplitByFirstByte(pathname[2:], '.')
 			if len(hash) != 40 || !valid.IsHexString(hash) {
 				return rex.Status(404, "Not Found")
 			}
-			savePath := normalizeSavePath(ctx.R.Header.Get("X-Zone-Id"), fmt.Sprintf("modules/transform/%s.%s", hash, ext))
+			// resolve the zone the same way as the `/transform` and `/bundle` APIs that created the module
+			npmrc, err := getRequestNpmRC(ctx.R, npmrcVault)
+			if err != nil {
+				return rex.Status(400, err.Error())
+			}
+			zoneId := npmrc.withZone(ctx.R.Header.Get("X-Zone-Id"), "").zoneId
+			savePath := normalizeSavePath(zoneId, fmt.Sprintf("modules/transform/%s.%s", hash, ext))
 			f, fi, err := buildStorage.Get(savePath)
 			if err != nil {
+				if err == storage.ErrNotFound {
+					return rex.Status(404, "Not Found")
+				}
 				return rex.Status(500, err.Error())
 			}
-			if strings.HasSuffix(pathname, ".map") {
+			if strings.HasSuffix(pathname, ".map") || strings.HasSuffix(pathname, ".json") {
 				ctx.SetHeader("Content-Type", ctJSON)
+			} else if strings.HasSuffix(pathname, ".css") {
+				ctx.SetHeader("Content-Type", ctCSS)
+			} else if strings.HasSuffix(pathname, ".d.ts") {
+				ctx.SetHeader("Content-Type", ctTypeScript)
 			} else {
 				ctx.SetHeader("Content-Type", ctJavaScript)
+				// the declaration generated by the `/transform` API with the `declaration` option
+				if ext == "mjs" {
+					if _, err := buildStorage.Stat(strings.TrimSuffix(savePath, ".mjs") + ".d.ts"); err == nil {
+						ctx.SetHeader("X-TypeScript-Types", getOrigin(ctx)+"/+"+hash+".d.ts")
+						ctx.SetHeader("Access-Control-Expose-Headers", "X-TypeScript-Types")
+					}
+				}
 			}
 			ctx.SetHeader("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
 			ctx.SetHeader("Cache-Control", ccImmutable)
@@ -473,39 +694,12 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			return data
 		}
 
-		var npmrc *NpmRC
-		if v := ctx.R.Header.Get("X-Npmrc"); v != "" {
-			rc, err := NewNpmRcFromJSON([]byte(v))
-			if err != nil {
-				return rex.Status(400, "Invalid Npmrc Header")
-			}
-			npmrc = rc
-		} else {
-			npmrc = DefaultNpmRC()
+		npmrc, err := getRequestNpmRC(ctx.R, npmrcVault)
+		if err != nil {
+			return rex.Status(400, err.Error())
 		}
 
-		zoneIdHeader := ctx.R.Header.Get("X-Zone-Id")
-		if zoneIdHeader != "" {
-			if !valid.IsDomain(zoneIdHeader) {
-				zoneIdHeader = ""
-			} else {
-				var scopeName string
-				if pkgName := toPackageName(pathname[1:]); strings.HasPrefix(pkgName, "@") {
-					scopeName = pkgName[:strings.Index(pkgName, "/")]
-				}
-				if scopeName != "" {
-					reg, ok := npmrc.ScopedRegistries[scopeName]
-					if !ok || (reg.Registry == jsrRegistry && reg.Token == "" && (reg.User == "" || reg.Password == "")) {
-						zoneIdHeader = ""
-					}
-				} else if npmrc.Registry == npmRegistry && npmrc.Token == "" && (npmrc.User == "" || npmrc.Password == "") {
-					zoneIdHeader = ""
-				}
-			}
-		}
-		if zoneIdHeader != "" {
-			npmrc.zoneId = zoneIdHeader
-		}
+		npmrc = npmrc.withZone(ctx.R.Header.Get("X-Zone-Id"), pathname)
 
 		if strings.HasPrefix(pathname, "/http://") || strings.HasPrefix(pathname, "/https://") {
 			query := ctx.Query()
@@ -517,26 +711,24 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				return rex.Status(400, "Invalid URL")
 			}
 			modUrlRaw := modUrl.String()
-			// disallow localhost or ip address for production
-			if !DEBUG {
-				hostname := modUrl.Hostname()
-				if isLocalhost(hostname) || !valid.IsDomain(hostname) || modUrl.Host == ctx.R.Host {
-					return rex.Status(400, "Invalid URL")
-				}
+			// disallow localhost or ip address for production, the public ip addresses can be listed in the allow list
+			if !checkHttpModuleOrigin(modUrl) || (!DEBUG && modUrl.Host == ctx.R.Host) {
+				return rex.Status(403, "Forbidden Origin")
 			}
 			extname := path.Ext(modUrl.Path)
 			if !(slices.Contains(moduleExts, extname) || extname == ".vue" || extname == ".svelte" || extname == ".md" || extname == ".css") {
 				return redirect(ctx, modUrl.String(), true)
 			}
-			target := strings.ToLower(query.Get("target"))
-			if targets[target] == 0 {
+			target, ok := normalizeBuildTarget(query.Get("target"))
+			if !ok {
 				target = "es2022"
 			}
 			v := query.Get("v")
 			if v != "" && (!npm.Versioning.Match(v) || len(v) > 32) {
 				return rex.Status(400, "Invalid Version Param")
 			}
-			fetchClient, recycle := fetch.NewClient(ctx.UserAgent(), 15, false)
+			// guard against SSRF, the domain may resolve to (or redirect to) an internal address
+			fetchClient, recycle := newGuardedFetchClient(ctx.UserAgent(), 15, checkHttpModuleOrigin)
 			defer recycle()
 			if strings.HasSuffix(modUrl.Path, "/uno.css") {
 				ctxParam := query.Get("ctx")
@@ -557,7 +749,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				h.Write([]byte(ctxParam))
 				h.Write([]byte(target))
 				h.Write([]byte(v))
-				savePath := normalizeSavePath(zoneIdHeader, path.Join("modules/x", hex.EncodeToString(h.Sum(nil))+".css"))
+				savePath := normalizeSavePath(npmrc.zoneId, path.Join("modules/x", hex.EncodeToString(h.Sum(nil))+".css"))
 				r, _, err := buildStorage.Get(savePath)
 				if err != nil && err != storage.ErrNotFound {
 					return rex.Status(500, err.Error())
@@ -666,6 +858,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				if err != nil {
 					return rex.Status(500, "Failed to generate uno.css: "+err.Error())
 				}
+				esTarget, engines := getBuildTarget(target)
 				ret := esbuild.Build(esbuild.BuildOptions{
 					Stdin: &esbuild.StdinOptions{
 						Sourcefile: "uno.css",
@@ -675,7 +868,8 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 					Write:            false,
 					MinifyWhitespace: config.Minify,
 					MinifySyntax:     config.Minify,
-					Target:           targets[target],
+					Target:           esTarget,
+					Engines:          engines,
 				})
 				if len(ret.Errors) > 0 {
 					return rex.Status(500, ret.Errors[0].Text)
@@ -700,7 +894,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				h.Write([]byte(im))
 				h.Write([]byte(target))
 				h.Write([]byte(v))
-				savePath := normalizeSavePath(zoneIdHeader, path.Join("modules/x", hex.EncodeToString(h.Sum(nil))+".mjs"))
+				savePath := normalizeSavePath(npmrc.zoneId, path.Join("modules/x", hex.EncodeToString(h.Sum(nil))+".mjs"))
 				content, _, err := buildStorage.Get(savePath)
 				if err != nil && err != storage.ErrNotFound {
 					return rex.Status(500, err.Error())
@@ -779,6 +973,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 							Target:   target,
 							Minify:   true,
 						},
+						npmrc:         npmrc,
 						importMap:     importMap,
 						globalVersion: v,
 					})
@@ -808,25 +1003,16 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			}
 		}
 
-		// check `/*pathname` pattern
-		asteriskPrefix := false
-		if strings.HasPrefix(pathname, "/*") {
-			asteriskPrefix = true
-			pathname = "/" + pathname[2:]
-		} else if strings.HasPrefix(pathname, "/gh/*") {
-			asteriskPrefix = true
-			pathname = "/gh/" + pathname[5:]
-		} else if strings.HasPrefix(pathname, "/github.com/*") {
-			asteriskPrefix = true
-			pathname = "/gh/" + pathname[13:]
-		} else if strings.HasPrefix(pathname, "/pr/*") {
-			asteriskPrefix = true
-			pathname = "/pr/" + pathname[5:]
-		} else if strings.HasPrefix(pathname, "/pkg.pr.new/*") {
-			asteriskPrefix = true
-			pathname = "/pr/" + pathname[13:]
+		// check `/graph/pathname` pattern, e.g. /graph/react-dom@19.0.0/client
+		graphMode := false
+		if strings.HasPrefix(pathname, "/graph/") {
+			graphMode = true
+			pathname = pathname[6:]
 		}
 
+		// check `/*pathname` pattern
+		pathname, asteriskPrefix := splitAsteriskPrefix(pathname)
+
 		esm, extraQuery, isExactVersion, hasTargetSegment, err := praseEsmPath(npmrc, pathname)
 		if err != nil {
 			status := 500
@@ -845,6 +1031,15 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			return rex.Status(403, "forbidden")
 		}
 
+		// check the package scopes of the API key
+		if !config.ApiKeys.isPackageAccessible(ctx.R, esm.PkgName) {
+			if getRequestApiKey(ctx.R) == nil {
+				ctx.SetHeader("WWW-Authenticate", "Bearer")
+				return rex.Status(401, "API Key Required")
+			}
+			return rex.Status(403, "forbidden")
+		}
+
 		origin := getOrigin(ctx)
 
 		registryPrefix := ""
@@ -852,6 +1047,10 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			registryPrefix = "/gh"
 		} else if esm.PrPrefix {
 			registryPrefix = "/pr"
+		} else if esm.GitHost != "" {
+			registryPrefix = "/git/" + esm.GitHost
+		} else if esm.TgzPrefix {
+			registryPrefix = "/tgz"
 		}
 
 		// redirect `/@types/PKG` to it's main dts file
@@ -927,6 +1126,11 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				if hasTargetSegment {
 					pathKind = EsmBuild
 				}
+			case ".js":
+				// classic scripts, e.g. /react@19.0.0/es2022/react.iife.js
+				if hasTargetSegment && endsWith(esm.SubPath, ".iife.js", ".umd.js") {
+					pathKind = EsmBuild
+				}
 			case ".ts", ".mts", ".cts", ".tsx":
 				if strings.HasSuffix(strings.TrimSuffix(pathname, ext), ".d") || query.Has("dts") {
 					pathKind = EsmDts
@@ -944,7 +1148,9 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 					pathKind = RawFile
 				}
 			default:
-				if ext != "" && assetExts[ext[1:]] {
+				if hasTargetSegment && regexpExternalAsset.MatchString(esm.SubPath) {
+					pathKind = EsmAsset
+				} else if ext != "" && assetExts[ext[1:]] {
 					pathKind = RawFile
 				}
 			}
@@ -955,6 +1161,11 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			pathKind = RawFile
 		}
 
+		// the `/graph/` route only accepts the module entry, e.g. /graph/react-dom@19.0.0/client
+		if graphMode && pathKind != EsmEntry {
+			return rex.Status(400, "Invalid graph path")
+		}
+
 		// redirect to the url with exact package version
 		if !isExactVersion {
 			if hasTargetSegment {
@@ -964,6 +1175,8 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				if asteriskPrefix {
 					if esm.GhPrefix || esm.PrPrefix {
 						pkgName = pkgName[0:3] + "*" + pkgName[3:]
+					} else if esm.TgzPrefix {
+						pkgName = pkgName[0:4] + "*" + pkgName[4:]
 					} else {
 						pkgName = "*" + pkgName
 					}
@@ -1180,7 +1393,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			}
 
 			// build/dts files
-			if pathKind == EsmBuild || pathKind == EsmSourceMap || pathKind == EsmDts {
+			if pathKind == EsmBuild || pathKind == EsmSourceMap || (pathKind == EsmDts && !query.Has("dts-bundle")) || pathKind == EsmAsset {
 				var savePath string
 				if asteriskPrefix {
 					pathname = "/*" + pathname[1:]
@@ -1195,7 +1408,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				if err != nil {
 					if err != storage.ErrNotFound {
 						return rex.Status(500, err.Error())
-					} else if pathKind == EsmSourceMap {
+					} else if pathKind == EsmSourceMap || pathKind == EsmAsset {
 						return rex.Status(404, "Not found")
 					}
 				}
@@ -1206,6 +1419,8 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 						ctx.SetHeader("Content-Type", ctTypeScript)
 					} else if pathKind == EsmSourceMap {
 						ctx.SetHeader("Content-Type", ctJSON)
+					} else if pathKind == EsmAsset {
+						ctx.SetHeader("Content-Type", mime.GetContentType(pathname))
 					} else if strings.HasSuffix(pathname, ".css") {
 						ctx.SetHeader("Content-Type", ctCSS)
 					} else {
@@ -1253,12 +1468,12 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 							target := "es2022"
 							// check target in the pathname
 							for _, seg := range strings.Split(pathname, "/") {
-								if targets[seg] > 0 {
+								if isValidBuildTarget(seg) {
 									target = seg
 									break
 								}
 							}
-							ret, err := treeShake(code, exports, targets[target])
+							ret, err := treeShake(code, exports, target)
 							if err != nil {
 								return rex.Status(500, err.Error())
 							}
@@ -1281,8 +1496,8 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 		}
 
 		// determine build target by `?target` query or `User-Agent` header
-		target := strings.ToLower(query.Get("target"))
-		targetFromUA := targets[target] == 0
+		target, ok := normalizeBuildTarget(query.Get("target"))
+		targetFromUA := !ok
 		if targetFromUA {
 			target = getBuildTargetByUA(ctx.UserAgent())
 		}
@@ -1322,6 +1537,46 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			return redirect(ctx, fmt.Sprintf("%s%s/%s@%s%s%s", origin, registryPrefix, pkgName, pkgVersion, subPath, qs), false)
 		}
 
+		// serve the original typescript sources of native jsr packages for the `denonext` target
+		if target == "denonext" && pathKind == EsmEntry && npmrc.isNativeJsr(esm.PkgName) {
+			b := &BuildContext{
+				npmrc:   npmrc,
+				logger:  logger,
+				esmPath: esm,
+			}
+			// the package is installed on the request path, charge it to the build budget of the client
+			if res := checkBuildRateLimit(ctx); res != nil {
+				return res
+			}
+			err = b.install()
+			if err != nil {
+				return rex.Status(500, err.Error())
+			}
+			entry := b.resolveEntry(esm)
+			if main := strings.TrimPrefix(entry.main, "./"); endsWith(main, ".ts", ".mts", ".tsx") {
+				if targetFromUA {
+					appendVaryHeader(ctx.W.Header(), "User-Agent")
+				}
+				if main != esm.SubPath {
+					url := fmt.Sprintf("%s/jsr/%s@%s/%s", origin, toJsrName(esm.PkgName), esm.PkgVersion, main)
+					if rawQuery != "" {
+						url += "?" + rawQuery
+					}
+					return redirect(ctx, url, isExactVersion)
+				}
+				code, err := os.ReadFile(path.Join(b.wd, "node_modules", esm.PkgName, main))
+				if err != nil {
+					if os.IsNotExist(err) {
+						return rex.Status(404, "File Not Found")
+					}
+					return rex.Status(500, err.Error())
+				}
+				ctx.SetHeader("Content-Type", ctTypeScript)
+				ctx.SetHeader("Cache-Control", ccImmutable)
+				return rewriteJsrSourceImports(code, origin, fmt.Sprintf("/jsr/%s@%s", toJsrName(esm.PkgName), esm.PkgVersion), b.pkgJson.Imports)
+			}
+		}
+
 		// check `?alias` query
 		alias := map[string]string{}
 		if query.Has("alias") {
@@ -1368,10 +1623,19 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			}
 		}
 
+		// check `?format` query, the classic script formats(iife/umd) bundle all dependencies
+		format := ""
+		if v := query.Get("format"); v != "" && v != "esm" {
+			if v != "iife" && v != "umd" {
+				return rex.Status(400, "Invalid format, available formats are `esm`, `iife` and `umd`")
+			}
+			format = v
+		}
+
 		// check `?external` query
 		external := set.New[string]()
-		externalAll := asteriskPrefix
-		if !asteriskPrefix && query.Has("external") {
+		externalAll := asteriskPrefix && format == ""
+		if !asteriskPrefix && format == "" && query.Has("external") {
 			for _, p := range strings.Split(query.Get("external"), ",") {
 				p = strings.TrimSpace(p)
 				if p == "*" {
@@ -1412,16 +1676,13 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 
 		// build and return the types(.d.ts) file
 		if pathKind == EsmDts {
+			args := ""
+			if a := encodeBuildArgs(buildArgs, true); a != "" {
+				args = "X-" + a
+			}
+			dtsPath := path.Join("/", esm.Name(), args, esm.SubPath)
+			savePath := normalizeSavePath(npmrc.zoneId, "types"+dtsPath)
 			readDts := func() (content io.ReadCloser, stat storage.Stat, err error) {
-				args := ""
-				if a := encodeBuildArgs(buildArgs, true); a != "" {
-					args = "X-" + a
-				}
-				savePath := normalizeSavePath(npmrc.zoneId, path.Join(fmt.Sprintf(
-					"types/%s/%s",
-					esm.Name(),
-					args,
-				), esm.SubPath))
 				content, stat, err = buildStorage.Get(savePath)
 				return
 			}
@@ -1440,6 +1701,9 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 					externalAll: externalAll,
 					target:      "types",
 				}
+				if res := checkBuildRateLimit(ctx); res != nil {
+					return res
+				}
 				ch := buildQueue.Add(buildCtx)
 				select {
 				case output := <-ch:
@@ -1466,6 +1730,55 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			if err != nil {
 				return rex.Status(500, err.Error())
 			}
+			// check `?dts-bundle` query, rolls up the internal declarations into a single file, the failures are
+			// cached to not retry the bundling for every request
+			bundleSavePath := savePath + ".bundle"
+			bundleFailedKey := "dts-bundle-failed:" + bundleSavePath
+			if query.Has("dts-bundle") && !cacheLRU.Contains(bundleFailedKey) {
+				r, _, err := buildStorage.Get(bundleSavePath)
+				if err == nil {
+					buffer, err = io.ReadAll(r)
+					r.Close()
+					if err != nil {
+						return rex.Status(500, err.Error())
+					}
+				} else if err == storage.ErrNotFound {
+					var storageErr error
+					bundled, err := bundleDTS(dtsPath, func(pathname string) ([]byte, error) {
+						if pathname == dtsPath {
+							return buffer, nil
+						}
+						r, _, err := buildStorage.Get(normalizeSavePath(npmrc.zoneId, "types"+pathname))
+						if err != nil {
+							if err != storage.ErrNotFound {
+								storageErr = err
+							}
+							return nil, err
+						}
+						defer r.Close()
+						data, err := io.ReadAll(r)
+						if err != nil {
+							storageErr = err
+						}
+						return data, err
+					})
+					if err != nil {
+						// fallback to the unbundled declaration file, the storage errors are not cached as they may be temporary
+						logger.Warnf("bundleDTS(%s): %v", dtsPath, err)
+						if storageErr == nil {
+							cacheLRU.Add(bundleFailedKey, true)
+						}
+					} else {
+						err = buildStorage.Put(bundleSavePath, bytes.NewReader(bundled))
+						if err != nil {
+							logger.Errorf("storage.put(%s): %v", bundleSavePath, err)
+						}
+						buffer = bundled
+					}
+				} else {
+					return rex.Status(500, err.Error())
+				}
+			}
 			ctx.SetHeader("Content-Type", ctTypeScript)
 			ctx.SetHeader("Cache-Control", ccImmutable)
 			return bytes.ReplaceAll(buffer, []byte("{ESM_CDN_ORIGIN}"), []byte(origin))
@@ -1480,6 +1793,36 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			buildArgs.ExternalRequire = externalRequire
 			buildArgs.KeepNames = query.Has("keep-names")
 			buildArgs.IgnoreAnnotations = query.Has("ignore-annotations")
+			// check `?assets` query, e.g. `?assets=external` or `?assets=4096` (inline the assets <= 4KB)
+			if v := query.Get("assets"); v != "" && v != "inline" {
+				if v != "external" {
+					limit, err := strconv.Atoi(v)
+					if err != nil || limit < 0 {
+						return rex.Status(400, "Invalid `assets` query")
+					}
+					buildArgs.AssetsInlineLimit = limit
+				}
+				buildArgs.ExternalAssets = true
+			}
+			// check `?define` query, e.g. `?define=__DEV__:false,process.env.API_URL:"/api"`
+			if v := query.Get("define"); v != "" {
+				define, err := parseDefine(v)
+				if err != nil {
+					return rex.Status(400, "Invalid `define` query: "+err.Error())
+				}
+				buildArgs.Define = define
+			}
+			if format != "" {
+				// check `?global` query, e.g. `?format=iife&global=MyLib`
+				globalName := query.Get("global")
+				if globalName != "" && !isGlobalName(globalName) {
+					return rex.Status(400, "Invalid `global` query")
+				}
+				if globalName == "" && format == "umd" {
+					return rex.Status(400, "Missing `global` query for the umd format")
+				}
+				buildArgs.GlobalName = globalName
+			}
 		}
 
 		bundleMode := BundleDefault
@@ -1488,6 +1831,9 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 		} else if query.Has("no-bundle") || query.Get("bundle") == "false" {
 			bundleMode = BundleFalse
 		}
+		if format != "" {
+			bundleMode = BundleDeps
+		}
 
 		dev := query.Has("dev")
 		// force react/jsx-dev-runtime and react-refresh into `dev` mode
@@ -1500,9 +1846,17 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			a := strings.Split(esm.SubModuleName, "/")
 			if len(a) > 0 {
 				maybeTarget := a[0]
-				if _, ok := targets[maybeTarget]; ok {
+				if isValidBuildTarget(maybeTarget) {
 					submodule := strings.Join(a[1:], "/")
-					if strings.HasSuffix(submodule, ".bundle") {
+					if s, ok := strings.CutSuffix(submodule, ".iife"); ok && strings.HasSuffix(esm.SubPath, ".js") {
+						submodule = s
+						format = "iife"
+						bundleMode = BundleDeps
+					} else if s, ok := strings.CutSuffix(submodule, ".umd"); ok && strings.HasSuffix(esm.SubPath, ".js") {
+						submodule = s
+						format = "umd"
+						bundleMode = BundleDeps
+					} else if strings.HasSuffix(submodule, ".bundle") {
 						submodule = strings.TrimSuffix(submodule, ".bundle")
 						bundleMode = BundleDeps
 					} else if strings.HasSuffix(submodule, ".nobundle") {
@@ -1544,6 +1898,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			esmPath:     esm,
 			args:        buildArgs,
 			bundleMode:  bundleMode,
+			format:      format,
 			externalAll: externalAll,
 			target:      target,
 			dev:         dev,
@@ -1552,7 +1907,27 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 		if err != nil {
 			return rex.Status(500, err.Error())
 		}
+
+		// send the module preload links as the 103 Early Hints before the slow work(waiting on the build or reading the
+		// storage), the entry module is preloaded before it is built, the imports are preloaded once the build meta exists
+		if ctx.R.Method == http.MethodGet && format == "" && !graphMode && (pathKind == EsmEntry || strings.HasSuffix(esm.SubPath, ".mjs")) && !query.Has("exports") && !query.Has("worker") && !query.Has("css") && !query.Has("analyze") && !query.Has("sbom") {
+			if !ok {
+				if pathKind == EsmEntry {
+					sendEarlyHints(ctx.W, ctx.R, []string{fmt.Sprintf("<%s%s>; rel=modulepreload", origin, build.Path())})
+				}
+			} else if ret.CSSEntry == "" && !ret.TypesOnly {
+				imports := ret.Imports
+				if pathKind == EsmEntry {
+					imports = append([]string{build.Path()}, imports...)
+				}
+				sendEarlyHints(ctx.W, ctx.R, build.getModulePreloadLinks(imports, origin))
+			}
+		}
+
 		if !ok {
+			if res := checkBuildRateLimit(ctx); res != nil {
+				return res
+			}
 			ch := buildQueue.Add(build)
 			select {
 			case output := <-ch:
@@ -1570,6 +1945,22 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			}
 		}
 
+		// return the dependency graph of the module for the `/graph/` route
+		if graphMode {
+			graph := walkModuleGraph(ctx, buildQueue, build, ret, origin)
+			if targetFromUA {
+				appendVaryHeader(ctx.W.Header(), "User-Agent")
+			}
+			ctx.SetHeader("Cache-Control", fmt.Sprintf("public, max-age=%d", config.NpmQueryCacheTTL))
+			for _, m := range graph.Modules {
+				if m.Error != "" {
+					ctx.SetHeader("Cache-Control", ccMustRevalidate)
+					break
+				}
+			}
+			return graph
+		}
+
 		if ret.CSSEntry != "" {
 			url := strings.Join([]string{origin, esm.Name(), ret.CSSEntry[2:]}, "/")
 			return redirect(ctx, url, isExactVersion)
@@ -1578,6 +1969,9 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 		// redirect to `*.d.ts` file
 		if ret.TypesOnly {
 			dtsUrl := origin + ret.Dts
+			if query.Has("dts-bundle") {
+				dtsUrl += "?dts-bundle"
+			}
 			ctx.SetHeader("X-TypeScript-Types", dtsUrl)
 			ctx.SetHeader("Content-Type", ctJavaScript)
 			ctx.SetHeader("Cache-Control", ccImmutable)
@@ -1587,6 +1981,98 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 			return []byte("export default null;\n")
 		}
 
+		// return the bundle analysis of the module from `?analyze`, or the treemap from `?analyze=html`,
+		// or the SBOM from `?sbom` (CycloneDX) and `?sbom=spdx`
+		if query.Has("analyze") || query.Has("sbom") {
+			isSBOM := !query.Has("analyze")
+			savePath := build.getAnalysisSavepath()
+			if isSBOM {
+				switch query.Get("sbom") {
+				case "", "cyclonedx", "spdx":
+				default:
+					return rex.Status(400, "Invalid sbom format, supported formats are \"cyclonedx\" and \"spdx\"")
+				}
+				savePath = build.getSBOMSavepath()
+			}
+			readAnalysis := func() ([]byte, error) {
+				f, _, err := buildStorage.Get(savePath)
+				if err != nil {
+					return nil, err
+				}
+				defer f.Close()
+				return io.ReadAll(f)
+			}
+			data, err := readAnalysis()
+			if err != nil {
+				if err != storage.ErrNotFound {
+					return rex.Status(500, err.Error())
+				}
+				analyzeCtx := &BuildContext{
+					npmrc:         npmrc,
+					logger:        logger,
+					db:            db,
+					storage:       buildStorage,
+					esmPath:       build.esmPath,
+					args:          buildArgs,
+					bundleMode:    bundleMode,
+					format:        format,
+					externalAll:   externalAll,
+					target:        target,
+					dev:           dev,
+					analyzeBundle: true,
+				}
+				if res := checkBuildRateLimit(ctx); res != nil {
+					return res
+				}
+				ch := buildQueue.Add(analyzeCtx)
+				select {
+				case output := <-ch:
+					if output.err != nil {
+						return rex.Status(500, "Failed to analyze the module: "+output.err.Error())
+					}
+				case <-time.After(time.Duration(config.BuildWaitTime) * time.Second):
+					ctx.SetHeader("Cache-Control", ccMustRevalidate)
+					return rex.Status(http.StatusRequestTimeout, "timeout, the module is waiting to be analyzed, please try refreshing the page.")
+				}
+				data, err = readAnalysis()
+				if err != nil {
+					return rex.Status(500, err.Error())
+				}
+			}
+			if targetFromUA {
+				appendVaryHeader(ctx.W.Header(), "User-Agent")
+			}
+			if isExactVersion {
+				ctx.SetHeader("Cache-Control", ccImmutable)
+			} else {
+				ctx.SetHeader("Cache-Control", fmt.Sprintf("public, max-age=%d", config.NpmQueryCacheTTL))
+			}
+			if isSBOM {
+				var sbom SBOM
+				err = json.Unmarshal(data, &sbom)
+				if err != nil {
+					return rex.Status(500, err.Error())
+				}
+				doc, err := renderSBOM(&sbom, query.Get("sbom"), origin)
+				if err != nil {
+					return rex.Status(500, err.Error())
+				}
+				ctx.SetHeader("Content-Type", ctJSON)
+				return doc
+			}
+			if query.Get("analyze") == "html" {
+				html, err := embedFS.ReadFile("embed/analyze.html")
+				if err != nil {
+					return rex.Status(500, err.Error())
+				}
+				ctx.SetHeader("Content-Type", ctHTML)
+				// escape `</script>` in the json
+				return bytes.Replace(html, []byte("{ANALYSIS}"), bytes.ReplaceAll(data, []byte("</"), []byte("<\\/")), 1)
+			}
+			ctx.SetHeader("Content-Type", ctJSON)
+			return data
+		}
+
 		// redirect to package css from `?css`
 		if query.Has("css") && esm.SubModuleName == "" {
 			if !ret.CSSInJS {
@@ -1609,9 +2095,31 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 		exports := jsIdentSet.Values()
 		sort.Strings(exports)
 
+		// return the classic script(iife/umd) directly
+		if format != "" && pathKind != EsmBuild {
+			f, fi, err := buildStorage.Get(build.getSavepath())
+			if err != nil {
+				return rex.Status(500, err.Error())
+			}
+			if targetFromUA {
+				appendVaryHeader(ctx.W.Header(), "User-Agent")
+			}
+			if isExactVersion {
+				ctx.SetHeader("Cache-Control", ccImmutable)
+			} else {
+				ctx.SetHeader("Cache-Control", fmt.Sprintf("public, max-age=%d", config.NpmQueryCacheTTL))
+			}
+			ctx.SetHeader("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+			ctx.SetHeader("Content-Type", ctJavaScript)
+			ctx.SetHeader("X-ESM-Path", build.Path())
+			ctx.SetHeader("Access-Control-Expose-Headers", "X-ESM-Path")
+			setSignatureHeader(ctx, ret.Signature)
+			return f // auto closed
+		}
+
 		// if the path is `ESMBuild`, return the built js/css content
 		if pathKind == EsmBuild {
-			if esm.SubPath != build.esmPath.SubPath {
+			if esm.SubPath != build.esmPath.SubPath && format == "" {
 				buf, recycle := newBuffer()
 				defer recycle()
 				fmt.Fprintf(buf, "export * from \"%s\";\n", build.Path())
@@ -1660,6 +2168,9 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 						moduleUrl,
 					)
 				}
+				if len(exports) == 0 {
+					setModulePreloadHeaders(ctx.W, build.getModulePreloadLinks(ret.Imports, origin))
+				}
 				if !ret.CJS && len(exports) > 0 {
 					defer f.Close()
 					xxh := xxhash.New()
@@ -1676,7 +2187,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 					if err != nil {
 						return rex.Status(500, err.Error())
 					}
-					ret, err := treeShake(code, exports, targets[target])
+					ret, err := treeShake(code, exports, target)
 					if err != nil {
 						return rex.Status(500, err.Error())
 					}
@@ -1685,6 +2196,11 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 					return ret
 				}
 			}
+			if endsWith(savePath, ".css") {
+				setSignatureHeader(ctx, ret.CSSSignature)
+			} else if !endsWith(savePath, ".map") {
+				setSignatureHeader(ctx, ret.Signature)
+			}
 			return f // auto closed
 		}
 
@@ -1713,6 +2229,7 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				esm += "?exports=" + strings.Join(exports, ",")
 			}
 			ctx.SetHeader("X-ESM-Path", esm)
+			setModulePreloadHeaders(ctx.W, build.getModulePreloadLinks(append([]string{esm}, ret.Imports...), origin))
 			fmt.Fprintf(buf, "export * from \"%s\";\n", esm)
 			if ret.ExportDefault && (len(exports) == 0 || slices.Contains(exports, "default")) {
 				fmt.Fprintf(buf, "export { default } from \"%s\";\n", esm)
@@ -1722,7 +2239,11 @@ func esmRouter(db Database, buildStorage storage.Storage, logger *log.Logger) re
 				fmt.Fprintf(buf, "export const { %s } = _;\n", strings.Join(exports, ", "))
 			}
 			if noDts := query.Has("no-dts") || query.Has("no-check"); !noDts && ret.Dts != "" {
-				ctx.SetHeader("X-TypeScript-Types", origin+ret.Dts)
+				dtsUrl := origin + ret.Dts
+				if query.Has("dts-bundle") {
+					dtsUrl += "?dts-bundle"
+				}
+				ctx.SetHeader("X-TypeScript-Types", dtsUrl)
 				ctx.SetHeader("Access-Control-Expose-Headers", "X-ESM-Path, X-TypeScript-Types")
 			} else {
 				ctx.SetHeader("Access-Control-Expose-Headers", "X-ESM-Path")
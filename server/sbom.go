@@ -0,0 +1,384 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/esm-dev/esm.sh/internal/npm"
+	"github.com/goccy/go-json"
+	"github.com/ije/gox/utils"
+)
+
+// SBOM is the software bill of materials of a built module, see the `?sbom` query. It's stored in the storage and
+// rendered as the CycloneDX or SPDX document on request.
+type SBOM struct {
+	Path      string          `json:"path"`
+	Root      SBOMComponent   `json:"root"`
+	Packages  []SBOMComponent `json:"packages"`
+	Imports   []string        `json:"imports"`
+	CreatedAt int64           `json:"createdAt"`
+}
+
+// SBOMComponent is a package included in the build output.
+type SBOMComponent struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	PURL      string `json:"purl,omitempty"`
+	License   string `json:"license,omitempty"`
+	Integrity string `json:"integrity,omitempty"`
+}
+
+// sbomPackageJSON is the partial of the package.json for the SBOM.
+type sbomPackageJSON struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	License   any    `json:"license"`
+	Licenses  any    `json:"licenses"`
+	Integrity string `json:"_integrity"`
+}
+
+// getSBOMSavepath returns the storage path of the SBOM, next to the build.
+func (ctx *BuildContext) getSBOMSavepath() string {
+	return ctx.getSavepath() + ".sbom.json"
+}
+
+// saveSBOM collects the packages of the metafile inputs and saves the SBOM to the storage. The integrity hashes are
+// recorded by the `installPackage` function, no registry requests are made here.
+func (ctx *BuildContext) saveSBOM(metafile *esbuildMetafile, imports []string) (err error) {
+	sbom := SBOM{
+		Path: ctx.Path(),
+		Root: SBOMComponent{
+			Name:      ctx.esmPath.PkgName,
+			Version:   ctx.esmPath.PkgVersion,
+			PURL:      ctx.getRootPackageURL(),
+			Integrity: ctx.npmrc.getPackageIntegrity(ctx.esmPath.Package()),
+		},
+		Packages:  []SBOMComponent{},
+		Imports:   append([]string{}, imports...),
+		CreatedAt: time.Now().Unix(),
+	}
+	rootDir := filepath.Join(ctx.wd, "node_modules", ctx.esmPath.PkgName)
+	var rootPkgJson sbomPackageJSON
+	if utils.ParseJSONFile(filepath.Join(rootDir, "package.json"), &rootPkgJson) == nil {
+		sbom.Root.License = getPackageLicense(&rootPkgJson)
+	}
+	seen := map[string]bool{rootDir: true}
+	for name := range metafile.Inputs {
+		pkgDir, ok := getInputPackageDir(name)
+		if !ok {
+			continue
+		}
+		// the inputs are relative to the working directory of the build
+		if !filepath.IsAbs(pkgDir) {
+			pkgDir = filepath.Join(ctx.wd, pkgDir)
+		}
+		if seen[pkgDir] {
+			continue
+		}
+		seen[pkgDir] = true
+		var pkgJson sbomPackageJSON
+		if utils.ParseJSONFile(filepath.Join(pkgDir, "package.json"), &pkgJson) != nil || pkgJson.Name == "" {
+			continue
+		}
+		component := SBOMComponent{
+			Name:      pkgJson.Name,
+			Version:   pkgJson.Version,
+			License:   getPackageLicense(&pkgJson),
+			Integrity: pkgJson.Integrity,
+		}
+		if npm.IsExactVersion(component.Version) && !ctx.npmrc.isNativeJsr(component.Name) {
+			component.PURL = getPackageURL(component.Name, component.Version)
+			if component.Integrity == "" {
+				component.Integrity = ctx.npmrc.getPackageIntegrity(npm.Package{Name: component.Name, Version: component.Version})
+			}
+		}
+		sbom.Packages = append(sbom.Packages, component)
+	}
+	// the same package may be installed in different directories
+	sort.Slice(sbom.Packages, func(i, j int) bool {
+		a, b := sbom.Packages[i], sbom.Packages[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	sbom.Packages = dedupeSBOMComponents(sbom.Packages)
+
+	data, err := json.Marshal(sbom)
+	if err != nil {
+		return
+	}
+	err = ctx.storage.Put(ctx.getSBOMSavepath(), bytes.NewReader(data))
+	if err != nil {
+		ctx.logger.Errorf("storage.put(%s): %v", ctx.getSBOMSavepath(), err)
+		err = errors.New("storage: " + err.Error())
+	}
+	return
+}
+
+// getRootPackageURL returns the package url of the built package, it's empty for the packages that have no
+// package url type, e.g. the tarball, pkg.pr.new and JSR packages.
+func (ctx *BuildContext) getRootPackageURL() string {
+	esm := ctx.esmPath
+	switch {
+	case esm.GhPrefix:
+		return "pkg:github/" + strings.ToLower(esm.PkgName) + "@" + url.PathEscape(esm.PkgVersion)
+	case esm.GitHost == "bitbucket.org":
+		return "pkg:bitbucket/" + strings.ToLower(esm.PkgName) + "@" + url.PathEscape(esm.PkgVersion)
+	case esm.GitHost != "" || esm.PrPrefix || esm.TgzPrefix || ctx.npmrc.isNativeJsr(esm.PkgName):
+		return ""
+	default:
+		return getPackageURL(esm.PkgName, esm.PkgVersion)
+	}
+}
+
+// getInputPackageDir returns the package directory of the metafile input, e.g.
+// "node_modules/foo/node_modules/@scope/bar/index.js" -> "node_modules/foo/node_modules/@scope/bar"
+func getInputPackageDir(input string) (string, bool) {
+	// strip the namespace of the plugins, e.g. "esm-fs:/path/to/file.js"
+	if i := strings.IndexByte(input, ':'); i > 0 && !strings.ContainsRune(input[:i], '/') {
+		input = input[i+1:]
+	}
+	i := strings.LastIndex(input, "node_modules/")
+	if i < 0 {
+		return "", false
+	}
+	segments := strings.SplitN(input[i+len("node_modules/"):], "/", 3)
+	n := 1
+	if strings.HasPrefix(segments[0], "@") {
+		n = 2
+	}
+	if len(segments) <= n {
+		return "", false
+	}
+	return input[:i] + "node_modules/" + strings.Join(segments[:n], "/"), true
+}
+
+// getPackageLicense returns the license expression of the package.json, the legacy `licenses` array is joined with "OR".
+func getPackageLicense(pkgJson *sbomPackageJSON) string {
+	getType := func(v any) string {
+		switch v := v.(type) {
+		case string:
+			return v
+		case map[string]any:
+			if t, ok := v["type"].(string); ok {
+				return t
+			}
+		}
+		return ""
+	}
+	if license := getType(pkgJson.License); license != "" {
+		return license
+	}
+	if licenses, ok := pkgJson.Licenses.([]any); ok {
+		var types []string
+		for _, v := range licenses {
+			if t := getType(v); t != "" {
+				types = append(types, t)
+			}
+		}
+		if len(types) == 1 {
+			return types[0]
+		}
+		if len(types) > 1 {
+			return "(" + strings.Join(types, " OR ") + ")"
+		}
+	}
+	return ""
+}
+
+func dedupeSBOMComponents(components []SBOMComponent) []SBOMComponent {
+	ret := components[:0]
+	for i, c := range components {
+		if i > 0 && c.Name == components[i-1].Name && c.Version == components[i-1].Version {
+			continue
+		}
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+// getPackageIntegrity returns the integrity hash of the npm package, which is saved to the `integrity.txt` file by the
+// `installPackage` function. It's empty for other packages, or the packages that were installed before.
+func (npmrc *NpmRC) getPackageIntegrity(pkg npm.Package) string {
+	if pkg.Github || pkg.PkgPrNew || pkg.GitHost != "" || pkg.Tgz || !npm.IsExactVersion(pkg.Version) {
+		return ""
+	}
+	data, err := os.ReadFile(path.Join(npmrc.StoreDir(), pkg.String(), "integrity.txt"))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// renderSBOM renders the SBOM as a CycloneDX(default) or SPDX JSON document.
+func renderSBOM(sbom *SBOM, format string, origin string) (any, error) {
+	createdAt := time.Unix(sbom.CreatedAt, 0).UTC().Format(time.RFC3339)
+	switch format {
+	case "", "cyclonedx":
+		components := make([]map[string]any, 0, len(sbom.Packages))
+		dependsOn := make([]string, 0, len(sbom.Packages))
+		for _, c := range sbom.Packages {
+			components = append(components, cyclonedxComponent(c))
+			dependsOn = append(dependsOn, cyclonedxRef(c))
+		}
+		h := sha256.Sum256([]byte(origin + sbom.Path))
+		return map[string]any{
+			"bomFormat":    "CycloneDX",
+			"specVersion":  "1.5",
+			"serialNumber": "urn:uuid:" + formatUUID(h[:16]),
+			"version":      1,
+			"metadata": map[string]any{
+				"timestamp": createdAt,
+				"tools": map[string]any{
+					"components": []map[string]any{{"type": "application", "name": "esm.sh", "version": VERSION}},
+				},
+				"component": cyclonedxComponent(sbom.Root),
+				"properties": []map[string]any{
+					{"name": "esm.sh:path", "value": sbom.Path},
+					{"name": "esm.sh:imports", "value": strings.Join(sbom.Imports, ",")},
+				},
+			},
+			"components": components,
+			"dependencies": []map[string]any{
+				{"ref": cyclonedxRef(sbom.Root), "dependsOn": dependsOn},
+			},
+		}, nil
+	case "spdx":
+		rootId := spdxId(sbom.Root)
+		packages := []map[string]any{spdxPackage(sbom.Root)}
+		relationships := []map[string]any{
+			{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": rootId},
+		}
+		for _, c := range sbom.Packages {
+			packages = append(packages, spdxPackage(c))
+			relationships = append(relationships, map[string]any{
+				"spdxElementId":      rootId,
+				"relationshipType":   "DEPENDS_ON",
+				"relatedSpdxElement": spdxId(c),
+			})
+		}
+		return map[string]any{
+			"spdxVersion":       "SPDX-2.3",
+			"dataLicense":       "CC0-1.0",
+			"SPDXID":            "SPDXRef-DOCUMENT",
+			"name":              sbom.Path,
+			"documentNamespace": origin + sbom.Path + "?sbom=spdx",
+			"creationInfo": map[string]any{
+				"created":  createdAt,
+				"creators": []string{"Tool: esm.sh-" + VERSION},
+			},
+			"packages":      packages,
+			"relationships": relationships,
+		}, nil
+	default:
+		return nil, errors.New("unsupported sbom format: " + format)
+	}
+}
+
+func cyclonedxComponent(c SBOMComponent) map[string]any {
+	component := map[string]any{
+		"type":    "library",
+		"bom-ref": cyclonedxRef(c),
+		"name":    c.Name,
+		"version": c.Version,
+	}
+	if c.PURL != "" {
+		component["purl"] = c.PURL
+	}
+	if c.License != "" {
+		if strings.ContainsAny(c.License, " ()") {
+			component["licenses"] = []map[string]any{{"expression": c.License}}
+		} else {
+			component["licenses"] = []map[string]any{{"license": map[string]any{"id": c.License}}}
+		}
+	}
+	if alg, hash, ok := decodeIntegrity(c.Integrity); ok {
+		component["hashes"] = []map[string]any{{"alg": strings.ToUpper(alg[:3]) + "-" + alg[3:], "content": hash}}
+	}
+	return component
+}
+
+func spdxPackage(c SBOMComponent) map[string]any {
+	license := c.License
+	if license == "" {
+		license = "NOASSERTION"
+	}
+	pkg := map[string]any{
+		"SPDXID":           spdxId(c),
+		"name":             c.Name,
+		"versionInfo":      c.Version,
+		"downloadLocation": "NOASSERTION",
+		"filesAnalyzed":    false,
+		"licenseConcluded": "NOASSERTION",
+		"licenseDeclared":  license,
+		"copyrightText":    "NOASSERTION",
+	}
+	if c.PURL != "" {
+		pkg["externalRefs"] = []map[string]any{
+			{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": c.PURL},
+		}
+	}
+	if alg, hash, ok := decodeIntegrity(c.Integrity); ok {
+		pkg["checksums"] = []map[string]any{{"algorithm": strings.ToUpper(alg), "checksumValue": hash}}
+	}
+	return pkg
+}
+
+// getPackageURL returns the package url of the npm package, e.g. "pkg:npm/%40scope/name@1.0.0"
+func getPackageURL(pkgName string, pkgVersion string) string {
+	return "pkg:npm/" + strings.Replace(pkgName, "@", "%40", 1) + "@" + url.PathEscape(pkgVersion)
+}
+
+// cyclonedxRef returns the `bom-ref` of the component, which is the package url if present.
+func cyclonedxRef(c SBOMComponent) string {
+	if c.PURL != "" {
+		return c.PURL
+	}
+	return c.Name + "@" + c.Version
+}
+
+// spdxId returns the SPDX identifier of the component, which only allows letters, numbers, "." and "-".
+func spdxId(c SBOMComponent) string {
+	var sb strings.Builder
+	sb.WriteString("SPDXRef-Package-npm-")
+	for _, r := range c.Name + "-" + c.Version {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('-')
+		}
+	}
+	return sb.String()
+}
+
+// decodeIntegrity decodes the subresource integrity to the algorithm and the hex encoded hash, e.g.
+// "sha512-<base64>" -> ("sha512", "<hex>")
+func decodeIntegrity(integrity string) (alg string, hash string, ok bool) {
+	alg, b64, ok := strings.Cut(integrity, "-")
+	if !ok || (alg != "sha1" && alg != "sha256" && alg != "sha384" && alg != "sha512") {
+		return "", "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", "", false
+	}
+	return alg, hex.EncodeToString(data), true
+}
+
+func formatUUID(b []byte) string {
+	b[6] = (b[6] & 0x0f) | 0x50 // version 5
+	b[8] = (b[8] & 0x3f) | 0x80 // variant
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestGetInputPackageDir(t *testing.T) {
+	for input, expected := range map[string]string{
+		"node_modules/react-dom/cjs/react-dom.production.min.js":      "node_modules/react-dom",
+		"node_modules/foo/node_modules/@scope/bar/index.js":           "node_modules/foo/node_modules/@scope/bar",
+		"../scheduler@0.23.2/node_modules/scheduler/index.js":         "../scheduler@0.23.2/node_modules/scheduler",
+		"esm-fs:/store/foo@1.0.0/node_modules/@scope/foo/lib/main.js": "/store/foo@1.0.0/node_modules/@scope/foo",
+	} {
+		dir, ok := getInputPackageDir(input)
+		if !ok || dir != expected {
+			t.Fatalf("getInputPackageDir(%q): expected %q, got %q", input, expected, dir)
+		}
+	}
+	for _, input := range []string{"<stdin>", "src/index.js", "node_modules/@scope/index.js"} {
+		if _, ok := getInputPackageDir(input); ok {
+			t.Fatalf("getInputPackageDir(%q): expected no package", input)
+		}
+	}
+}
+
+func TestGetPackageLicense(t *testing.T) {
+	for expected, pkgJson := range map[string]sbomPackageJSON{
+		"MIT":                   {License: "MIT"},
+		"ISC":                   {License: map[string]any{"type": "ISC"}},
+		"(MIT OR Apache-2.0)":   {Licenses: []any{map[string]any{"type": "MIT"}, map[string]any{"type": "Apache-2.0"}}},
+		"BSD-3-Clause":          {Licenses: []any{map[string]any{"type": "BSD-3-Clause"}}},
+		"":                      {},
+		"(MIT AND Zlib)":        {License: "(MIT AND Zlib)"},
+		"SEE LICENSE IN README": {License: "SEE LICENSE IN README"},
+	} {
+		if license := getPackageLicense(&pkgJson); license != expected {
+			t.Fatalf("expected %q, got %q", expected, license)
+		}
+	}
+}
+
+func TestGetRootPackageURL(t *testing.T) {
+	for expected, esm := range map[string]EsmPath{
+		"pkg:npm/%40scope/foo@1.0.0":       {PkgName: "@scope/foo", PkgVersion: "1.0.0"},
+		"pkg:github/owner/repo@abcdef0":    {GhPrefix: true, PkgName: "Owner/Repo", PkgVersion: "abcdef0"},
+		"pkg:bitbucket/owner/repo@abcdef0": {GitHost: "bitbucket.org", PkgName: "owner/repo", PkgVersion: "abcdef0"},
+		"":                                 {TgzPrefix: true, PkgName: "foo", PkgVersion: "1a2b3c4d5e6f7a8b"},
+	} {
+		ctx := &BuildContext{npmrc: &NpmRC{ScopedRegistries: map[string]NpmRegistry{}}, esmPath: esm}
+		if purl := ctx.getRootPackageURL(); purl != expected {
+			t.Fatalf("expected %q, got %q", expected, purl)
+		}
+	}
+	ctx := &BuildContext{npmrc: &NpmRC{ScopedRegistries: map[string]NpmRegistry{}}, esmPath: EsmPath{PkgName: "@jsr/std__path", PkgVersion: "1.0.0"}}
+	if purl := ctx.getRootPackageURL(); purl != "" {
+		t.Fatalf("unexpected purl of the JSR package: %q", purl)
+	}
+}
+
+func TestRenderSBOM(t *testing.T) {
+	sbom := &SBOM{
+		Path:     "/foo@1.0.0/es2022/foo.bundle.mjs",
+		Root:     SBOMComponent{Name: "foo", Version: "1.0.0", PURL: getPackageURL("foo", "1.0.0"), License: "MIT", Integrity: "sha512-AAEC"},
+		Packages: []SBOMComponent{{Name: "@scope/bar", Version: "2.0.0", PURL: getPackageURL("@scope/bar", "2.0.0"), License: "(MIT OR Apache-2.0)"}},
+	}
+
+	doc, err := renderSBOM(sbom, "", "https://esm.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := doc.(map[string]any)
+	root := bom["metadata"].(map[string]any)["component"].(map[string]any)
+	if root["purl"] != "pkg:npm/foo@1.0.0" {
+		t.Fatalf("unexpected purl: %v", root["purl"])
+	}
+	if hash := root["hashes"].([]map[string]any)[0]; hash["alg"] != "SHA-512" || hash["content"] != "000102" {
+		t.Fatalf("unexpected hash: %v", hash)
+	}
+	bar := bom["components"].([]map[string]any)[0]
+	if bar["purl"] != "pkg:npm/%40scope/bar@2.0.0" {
+		t.Fatalf("unexpected purl: %v", bar["purl"])
+	}
+	if licenses := bar["licenses"].([]map[string]any); licenses[0]["expression"] != "(MIT OR Apache-2.0)" {
+		t.Fatalf("unexpected licenses: %v", licenses)
+	}
+
+	doc, err = renderSBOM(sbom, "spdx", "https://esm.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages := doc.(map[string]any)["packages"].([]map[string]any)
+	if len(packages) != 2 || packages[1]["SPDXID"] != "SPDXRef-Package-npm--scope-bar-2.0.0" {
+		t.Fatalf("unexpected packages: %v", packages)
+	}
+	if packages[1]["licenseDeclared"] != "(MIT OR Apache-2.0)" || packages[0]["checksums"] == nil {
+		t.Fatalf("unexpected packages: %v", packages)
+	}
+
+	// the tarball packages have no package url
+	sbom.Root = SBOMComponent{Name: "foo", Version: "1a2b3c4d5e6f7a8b"}
+	doc, _ = renderSBOM(sbom, "", "https://esm.sh")
+	root = doc.(map[string]any)["metadata"].(map[string]any)["component"].(map[string]any)
+	if _, ok := root["purl"]; ok || root["bom-ref"] != "foo@1a2b3c4d5e6f7a8b" {
+		t.Fatalf("unexpected root component: %v", root)
+	}
+	doc, _ = renderSBOM(sbom, "spdx", "https://esm.sh")
+	if _, ok := doc.(map[string]any)["packages"].([]map[string]any)[0]["externalRefs"]; ok {
+		t.Fatal("unexpected externalRefs of the tarball package")
+	}
+
+	if _, err = renderSBOM(sbom, "foo", "https://esm.sh"); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/esm-dev/esm.sh/internal/fetch"
+	"github.com/esm-dev/esm.sh/internal/signature"
 	"github.com/esm-dev/esm.sh/internal/storage"
 	"github.com/ije/gox/log"
 	"github.com/ije/gox/set"
@@ -57,6 +58,14 @@ func Serve() {
 	}
 	accessLogger.SetQuite(true)
 
+	// initialize the signer of the build artifacts
+	if len(config.SigningKeys) > 0 {
+		buildSigner, err = signature.NewSigner(config.SigningKeys)
+		if err != nil {
+			logger.Fatalf("init signer: %v", err)
+		}
+	}
+
 	// open database
 	db, err := OpenBoltDB(path.Join(config.WorkDir, "esm.db"))
 	if err != nil {
@@ -79,6 +88,8 @@ func Serve() {
 		cors(config.CorsAllowOrigins),
 		rex.Logger(logger),
 		rex.Optional(rex.AccessLogger(accessLogger), config.AccessLog),
+		rex.Optional(apiKeyAuth(db, &config.ApiKeys), config.ApiKeys.Enabled()),
+		rex.Optional(rateLimit(&config.RateLimit), config.RateLimit.Enabled()),
 		rex.Optional(rex.Compress(), config.Compress),
 		rex.Optional(customLandingPage(&config.CustomLandingPage), config.CustomLandingPage.Origin != ""),
 		rex.Optional(esmLegacyRouter(buildStorage), config.LegacyServer != ""),
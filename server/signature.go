@@ -0,0 +1,38 @@
+package server
+
+import (
+	"bytes"
+
+	"github.com/esm-dev/esm.sh/internal/signature"
+	"github.com/ije/rex"
+)
+
+// the well-known path of the public keys to verify the build artifacts
+const signingKeysPath = "/.well-known/esm-signing-keys.json"
+
+// buildSigner signs the build artifacts, it's nil if the `signingKeys` config is not set.
+var buildSigner *signature.Signer
+
+// putArtifact stores the build artifact of the pathname, and returns its signature if the signer is enabled.
+// The signature is saved in the build meta, so the responses don't need to read it from the storage.
+func (ctx *BuildContext) putArtifact(savePath string, pathname string, data []byte) (sig string, err error) {
+	err = ctx.storage.Put(savePath, bytes.NewReader(data))
+	if err != nil || buildSigner == nil {
+		return
+	}
+	return buildSigner.Sign(pathname, data).String(), nil
+}
+
+// setSignatureHeader sets the `X-ESM-Signature` header with the signature of the artifact from the build meta.
+func setSignatureHeader(ctx *rex.Context, sig string) {
+	if buildSigner == nil || sig == "" {
+		return
+	}
+	h := ctx.W.Header()
+	h.Set("X-ESM-Signature", sig)
+	if v := h.Get("Access-Control-Expose-Headers"); v != "" {
+		h.Set("Access-Control-Expose-Headers", v+", X-ESM-Signature")
+	} else {
+		h.Set("Access-Control-Expose-Headers", "X-ESM-Signature")
+	}
+}
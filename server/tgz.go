@@ -0,0 +1,226 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/esm-dev/esm.sh/internal/npm"
+	"github.com/goccy/go-json"
+	"github.com/ije/gox/utils"
+	"github.com/ije/gox/valid"
+)
+
+// the length of the content-hash version of tarball packages
+const tgzHashLength = 16
+
+// parseTgzPath parses the `/tgz/` pathname, e.g.
+// - /tgz/https://example.com/pkg-1.0.0.tgz/sub/path (downloads the tarball and resolves the content-hash version)
+// - /tgz/pkg@1a2b3c4d5e6f7a8b/sub/path
+func parseTgzPath(npmrc *NpmRC, pathname string) (esm EsmPath, extraQuery string, exactVersion bool, hasTargetSegment bool, err error) {
+	if isHttpSepcifier(pathname) {
+		tarballUrl, subPath, ok := splitTgzUrl(pathname)
+		if !ok {
+			err = errors.New("invalid tarball url")
+			return
+		}
+		var u *url.URL
+		u, err = url.Parse(tarballUrl)
+		if err != nil {
+			err = errors.New("invalid tarball url")
+			return
+		}
+		if !isTgzHostAllowed(u.Hostname()) {
+			err = fmt.Errorf("invalid tarball host '%s'", u.Hostname())
+			return
+		}
+		var pkg npm.Package
+		pkg, err = npmrc.installTgzPackage(tarballUrl)
+		if err != nil {
+			return
+		}
+		hasTargetSegment = validateTargetSegment(strings.Split(subPath, "/"))
+		esm = EsmPath{
+			TgzPrefix:     true,
+			PkgName:       pkg.Name,
+			PkgVersion:    pkg.Version,
+			SubPath:       subPath,
+			SubModuleName: stripEntryModuleExt(subPath),
+		}
+		return
+	}
+
+	pkgName, maybeVersion, subPath, hasTargetSegment := splitEsmPath("/" + pathname)
+	if !npm.ValidatePackageName(pkgName) {
+		err = fmt.Errorf("invalid package name '%s'", pkgName)
+		return
+	}
+	version, extraQuery := utils.SplitByFirstByte(maybeVersion, '&')
+	if len(version) != tgzHashLength || !valid.IsHexString(version) {
+		err = errors.New("invalid tarball version")
+		return
+	}
+	esm = EsmPath{
+		TgzPrefix:     true,
+		PkgName:       pkgName,
+		PkgVersion:    version,
+		SubPath:       subPath,
+		SubModuleName: stripEntryModuleExt(subPath),
+	}
+	exactVersion = true
+	return
+}
+
+// splitTgzUrl splits the tarball url and the sub path, e.g.
+// "https://example.com/pkg-1.0.0.tgz/sub/path" -> "https://example.com/pkg-1.0.0.tgz", "sub/path"
+func splitTgzUrl(s string) (tarballUrl string, subPath string, ok bool) {
+	segments := strings.Split(s, "/")
+	// skip the "https:", "" and host segments
+	for i := 3; i < len(segments); i++ {
+		if endsWith(segments[i], ".tgz", ".tar.gz") {
+			return strings.Join(segments[:i+1], "/"), strings.Join(segments[i+1:], "/"), true
+		}
+	}
+	return
+}
+
+// isTgzHostAllowed checks if the tarball host is allowed by the `tgzAllowHosts` and `tgzDenyHosts` config.
+// The localhost and IP addresses are not allowed unless they are listed in the `tgzAllowHosts` explicitly,
+// or the server is running in debug mode. Note the server never connects to the non-public addresses in
+// production, see `newGuardedFetchClient`.
+func isTgzHostAllowed(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	if hostname == "" {
+		return false
+	}
+	for _, host := range config.TgzDenyHosts {
+		if matchHost(host, hostname) {
+			return false
+		}
+	}
+	for _, host := range config.TgzAllowHosts {
+		if matchHost(host, hostname) {
+			return true
+		}
+	}
+	if len(config.TgzAllowHosts) > 0 {
+		return false
+	}
+	return DEBUG || (!isLocalhost(hostname) && valid.IsDomain(hostname))
+}
+
+// matchHost checks if the hostname matches the host pattern, e.g. "*.example.com" matches "cdn.example.com".
+func matchHost(pattern string, hostname string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(hostname, pattern[1:])
+	}
+	return pattern == hostname
+}
+
+// installTgzPackage downloads the tarball of the given url and installs it under the content-hash version,
+// e.g. "https://example.com/pkg-1.0.0.tgz" -> "tgz/pkg@1a2b3c4d5e6f7a8b".
+func (npmrc *NpmRC) installTgzPackage(tarballUrl string) (npm.Package, error) {
+	return withCache("tgz:"+npmrc.StoreDir()+":"+tarballUrl, time.Duration(config.NpmQueryCacheTTL)*time.Second, func() (pkg npm.Package, _ string, err error) {
+		tmpFile, err := os.CreateTemp("", "esm-tgz-*")
+		if err != nil {
+			return
+		}
+		defer os.Remove(tmpFile.Name())
+		defer tmpFile.Close()
+
+		// guard against SSRF, the host may resolve to (or redirect to) an internal address
+		fetchClient, recycle := newGuardedFetchClient("esmd/"+VERSION, 30, func(u *url.URL) bool {
+			return isTgzHostAllowed(u.Hostname())
+		})
+		defer recycle()
+
+		h := sha256.New()
+		err = downloadPackageTarball(fetchClient, &NpmRegistry{}, tarballUrl, path.Base(tarballUrl), func(tarball io.Reader) error {
+			_, err := io.Copy(io.MultiWriter(tmpFile, h), tarball)
+			return err
+		})
+		if err != nil {
+			return
+		}
+
+		_, err = tmpFile.Seek(0, io.SeekStart)
+		if err != nil {
+			return
+		}
+		pkgName, err := readTarballPackageName(tmpFile)
+		if err != nil {
+			return
+		}
+
+		pkg = npm.Package{
+			Tgz:     true,
+			Name:    pkgName,
+			Version: hex.EncodeToString(h.Sum(nil))[:tgzHashLength],
+		}
+		installDir := path.Join(npmrc.StoreDir(), pkg.String())
+		packageJsonPath := path.Join(installDir, "node_modules", pkg.Name, "package.json")
+		if existsFile(packageJsonPath) {
+			return
+		}
+
+		unlock := installMutex.Lock(pkg.String())
+		defer unlock()
+
+		// skip installation if the package has been installed by another request
+		if existsFile(packageJsonPath) {
+			return
+		}
+
+		_, err = tmpFile.Seek(0, io.SeekStart)
+		if err != nil {
+			return
+		}
+		err = extractPackageTarball(installDir, pkg.Name, tmpFile)
+		if err != nil {
+			// clear installDir if failed to extract tarball
+			os.RemoveAll(installDir)
+		}
+		return
+	})
+}
+
+// readTarballPackageName reads the package name from the `package.json` in the tarball.
+func readTarballPackageName(tarball io.Reader) (pkgName string, err error) {
+	unziped, err := gzip.NewReader(tarball)
+	if err != nil {
+		return
+	}
+	tr := tar.NewReader(unziped)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		// strip tarball root dir
+		_, name := utils.SplitByFirstByte(h.Name, '/')
+		if name == "package.json" && h.Typeflag == tar.TypeReg {
+			var raw npm.PackageJSONRaw
+			err = json.NewDecoder(io.LimitReader(tr, maxAssetFileSize)).Decode(&raw)
+			if err != nil {
+				return "", fmt.Errorf("invalid package.json in the tarball: %v", err)
+			}
+			if !npm.ValidatePackageName(raw.Name) {
+				return "", fmt.Errorf("invalid package name '%s' in the tarball", raw.Name)
+			}
+			return raw.Name, nil
+		}
+	}
+	return "", errors.New("invalid tarball: missing package.json")
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ije/gox/crypto/rand"
+)
+
+func TestTgzPackage(t *testing.T) {
+	for _, c := range [][3]string{
+		{"https://example.com/pkg-1.0.0.tgz", "https://example.com/pkg-1.0.0.tgz", ""},
+		{"https://example.com/dist/pkg.tar.gz/es2022/pkg.mjs", "https://example.com/dist/pkg.tar.gz", "es2022/pkg.mjs"},
+	} {
+		tarballUrl, subPath, ok := splitTgzUrl(c[0])
+		if !ok || tarballUrl != c[1] || subPath != c[2] {
+			t.Fatalf("splitTgzUrl(%s): unexpected result: %s %s", c[0], tarballUrl, subPath)
+		}
+	}
+	if _, _, ok := splitTgzUrl("https://example.com/pkg.zip"); ok {
+		t.Fatal("splitTgzUrl should return false for non-tarball url")
+	}
+
+	allowHosts, denyHosts := config.TgzAllowHosts, config.TgzDenyHosts
+	defer func() { config.TgzAllowHosts, config.TgzDenyHosts = allowHosts, denyHosts }()
+	config.TgzAllowHosts, config.TgzDenyHosts = nil, []string{"evil.com"}
+	if !isTgzHostAllowed("example.com") || isTgzHostAllowed("evil.com") || isTgzHostAllowed("localhost") {
+		t.Fatal("isTgzHostAllowed: unexpected result")
+	}
+	config.TgzAllowHosts, config.TgzDenyHosts = []string{"*.example.com", "127.0.0.1"}, []string{"private.example.com"}
+	if !isTgzHostAllowed("cdn.example.com") || !isTgzHostAllowed("127.0.0.1") || isTgzHostAllowed("private.example.com") || isTgzHostAllowed("example.org") {
+		t.Fatal("isTgzHostAllowed: unexpected result")
+	}
+
+	buf := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range map[string]string{
+		"package/package.json": `{"name":"tgz-example","version":"1.0.0","main":"index.js"}`,
+		"package/index.js":     `export default "hello";`,
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	tarball := buf.Bytes()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tgz-example-1.0.0.tgz" {
+			w.Write(tarball)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	workDir := config.WorkDir
+	config.WorkDir = filepath.Join(os.TempDir(), rand.Hex.String(8))
+	defer func() {
+		os.RemoveAll(config.WorkDir)
+		config.WorkDir = workDir
+	}()
+
+	npmrc := &NpmRC{}
+	// the guarded client refuses to connect to the loopback address of the test server
+	fetchGuard := fetchGuardEnabled
+	defer func() { fetchGuardEnabled = fetchGuard }()
+	fetchGuardEnabled = true
+	if _, err := npmrc.installTgzPackage(server.URL + "/tgz-example-1.0.0.tgz"); err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Fatalf("should refuse to connect to the loopback address: %v", err)
+	}
+	fetchGuardEnabled = false
+	pkg, err := npmrc.installTgzPackage(server.URL + "/tgz-example-1.0.0.tgz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Name != "tgz-example" || len(pkg.Version) != tgzHashLength || pkg.String() != "tgz/tgz-example@"+pkg.Version {
+		t.Fatalf("invalid package: %+v", pkg)
+	}
+	p, err := npmrc.installPackage(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "tgz-example" || p.Main != "index.js" {
+		t.Fatalf("invalid package.json: %+v", p)
+	}
+	if !existsFile(path.Join(npmrc.StoreDir(), pkg.String(), "node_modules/tgz-example/index.js")) {
+		t.Fatal("index.js not found")
+	}
+
+	esm, _, exactVersion, _, err := praseEsmPath(npmrc, "/tgz/tgz-example@"+pkg.Version+"/index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactVersion || !esm.TgzPrefix || esm.Name() != pkg.String() || esm.SubPath != "index.js" {
+		t.Fatalf("invalid esm path: %+v", esm)
+	}
+	if _, err = npmrc.installTgzPackage(server.URL + "/not-found.tgz"); err == nil {
+		t.Fatal("should return an error for the missing tarball")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/goccy/go-json"
 	esbuild "github.com/ije/esbuild-internal/api"
 	"github.com/ije/gox/utils"
+	"github.com/ije/gox/valid"
 )
 
 type TransformOptions struct {
@@ -25,10 +26,12 @@ type TransformOptions struct {
 	Target          string          `json:"target"`
 	SourceMap       string          `json:"sourceMap"`
 	Minify          bool            `json:"minify"`
+	Declaration     bool            `json:"declaration"`
 }
 
 type ResolvedTransformOptions struct {
 	TransformOptions
+	npmrc         *NpmRC
 	importMap     importmap.ImportMap
 	globalVersion string
 }
@@ -36,18 +39,19 @@ type ResolvedTransformOptions struct {
 type TransformOutput struct {
 	Code string `json:"code"`
 	Map  string `json:"map"`
+	Dts  string `json:"dts,omitempty"`
 }
 
 // transform transforms the given code with the given options.
 func transform(options *ResolvedTransformOptions) (out *TransformOutput, err error) {
 	target := esbuild.ESNext
+	var engines []esbuild.Engine
 	if options.Target != "" {
-		if t, ok := targets[options.Target]; ok {
-			target = t
-		} else {
+		if !isValidBuildTarget(options.Target) {
 			err = errors.New("invalid target")
 			return
 		}
+		target, engines = getBuildTarget(options.Target)
 	}
 
 	loader := esbuild.LoaderJS
@@ -59,6 +63,31 @@ func transform(options *ResolvedTransformOptions) (out *TransformOutput, err err
 		_, basename := utils.SplitByLastByte(filename, '/')
 		_, options.Lang = utils.SplitByLastByte(basename, '.')
 	}
+	if options.Declaration && options.Lang != "ts" && options.Lang != "tsx" {
+		err = errors.New("declaration requires typescript code")
+		return
+	}
+
+	// compile the vue/svelte/markdown/mdx source code to js/jsx/ts
+	switch options.Lang {
+	case "vue", "svelte", "md", "mdx":
+		npmrc := options.npmrc
+		if npmrc == nil {
+			npmrc = DefaultNpmRC()
+		}
+		filename := options.Filename
+		if filename == "" {
+			filename = "source." + options.Lang
+		}
+		var ret *LoaderOutput
+		ret, err = compileSourceCode(npmrc, options.importMap, options.Lang, filename, sourceCode)
+		if err != nil {
+			return
+		}
+		sourceCode = ret.Code
+		options.Lang = ret.Lang
+	}
+
 	switch options.Lang {
 	case "js":
 		loader = esbuild.LoaderJS
@@ -76,16 +105,7 @@ func transform(options *ResolvedTransformOptions) (out *TransformOutput, err err
 	}
 
 	if jsxImportSource == "" && (loader == esbuild.LoaderJSX || loader == esbuild.LoaderTSX) {
-		var ok bool
-		for _, key := range []string{"@jsxRuntime", "@jsxImportSource", "preact", "react"} {
-			jsxImportSource, ok = options.importMap.Resolve(key)
-			if ok {
-				break
-			}
-		}
-		if !ok {
-			jsxImportSource = "react"
-		}
+		jsxImportSource = resolveJsxImportSource(options.importMap)
 	}
 
 	sourceMap := esbuild.SourceMapNone
@@ -109,6 +129,7 @@ func transform(options *ResolvedTransformOptions) (out *TransformOutput, err err
 		Platform:          esbuild.PlatformBrowser,
 		Format:            esbuild.FormatESModule,
 		Target:            target,
+		Engines:           engines,
 		JSX:               esbuild.JSXAutomatic,
 		JSXImportSource:   strings.TrimSuffix(jsxImportSource, "/"),
 		MinifyWhitespace:  options.Minify,
@@ -147,9 +168,102 @@ func transform(options *ResolvedTransformOptions) (out *TransformOutput, err err
 			out.Map = string(file.Contents)
 		}
 	}
+	if options.Declaration {
+		npmrc := options.npmrc
+		if npmrc == nil {
+			npmrc = DefaultNpmRC()
+		}
+		var dts *LoaderOutput
+		dts, err = transformDeclaration(npmrc, filename, sourceCode)
+		if err != nil {
+			err = errors.New("failed to generate declaration: " + err.Error())
+			return
+		}
+		out.Dts = dts.Code
+	}
 	return
 }
 
+// compileSourceCode compiles the vue/svelte/markdown/mdx source code to js/jsx/ts, the compiler versions are
+// resolved by the import map.
+func compileSourceCode(npmrc *NpmRC, importMap importmap.ImportMap, lang string, filename string, code string) (out *LoaderOutput, err error) {
+	switch lang {
+	case "vue":
+		var vueVersion string
+		vueVersion, err = resolveVueVersion(npmrc, importMap)
+		if err != nil {
+			return
+		}
+		return transformVue(npmrc, vueVersion, filename, code)
+	case "svelte":
+		var svelteVersion string
+		svelteVersion, err = resolveSvelteVersion(npmrc, importMap)
+		if err != nil {
+			return
+		}
+		return transformSvelte(npmrc, svelteVersion, filename, code)
+	case "md":
+		var js []byte
+		js, err = gfm.Render([]byte(code), gfm.RenderFormatJS)
+		if err != nil {
+			return
+		}
+		return &LoaderOutput{Lang: "js", Code: string(js)}, nil
+	case "mdx":
+		var mdxVersion string
+		mdxVersion, err = resolveMDXVersion(npmrc, importMap)
+		if err != nil {
+			return
+		}
+		out, err = transformMDX(npmrc, mdxVersion, filename, code)
+		if err != nil {
+			return
+		}
+		out.Lang = "jsx"
+		return
+	}
+	return nil, errors.New("unsupported language:" + lang)
+}
+
+// checkHttpModuleOrigin checks if the origin of the http module is allowed by the `httpModuleAllowOrigins` and
+// `httpModuleDenyOrigins` config. The public IP addresses are not allowed unless they are listed in the
+// `httpModuleAllowOrigins` explicitly, or the server is running in debug mode.
+// Note: the localhost and non-public addresses are always refused by the fetch client, and removed from the
+// `httpModuleAllowOrigins` when the config is loaded, see `newGuardedFetchClient` and `filterPrivateOrigins`.
+func checkHttpModuleOrigin(u *url.URL) bool {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return false
+	}
+	for _, origin := range config.HttpModuleDenyOrigins {
+		if matchOrigin(origin, u) {
+			return false
+		}
+	}
+	for _, origin := range config.HttpModuleAllowOrigins {
+		if matchOrigin(origin, u) {
+			return true
+		}
+	}
+	if len(config.HttpModuleAllowOrigins) > 0 {
+		return false
+	}
+	hostname := strings.ToLower(u.Hostname())
+	return DEBUG || (!isLocalhost(hostname) && valid.IsDomain(hostname))
+}
+
+// matchOrigin checks if the url matches the origin pattern, e.g. "https://*.example.com" matches
+// "https://cdn.example.com/foo.js", the pattern without scheme matches both http and https.
+func matchOrigin(pattern string, u *url.URL) bool {
+	scheme, host, ok := strings.Cut(strings.TrimSuffix(pattern, "/"), "://")
+	if !ok {
+		scheme, host = "", scheme
+	}
+	if scheme != "" && scheme != u.Scheme {
+		return false
+	}
+	return matchHost(host, strings.ToLower(u.Host))
+}
+
 // bundleHttpModule bundles the http module and it's submodules.
 func bundleHttpModule(npmrc *NpmRC, entry string, importMap importmap.ImportMap, collectDependencies bool, fetchClient *fetch.FetchClient) (js []byte, jsx bool, css []byte, dependencyTree map[string][]byte, err error) {
 	if !isHttpSepcifier(entry) {
@@ -334,7 +448,7 @@ func bundleHttpModule(npmrc *NpmRC, entry string, importMap importmap.ImportMap,
 }
 
 // treeShake tree-shakes the given javascript code with the given exports.
-func treeShake(code []byte, exports []string, target esbuild.Target) ([]byte, error) {
+func treeShake(code []byte, exports []string, target string) ([]byte, error) {
 	input := &esbuild.StdinOptions{
 		Contents: fmt.Sprintf(`export { %s } from '.';`, strings.Join(exports, ", ")),
 		Loader:   esbuild.LoaderJS,
@@ -356,11 +470,13 @@ func treeShake(code []byte, exports []string, target esbuild.Target) ([]byte, er
 			},
 		},
 	}
+	esTarget, engines := getBuildTarget(target)
 	ret := esbuild.Build(esbuild.BuildOptions{
 		Stdin:             input,
 		Bundle:            true,
 		Format:            esbuild.FormatESModule,
-		Target:            target,
+		Target:            esTarget,
+		Engines:           engines,
 		Platform:          esbuild.PlatformBrowser,
 		MinifyWhitespace:  config.Minify,
 		MinifyIdentifiers: config.Minify,
@@ -376,9 +492,11 @@ func treeShake(code []byte, exports []string, target esbuild.Target) ([]byte, er
 }
 
 // minify minifies the given javascript code.
-func minify(code string, loader esbuild.Loader, target esbuild.Target) ([]byte, error) {
+func minify(code string, loader esbuild.Loader, target string) ([]byte, error) {
+	esTarget, engines := getBuildTarget(target)
 	ret := esbuild.Transform(code, esbuild.TransformOptions{
-		Target:            target,
+		Target:            esTarget,
+		Engines:           engines,
 		Format:            esbuild.FormatESModule,
 		Platform:          esbuild.PlatformBrowser,
 		MinifyWhitespace:  true,
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCheckHttpModuleOrigin(t *testing.T) {
+	allowOrigins, denyOrigins := config.HttpModuleAllowOrigins, config.HttpModuleDenyOrigins
+	defer func() { config.HttpModuleAllowOrigins, config.HttpModuleDenyOrigins = allowOrigins, denyOrigins }()
+
+	check := func(rawUrl string) bool {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return checkHttpModuleOrigin(u)
+	}
+
+	config.HttpModuleAllowOrigins, config.HttpModuleDenyOrigins = nil, []string{"https://evil.com"}
+	if !check("https://example.com/mod.ts") {
+		t.Fatal("public domain should be allowed")
+	}
+	if check("https://evil.com/mod.ts") {
+		t.Fatal("denied origin should not be allowed")
+	}
+	if check("http://127.0.0.1/mod.ts") {
+		t.Fatal("ip address should not be allowed")
+	}
+
+	config.HttpModuleAllowOrigins, config.HttpModuleDenyOrigins = []string{"https://*.example.com", "http://10.0.0.1:8080"}, []string{"private.example.com"}
+	if !check("https://cdn.example.com/mod.ts") {
+		t.Fatal("listed origin should be allowed")
+	}
+	if check("http://cdn.example.com/mod.ts") {
+		t.Fatal("the scheme should be matched")
+	}
+	if !check("http://10.0.0.1:8080/mod.ts") {
+		t.Fatal("listed ip address should be allowed")
+	}
+	if check("https://private.example.com/mod.ts") {
+		t.Fatal("denied origin should not be allowed")
+	}
+	if check("https://example.org/mod.ts") {
+		t.Fatal("unlisted origin should not be allowed")
+	}
+}
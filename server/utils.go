@@ -3,11 +3,13 @@ package server
 import (
 	"encoding/base64"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/esm-dev/esm.sh/internal/fetch"
 	"github.com/ije/gox/valid"
 )
 
@@ -20,6 +22,16 @@ func isJsReservedWord(word string) bool {
 	return false
 }
 
+// isGlobalName checks if the given string is a valid global variable name, e.g. "MyLib" or "MyLib.utils".
+func isGlobalName(s string) bool {
+	for _, name := range strings.Split(s, ".") {
+		if !isJsIdentifier(name) {
+			return false
+		}
+	}
+	return true
+}
+
 // isJsIdentifier returns true if the given string is a valid JavaScript identifier.
 func isJsIdentifier(s string) bool {
 	if len(s) == 0 {
@@ -72,6 +84,18 @@ func isAbsPathSpecifier(specifier string) bool {
 	return strings.HasPrefix(specifier, "/") || strings.HasPrefix(specifier, "file://")
 }
 
+// fetchGuardEnabled is false in debug mode to access the local development servers.
+var fetchGuardEnabled = !DEBUG
+
+// newGuardedFetchClient creates a fetch client for the user-provided urls, which refuses to connect to the private,
+// loopback, link-local and cloud metadata addresses. The `allowURL` function checks the redirect urls.
+func newGuardedFetchClient(userAgent string, timeout int, allowURL func(u *url.URL) bool) (client *fetch.FetchClient, recycle func()) {
+	if !fetchGuardEnabled {
+		return fetch.NewClient(userAgent, timeout, false)
+	}
+	return fetch.NewGuardedClient(userAgent, timeout, false, allowURL)
+}
+
 // checks if the given hostname is a local address.
 func isLocalhost(hostname string) bool {
 	return hostname == "localhost" || hostname == "127.0.0.1" || (valid.IsIPv4(hostname) && strings.HasPrefix(hostname, "192.168."))
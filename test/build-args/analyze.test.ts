@@ -0,0 +1,29 @@
+import { assert, assertEquals, assertStringIncludes } from "jsr:@std/assert";
+
+Deno.test("?analyze", async () => {
+  const res = await fetch("http://localhost:8080/react-dom@18.3.1?analyze&target=es2022");
+  assertEquals(res.status, 200);
+  assertEquals(res.headers.get("content-type"), "application/json; charset=utf-8");
+  const analysis = await res.json();
+  assertEquals(analysis.path, "/react-dom@18.3.1/es2022/react-dom.mjs");
+  assertEquals(analysis.outputs.length, 1);
+  assert(analysis.outputs[0].bytes > analysis.outputs[0].gzip);
+  assert(analysis.outputs[0].gzip > analysis.outputs[0].brotli);
+  const inputs = analysis.inputs.map((input: { path: string }) => input.path);
+  assert(inputs.includes("node_modules/react-dom/cjs/react-dom.production.min.js"));
+  assert(analysis.inputs.every((input: { package: string }) => input.package === "react-dom"));
+  assert(analysis.imports.includes("/react@18.3.1/es2022/react.mjs"));
+  assert(analysis.imports.some((path: string) => path.startsWith("/scheduler@")));
+
+  const res2 = await fetch("http://localhost:8080/react-dom@18.3.1/es2022/react-dom.mjs?analyze");
+  assertEquals(await res2.json(), analysis);
+});
+
+Deno.test("?analyze=html", async () => {
+  const res = await fetch("http://localhost:8080/react@18.3.1?analyze=html&target=es2022");
+  assertEquals(res.status, 200);
+  assertEquals(res.headers.get("content-type"), "text/html; charset=utf-8");
+  const html = await res.text();
+  assertStringIncludes(html, `"path":"/react@18.3.1/es2022/react.mjs"`);
+  assertStringIncludes(html, "node_modules/react/cjs/react.production.min.js");
+});
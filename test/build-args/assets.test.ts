@@ -0,0 +1,30 @@
+import { assert, assertEquals, assertStringIncludes } from "jsr:@std/assert";
+
+Deno.test("?assets=external", async () => {
+  const res = await fetch(
+    "http://localhost:8080/monaco-editor@0.50.0/esm/vs/base/browser/ui/codicons/codiconStyles.js?assets=external&target=es2022",
+  );
+  assertEquals(res.status, 200);
+  res.body?.cancel();
+  const esmPath = res.headers.get("x-esm-path")!;
+  assert(esmPath.includes("/X-"), esmPath);
+
+  const res2 = await fetch("http://localhost:8080" + esmPath.replace(/\.mjs$/, ".css"));
+  assertEquals(res2.status, 200);
+  const css = await res2.text();
+  assert(!css.includes("data:font/ttf"));
+  const m = css.match(/url\("?(\/monaco-editor@0\.50\.0\/X-[\w-]+\/es2022\/[\w/.-]*codicon-[A-Z0-9]{8}\.ttf)"?\)/);
+  assert(m !== null, css);
+
+  const res3 = await fetch("http://localhost:8080" + m![1]);
+  assertEquals(res3.status, 200);
+  assertEquals(res3.headers.get("content-type"), "font/ttf");
+  assertStringIncludes(res3.headers.get("cache-control")!, "immutable");
+  res3.body?.cancel();
+});
+
+Deno.test("invalid ?assets", async () => {
+  const res = await fetch("http://localhost:8080/monaco-editor@0.50.0/esm/vs/base/browser/ui/codicons/codiconStyles.js?assets=foo");
+  assertEquals(res.status, 400);
+  res.body?.cancel();
+});
@@ -0,0 +1,22 @@
+import { assertEquals, assertStringIncludes } from "jsr:@std/assert";
+
+Deno.test("?define", async () => {
+  const define = `__DEV__:false,process.env.API_URL:"/api"`;
+  const res = await fetch("http://localhost:8080/tiny-invariant@1.3.3?target=es2022&define=" + encodeURIComponent(define));
+  assertEquals(res.status, 200);
+  const code = await res.text();
+  const m = code.match(/"\/tiny-invariant@1\.3\.3\/(X-[\w-]+)\/es2022\/tiny-invariant\.mjs"/);
+  assertEquals(m !== null, true, code);
+  const res2 = await fetch("http://localhost:8080/tiny-invariant@1.3.3/" + m![1] + "/es2022/tiny-invariant.mjs");
+  assertEquals(res2.status, 200);
+  assertStringIncludes(res2.headers.get("content-type")!, "javascript");
+  res2.body?.cancel();
+});
+
+Deno.test("invalid ?define", async () => {
+  for (const define of ["window:1", "process.env.NODE_ENV:1", "foo:bar", `foo:{"a":1}`]) {
+    const res = await fetch("http://localhost:8080/tiny-invariant@1.3.3?define=" + encodeURIComponent(define));
+    assertEquals(res.status, 400, define);
+    res.body?.cancel();
+  }
+});
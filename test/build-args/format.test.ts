@@ -0,0 +1,49 @@
+import { assertEquals, assertStringIncludes } from "jsr:@std/assert";
+
+Deno.test("?format=iife", async () => {
+  const res = await fetch("http://localhost:8080/react@18.3.1?format=iife&global=React&target=es2022");
+  assertEquals(res.status, 200);
+  assertEquals(res.headers.get("content-type"), "application/javascript; charset=utf-8");
+  assertEquals(res.headers.get("x-esm-path"), "/react@18.3.1/X-Z1JlYWN0/es2022/react.iife.js");
+  const code = await res.text();
+  assertStringIncludes(code, "(function(g){");
+  new Function(code)();
+  // deno-lint-ignore no-explicit-any
+  const React = (globalThis as any).React;
+  assertEquals(typeof React.createElement, "function");
+  assertEquals(typeof React.useState, "function");
+
+  const res2 = await fetch("http://localhost:8080/react@18.3.1/X-Z1JlYWN0/es2022/react.iife.js");
+  assertEquals(res2.status, 200);
+  assertEquals(await res2.text(), code);
+});
+
+Deno.test("?format=umd", async () => {
+  const res = await fetch("http://localhost:8080/react-dom@18.3.1?format=umd&global=ReactDOM&target=es2022");
+  assertEquals(res.status, 200);
+  assertEquals(res.headers.get("x-esm-path"), "/react-dom@18.3.1/X-Z1JlYWN0RE9N/es2022/react-dom.umd.js");
+  const code = await res.text();
+  assertStringIncludes(code, "(function(g,f){");
+  // the `react` peer dependency is bundled
+  const mod = { exports: {} as Record<string, unknown> };
+  new Function("module", "exports", code)(mod, mod.exports);
+  assertEquals(typeof mod.exports.createPortal, "function");
+});
+
+Deno.test("invalid ?format", async () => {
+  {
+    const res = await fetch("http://localhost:8080/react@18.3.1?format=amd");
+    assertEquals(res.status, 400);
+    res.body?.cancel();
+  }
+  {
+    const res = await fetch("http://localhost:8080/react@18.3.1?format=umd");
+    assertEquals(res.status, 400);
+    res.body?.cancel();
+  }
+  {
+    const res = await fetch("http://localhost:8080/react@18.3.1?format=iife&global=my-lib");
+    assertEquals(res.status, 400);
+    res.body?.cancel();
+  }
+});
@@ -0,0 +1,38 @@
+import { assert, assertEquals } from "jsr:@std/assert";
+
+Deno.test("?sbom", async () => {
+  const res = await fetch("http://localhost:8080/react-dom@18.3.1?sbom&bundle&target=es2022");
+  assertEquals(res.status, 200);
+  assertEquals(res.headers.get("content-type"), "application/json; charset=utf-8");
+  const bom = await res.json();
+  assertEquals(bom.bomFormat, "CycloneDX");
+  assertEquals(bom.metadata.component.name, "react-dom");
+  assertEquals(bom.metadata.component.version, "18.3.1");
+  assertEquals(bom.metadata.component.purl, "pkg:npm/react-dom@18.3.1");
+  assertEquals(bom.metadata.component.licenses, [{ license: { id: "MIT" } }]);
+  assertEquals(bom.metadata.component.hashes[0].alg, "SHA-512");
+  const scheduler = bom.components.find((c: { name: string }) => c.name === "scheduler");
+  assert(scheduler);
+  assert(scheduler.hashes[0].content.length === 128);
+  assert(bom.dependencies[0].dependsOn.includes(scheduler.purl));
+
+  const res2 = await fetch("http://localhost:8080/react-dom@18.3.1/es2022/react-dom.bundle.mjs?sbom");
+  assertEquals(res2.status, 200);
+  assertEquals((await res2.json()).metadata.component.name, "react-dom");
+});
+
+Deno.test("?sbom=spdx", async () => {
+  const res = await fetch("http://localhost:8080/react-dom@18.3.1?sbom=spdx&bundle&target=es2022");
+  assertEquals(res.status, 200);
+  const doc = await res.json();
+  assertEquals(doc.spdxVersion, "SPDX-2.3");
+  assertEquals(doc.packages[0].name, "react-dom");
+  assertEquals(doc.packages[0].licenseDeclared, "MIT");
+  assertEquals(doc.packages[0].checksums[0].algorithm, "SHA512");
+  assert(doc.packages.some((p: { name: string }) => p.name === "scheduler"));
+  assert(doc.relationships.some((r: { relationshipType: string }) => r.relationshipType === "DEPENDS_ON"));
+
+  const res2 = await fetch("http://localhost:8080/react-dom@18.3.1?sbom=foo");
+  assertEquals(res2.status, 400);
+  await res2.body?.cancel();
+});
@@ -65,3 +65,33 @@ Deno.test("target from query", async () => {
     assertStringIncludes(await res.text(), "/es2024/");
   }
 });
+
+Deno.test("browser target from ua", async () => {
+  const ua = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36";
+  const res = await fetch("http://localhost:8080/react@18.3.1", { headers: { "User-Agent": ua } });
+  assertEquals(res.status, 200);
+  assertStringIncludes(res.headers.get("Vary")!, "User-Agent");
+  const code = await res.text();
+  assert(/\/react@18\.3\.1\/chrome\d+\/react\.mjs/.test(code), code);
+});
+
+Deno.test("browserslist target from query", async () => {
+  {
+    const res = await fetch("http://localhost:8080/react@18.3.1?target=" + encodeURIComponent("safari>=15.4, chrome>=100"));
+    assertEquals(res.status, 200);
+    assert(!res.headers.get("Vary")?.includes("User-Agent"));
+    assertStringIncludes(await res.text(), "/chrome100-safari15.4/");
+  }
+  {
+    const res = await fetch("http://localhost:8080/react@18.3.1?target=defaults");
+    assertEquals(res.status, 200);
+    assert(!res.headers.get("Vary")?.includes("User-Agent"));
+    assertStringIncludes(await res.text(), "/chrome107-edge107-firefox104-safari16/");
+  }
+  {
+    const res = await fetch("http://localhost:8080/react@18.3.1/chrome100-safari15.4/react.mjs");
+    assertEquals(res.status, 200);
+    assertEquals(res.headers.get("Content-Type"), "application/javascript; charset=utf-8");
+    res.body?.cancel();
+  }
+});
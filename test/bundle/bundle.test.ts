@@ -0,0 +1,69 @@
+import { assert, assertEquals, assertStringIncludes } from "jsr:@std/assert";
+
+Deno.test("POST /bundle", async () => {
+  const options = {
+    files: {
+      "/main.tsx": `
+        import { h, render } from "preact";
+        import App from "./app.tsx";
+        import { version } from "./data.json";
+        render(h(App, { version }), document.body);
+        export const lazy = () => import("./lazy.ts");
+      `,
+      "/app.tsx": `export default (props: { version: string }) => <h1>v{props.version}</h1>;`,
+      "/data.json": `{ "version": "1.0.0" }`,
+      "/lazy.ts": `export { default as dayjs } from "dayjs";`,
+    },
+    entry: "./main.tsx",
+    importMap: {
+      imports: {
+        "preact": "https://esm.sh/preact@10.25.4",
+        "preact/": "https://esm.sh/preact@10.25.4/",
+      },
+    },
+    target: "es2022",
+  };
+  const res = await fetch("http://localhost:8080/bundle", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(options),
+  });
+  assertEquals(res.status, 200);
+  const out = await res.json();
+  assert(/^http:\/\/localhost:8080\/\+[0-9a-f]{40}\.mjs$/.test(out.url));
+  assertStringIncludes(out.code, `"https://esm.sh/preact@10.25.4"`);
+  assertStringIncludes(out.code, `"https://esm.sh/preact@10.25.4/jsx-runtime"`);
+  assertStringIncludes(out.code, `"1.0.0"`);
+  assertStringIncludes(out.code, `//# sourceMappingURL=${out.url.split("/").pop()}.map`);
+  assertStringIncludes(out.map, `"mappings":`);
+  assertEquals(out.chunks.length, 1);
+  assertStringIncludes(out.chunks[0].code, `"/dayjs?target=es2022"`);
+
+  const res2 = await fetch(out.url);
+  assertEquals(res2.status, 200);
+  assertEquals(res2.headers.get("Content-Type"), "application/javascript; charset=utf-8");
+  assertEquals(await res2.text(), out.code);
+
+  const res3 = await fetch(out.chunks[0].url);
+  assertEquals(res3.status, 200);
+  assertEquals(await res3.text(), out.chunks[0].code);
+
+  // cached
+  const res4 = await fetch("http://localhost:8080/bundle", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(options),
+  });
+  assertEquals(res4.status, 200);
+  assertEquals(await res4.json(), out);
+});
+
+Deno.test("POST /bundle: missing file", async () => {
+  const res = await fetch("http://localhost:8080/bundle", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ files: { "/main.ts": `import "./foo.ts";` }, entry: "/main.ts" }),
+  });
+  assertEquals(res.status, 400);
+  assertStringIncludes(await res.text(), "file not found");
+});
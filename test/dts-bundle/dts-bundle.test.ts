@@ -0,0 +1,21 @@
+import { assert, assertEquals, assertStringIncludes } from "jsr:@std/assert";
+
+Deno.test("?dts-bundle", async () => {
+  const res = await fetch("http://localhost:8080/zod@3.24.1?dts-bundle");
+  res.body?.cancel();
+  assertEquals(res.status, 200);
+  const dtsUrl = res.headers.get("X-TypeScript-Types")!;
+  assert(dtsUrl.endsWith(".d.ts?dts-bundle"));
+
+  const res2 = await fetch(dtsUrl);
+  assertEquals(res2.status, 200);
+  assertEquals(res2.headers.get("Content-Type"), "application/typescript; charset=utf-8");
+  const dts = await res2.text();
+  assertStringIncludes(dts, "declare namespace __esm_dts_");
+  assert(!/from\s*["']\.\.?\//.test(dts));
+
+  const res3 = await fetch(dtsUrl.slice(0, -"?dts-bundle".length));
+  assertEquals(res3.status, 200);
+  const dts2 = await res3.text();
+  assert(!dts2.includes("declare namespace __esm_dts_"));
+});
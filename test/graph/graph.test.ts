@@ -0,0 +1,32 @@
+import { assert, assertEquals } from "jsr:@std/assert";
+
+Deno.test("/graph/", async () => {
+  const res = await fetch("http://localhost:8080/graph/react-dom@18.3.1/client?target=es2022");
+  assertEquals(res.status, 200);
+  assertEquals(res.headers.get("content-type"), "application/json; charset=utf-8");
+  const graph = await res.json();
+  assertEquals(graph.root, "http://localhost:8080/react-dom@18.3.1/es2022/client.mjs");
+  assertEquals(graph.modules[0].url, graph.root);
+  assertEquals(graph.modules[0].package, "react-dom");
+  assertEquals(graph.modules[0].version, "18.3.1");
+
+  const urls = graph.modules.map((m: { url: string }) => m.url);
+  assert(urls.includes("http://localhost:8080/react-dom@18.3.1/es2022/react-dom.mjs"));
+  assert(urls.includes("http://localhost:8080/react@18.3.1/es2022/react.mjs"));
+  assert(urls.some((url: string) => url.startsWith("http://localhost:8080/scheduler@")));
+  assertEquals(new Set(urls).size, urls.length);
+
+  for (const m of graph.modules) {
+    assert(!m.error, m.error);
+    assert(m.size > 0, m.url);
+    for (const url of m.imports) {
+      assert(urls.includes(url), url);
+    }
+  }
+});
+
+Deno.test("/graph/ with invalid path", async () => {
+  const res = await fetch("http://localhost:8080/graph/react-dom@18.3.1/es2022/client.mjs");
+  res.body?.cancel();
+  assertEquals(res.status, 400);
+});
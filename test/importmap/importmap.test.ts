@@ -0,0 +1,58 @@
+import { assert, assertEquals, assertStringIncludes } from "jsr:@std/assert";
+
+Deno.test("POST /importmap", async () => {
+  const res = await fetch("http://localhost:8080/importmap", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      packages: ["react-dom@18.3.1", "react@18.3.1"],
+      target: "es2022",
+    }),
+  });
+  assertEquals(res.status, 200);
+  const importMap = await res.json();
+  assertEquals(importMap.imports["react"], "http://localhost:8080/*react@18.3.1?target=es2022");
+  assertEquals(importMap.imports["react/"], "http://localhost:8080/*react@18.3.1&target=es2022/");
+  assertEquals(importMap.imports["react-dom"], "http://localhost:8080/*react-dom@18.3.1?target=es2022");
+  assertStringIncludes(importMap.scopes["http://localhost:8080/"]["scheduler"], "http://localhost:8080/scheduler@0.23.");
+  // the peer dependency is resolved to the top-level package
+  assert(!("react" in importMap.scopes["http://localhost:8080/"]));
+  assertEquals(importMap.integrity, undefined);
+});
+
+Deno.test("POST /importmap: scopes", async () => {
+  const res = await fetch("http://localhost:8080/importmap", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      packages: ["react-dom@18.3.1", "scheduler@0.20.2"],
+    }),
+  });
+  assertEquals(res.status, 200);
+  const importMap = await res.json();
+  assertEquals(importMap.imports["scheduler"], "http://localhost:8080/*scheduler@0.20.2");
+  assertStringIncludes(importMap.scopes["http://localhost:8080/*react-dom@18.3.1/"]["scheduler"], "http://localhost:8080/scheduler@0.23.");
+});
+
+Deno.test("POST /importmap: integrity", async () => {
+  {
+    const res = await fetch("http://localhost:8080/importmap", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ packages: ["react@18.3.1"], integrity: true }),
+    });
+    res.body?.cancel();
+    assertEquals(res.status, 400);
+  }
+  {
+    const res = await fetch("http://localhost:8080/importmap", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ packages: ["react@18.3.1"], target: "es2022", integrity: true }),
+    });
+    assertEquals(res.status, 200);
+    const importMap = await res.json();
+    assertEquals(importMap.imports["react"], "http://localhost:8080/*react@18.3.1/es2022/react.mjs");
+    assert(importMap.integrity["http://localhost:8080/*react@18.3.1/es2022/react.mjs"].startsWith("sha384-"));
+  }
+});
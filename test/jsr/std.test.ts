@@ -15,6 +15,13 @@ Deno.test("jsr subpath", async () => {
 Deno.test("jsr raw path", async () => {
   const res = await fetch("http://localhost:8080/jsr.io/@std/assert@1.0.10/mod.ts");
   assertEquals(res.status, 200);
-  assertEquals(res.headers.get("x-typescript-types"), "http://localhost:8080/@jsr/std__assert@1.0.10/_dist/mod.d.ts");
-  assertStringIncludes(await res.text(), "/@jsr/std__assert@1.0.10/denonext/mod.ts.mjs");
+  // the original typescript source is served for the `denonext` target
+  assertEquals(res.headers.get("content-type"), "application/typescript; charset=utf-8");
+  assertStringIncludes(await res.text(), `export * from "./assert.ts";`);
+});
+
+Deno.test("jsr raw path (browser)", async () => {
+  const res = await fetch("http://localhost:8080/jsr.io/@std/assert@1.0.10/mod.ts?target=es2022");
+  assertEquals(res.status, 200);
+  assertStringIncludes(await res.text(), "/@jsr/std__assert@1.0.10/es2022/mod.ts.mjs");
 });
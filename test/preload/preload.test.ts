@@ -0,0 +1,28 @@
+import { assert, assertEquals, assertStringIncludes } from "jsr:@std/assert";
+
+Deno.test("modulepreload links", async () => {
+  {
+    const res = await fetch("http://localhost:8080/react-dom@18.3.1/client?target=es2022");
+    res.body?.cancel();
+    assertEquals(res.status, 200);
+    const link = res.headers.get("link")!;
+    assert(link.startsWith("<http://localhost:8080/react-dom@18.3.1/es2022/client.mjs>; rel=modulepreload"));
+    // the transitive imports
+    assertStringIncludes(link, "<http://localhost:8080/react-dom@18.3.1/es2022/react-dom.mjs>; rel=modulepreload");
+    assertStringIncludes(link, "<http://localhost:8080/react@18.3.1/es2022/react.mjs>; rel=modulepreload");
+    assertStringIncludes(link, "<http://localhost:8080/scheduler@");
+  }
+  {
+    const res = await fetch("http://localhost:8080/react-dom@18.3.1/es2022/react-dom.mjs");
+    res.body?.cancel();
+    assertEquals(res.status, 200);
+    const link = res.headers.get("link")!;
+    assert(link.startsWith("<http://localhost:8080/react@18.3.1/es2022/react.mjs>; rel=modulepreload"));
+  }
+  {
+    const res = await fetch("http://localhost:8080/react@18.3.1/es2022/react.mjs");
+    res.body?.cancel();
+    assertEquals(res.status, 200);
+    assertEquals(res.headers.get("link"), null);
+  }
+});
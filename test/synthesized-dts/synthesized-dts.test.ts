@@ -0,0 +1,15 @@
+import { assert, assertEquals, assertStringIncludes } from "jsr:@std/assert";
+
+Deno.test("synthesized declarations for untyped packages", async () => {
+  const res = await fetch("http://localhost:8080/is-even@1.0.0");
+  res.body?.cancel();
+  assertEquals(res.status, 200);
+  const dtsUrl = res.headers.get("X-TypeScript-Types")!;
+  assert(dtsUrl.endsWith("/is-even@1.0.0/index.synthesized.d.ts"));
+
+  const res2 = await fetch(dtsUrl);
+  assertEquals(res2.status, 200);
+  assertEquals(res2.headers.get("Content-Type"), "application/typescript; charset=utf-8");
+  const dts = await res2.text();
+  assertStringIncludes(dts, "declare const __default: any;\nexport default __default;\n");
+});
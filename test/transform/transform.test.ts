@@ -80,6 +80,79 @@ Deno.test("transform", async (t) => {
     assertEquals(map, transformOut.map);
   });
 
+  await t.step("transform API: declaration", async () => {
+    const options = {
+      lang: "ts",
+      code: `export function add(a: number, b: number): number { return a + b; }\nexport const name: string = "esm.sh";`,
+      declaration: true,
+    };
+    const hash = await computeHash(options.lang + options.code + "esnext" + "false" + "declaration");
+    const res = await fetch("http://localhost:8080/transform", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(options),
+    });
+    assertEquals(res.status, 200);
+    const { code, dts } = await res.json();
+    assertStringIncludes(code, "function add(a, b)");
+    assertStringIncludes(dts, "export declare function add(a: number, b: number): number;");
+    assertStringIncludes(dts, "export declare const name: string;");
+
+    const res2 = await fetch(`http://localhost:8080/+${hash}.mjs`);
+    res2.body?.cancel();
+    assertEquals(res2.status, 200);
+    assertEquals(res2.headers.get("X-TypeScript-Types"), `http://localhost:8080/+${hash}.d.ts`);
+
+    const res3 = await fetch(`http://localhost:8080/+${hash}.d.ts`);
+    assertEquals(res3.status, 200);
+    assertEquals(res3.headers.get("Content-Type"), "application/typescript; charset=utf-8");
+    assertEquals(await res3.text(), dts);
+  });
+
+  await t.step("transform API: vue/svelte/md/mdx", async () => {
+    const transform = async (options: Record<string, unknown>) => {
+      const res = await fetch("http://localhost:8080/transform", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(options),
+      });
+      assertEquals(res.status, 200);
+      const { code } = await res.json();
+      return code as string;
+    };
+    {
+      const code = await transform({
+        lang: "vue",
+        code: `<script setup>const msg = "Hello"</script><template><h1>{{ msg }}</h1></template>`,
+        importMap: { imports: { "vue": "https://esm.sh/vue@3.5.8" } },
+      });
+      assertStringIncludes(code, `from "https://esm.sh/vue@3.5.8"`);
+      assertStringIncludes(code, `"h1"`);
+    }
+    {
+      const code = await transform({
+        filename: "App.svelte",
+        code: `<script>let name = "world";</script><h1>Hello {name}!</h1>`,
+        importMap: { imports: { "svelte/": "https://esm.sh/svelte@5.16.0/" } },
+      });
+      assertStringIncludes(code, `from "https://esm.sh/svelte@5.16.0/internal/client"`);
+      assertStringIncludes(code, `<h1>`);
+    }
+    {
+      const code = await transform({ lang: "md", code: `# Hello world` });
+      assertStringIncludes(code, `<h1 id="hello-world">Hello world</h1>`);
+    }
+    {
+      const code = await transform({
+        lang: "mdx",
+        code: `export const Thing = () => <>World!</>\n\n# Hello, <Thing />`,
+        importMap: { imports: { "@jsxImportSource": "https://esm.sh/preact@10.24.1" } },
+      });
+      assertStringIncludes(code, `from "https://esm.sh/preact@10.24.1/jsx-runtime"`);
+      assertStringIncludes(code, `"h1"`);
+    }
+  });
+
   await t.step("transform module: vanilla", async () => {
     const im = btoaUrl("/vanilla/");
     const res = await fetch(`http://localhost:8080/http://localhost:8083/vanilla/app/main.ts?im=${im}`);
@@ -631,22 +631,56 @@ func (s *Handler) ServeRPC(w http.ResponseWriter, r *http.Request, filename stri
 }
 
 func (s *Handler) ServeCSSModule(w http.ResponseWriter, r *http.Request, query url.Values) {
-	ret := esbuild.Build(esbuild.BuildOptions{
-		EntryPoints:      []string{filepath.Join(s.config.AppDir, r.URL.Path)},
+	filename := filepath.Join(s.config.AppDir, r.URL.Path)
+	options := esbuild.BuildOptions{
+		EntryPoints:      []string{filename},
 		Write:            false,
 		MinifyWhitespace: true,
 		MinifySyntax:     true,
 		Target:           esbuild.ES2022,
 		Bundle:           true,
-	})
+	}
+	// bundle the `*.module.css` with a JS entry to get the mapping of the scoped names of esbuild's `local-css` loader
+	isCSSModule := strings.HasSuffix(r.URL.Path, ".module.css")
+	if isCSSModule {
+		if _, err := os.Stat(filename); err != nil {
+			if os.IsNotExist(err) {
+				http.Error(w, "Not Found", 404)
+			} else {
+				http.Error(w, "Internal Server Error", 500)
+			}
+			return
+		}
+		specifier := "./" + filepath.Base(filename)
+		entry := fmt.Sprintf("export{default}from%q;export*from%q;", specifier, specifier)
+		options.EntryPoints = nil
+		options.Stdin = &esbuild.StdinOptions{
+			Contents:   entry,
+			ResolveDir: filepath.Dir(filename),
+			Sourcefile: filename + ".js",
+			Loader:     esbuild.LoaderJS,
+		}
+		options.Format = esbuild.FormatESModule
+		options.Outdir = "/"
+		options.Loader = map[string]esbuild.Loader{".module.css": esbuild.LoaderLocalCSS}
+	}
+	ret := esbuild.Build(options)
 	if len(ret.Errors) > 0 {
 		fmt.Println(term.Red(ret.Errors[0].Text))
 		http.Error(w, "Internal Server Error", 500)
 		return
 	}
-	css := bytes.TrimSpace(ret.OutputFiles[0].Contents)
+	var css, js []byte
+	for _, file := range ret.OutputFiles {
+		if strings.HasSuffix(file.Path, ".css") {
+			css = bytes.TrimSpace(file.Contents)
+		} else if strings.HasSuffix(file.Path, ".js") {
+			js = file.Contents
+		}
+	}
 	xx := xxhash.New()
 	xx.Write(css)
+	xx.Write(js)
 	etag := fmt.Sprintf("w/\"%x%s\"", xx.Sum(nil), s.etagSuffix)
 	if r.Header.Get("If-None-Match") == etag && !query.Has("t") {
 		w.WriteHeader(http.StatusNotModified)
@@ -662,6 +696,17 @@ func (s *Handler) ServeCSSModule(w http.ResponseWriter, r *http.Request, query u
 	w.Write(bytes.ReplaceAll(css, []byte{'"'}, []byte{'\\', '"'}))
 	w.Write([]byte("\";let style,"))
 	w.Write([]byte(`applyCSS=css=>{(style??(style=document.head.appendChild(document.createElement("style")))).textContent=css};`))
+	if isCSSModule {
+		if s.config.Dev {
+			w.Write([]byte(`import createHot from"/@hmr";`))
+			w.Write([]byte("const hot=createHot(import.meta.url);hot.accept(_=>applyCSS(_.$css));!hot.locked&&applyCSS(css);"))
+		} else {
+			w.Write([]byte("applyCSS(css);"))
+		}
+		w.Write([]byte("export const $css=css;\n"))
+		w.Write(js)
+		return
+	}
 	if s.config.Dev {
 		w.Write([]byte(`import createHot from"/@hmr";`))
 		w.Write([]byte("const hot=createHot(import.meta.url);hot.accept(_=>applyCSS(_.default));!hot.locked&&applyCSS(css);"))
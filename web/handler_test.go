@@ -0,0 +1,75 @@
+package web
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestServeCSSModule(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.module.css":   `.base { margin: 0; }`,
+		"button.module.css": `.btn { color: red; } .primary { composes: btn; composes: base from "./base.module.css"; }`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &Handler{config: &Config{AppDir: dir, Dev: true}, etagSuffix: "-test"}
+	w := httptest.NewRecorder()
+	s.ServeCSSModule(w, httptest.NewRequest("GET", "/button.module.css?module", nil), url.Values{"module": {""}})
+	if w.Code != 200 {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	js := w.Body.String()
+
+	// the class names are scoped, and the css is applied with the hmr in dev mode
+	btn := regexp.MustCompile(`btn"?:\s*"([\w-]+)"`).FindStringSubmatch(js)
+	if btn == nil || btn[1] == "btn" {
+		t.Fatalf("the class names should be scoped:\n%s", js)
+	}
+	for _, s := range []string{
+		`const css="`,
+		`.` + btn[1] + `{color:red}`,
+		`import createHot from"/@hmr";`,
+		`hot.accept(_=>applyCSS(_.$css))`,
+		`export const $css=css;`,
+		` as default`,
+	} {
+		if !strings.Contains(js, s) {
+			t.Fatalf("missing %q in the output:\n%s", s, js)
+		}
+	}
+
+	// the composed class names of the other css modules are bundled
+	composed := regexp.MustCompile(`primary"?:([^,\n}]*)`).FindStringSubmatch(js)
+	if composed == nil || !strings.Contains(composed[1], btn[1]) || !strings.Contains(js, "{margin:0}") {
+		t.Fatalf("the composed class names should be added to the class map:\n%s", js)
+	}
+	if strings.Contains(js, "composes") {
+		t.Fatalf("the composes declarations should be removed:\n%s", js)
+	}
+
+	// the etag is stable
+	etag := w.Header().Get("Etag")
+	r := httptest.NewRequest("GET", "/button.module.css?module", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.ServeCSSModule(w, r, url.Values{"module": {""}})
+	if etag == "" || w.Code != 304 {
+		t.Fatalf("the etag should be stable, got status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeCSSModule(w, httptest.NewRequest("GET", "/missing.module.css?module", nil), url.Values{})
+	if w.Code != 404 {
+		t.Fatalf("unexpected status %d for the missing file", w.Code)
+	}
+}